@@ -3,21 +3,34 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
 	"github.com/JILI-GAMES/b_backend_games12/pkg/common/config"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/fairness"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/idempotency"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
 	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
 	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/statetoken"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/wallet"
 	"github.com/JILI-GAMES/b_backend_games12/pkg/games/birdspartydeluxe"
 )
 
 func main() {
 	// Load configuration
 	prodCfg, testCfg := config.LoadAll()
+	spinFlow, err := birdspartydeluxe.ParseSpinFlow(prodCfg.SpinFlow)
+	if err != nil {
+		log.Fatalf("Error reading spin flow: %v", err)
+	}
 
 	fmt.Println("Production Configuration:", prodCfg)
 	fmt.Println("Test Configuration:", testCfg)
@@ -31,12 +44,36 @@ func main() {
 	log.SetOutput(logFile)
 
 	// Create shared clients
-	rngClient := rng.NewClient(prodCfg.RNGServiceURL)
+	rngClient, err := newOutcomeProvider(prodCfg)
+	if err != nil {
+		log.Fatalf("Error creating RNG provider: %v", err)
+	}
 	settingsClient := settings.NewClient(prodCfg.SettingsServiceURL)
-	
+	settingsClient.Backoff.MaxRetries = prodCfg.SettingsMaxRetries
+	settingsClient.Backoff.InitialInterval = prodCfg.SettingsBackoffInitial
+	settingsClient.Backoff.MaxInterval = prodCfg.SettingsBackoffMax
+	if prodCfg.SettingsCacheTTL > 0 {
+		settingsClient.Cache = settings.NewCache(prodCfg.SettingsCacheTTL, prodCfg.SettingsCacheStale)
+	}
+
 	// Create test clients
-	rngTestClient := rng.NewClient(testCfg.RNGServiceURL)
+	rngTestClient, err := newOutcomeProvider(testCfg)
+	if err != nil {
+		log.Fatalf("Error creating test RNG provider: %v", err)
+	}
 	settingsTestClient := settings.NewClient(testCfg.SettingsServiceURL)
+	settingsTestClient.Backoff.MaxRetries = testCfg.SettingsMaxRetries
+	settingsTestClient.Backoff.InitialInterval = testCfg.SettingsBackoffInitial
+	settingsTestClient.Backoff.MaxInterval = testCfg.SettingsBackoffMax
+	if testCfg.SettingsCacheTTL > 0 {
+		settingsTestClient.Cache = settings.NewCache(testCfg.SettingsCacheTTL, testCfg.SettingsCacheStale)
+	}
+
+	// Create the server-side round session store
+	sessionStore, err := session.NewStore(prodCfg.SessionStore, prodCfg.SessionDir)
+	if err != nil {
+		log.Fatalf("Error creating session store: %v", err)
+	}
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -52,8 +89,106 @@ func main() {
 	}))
 
 	// Register routes for Birds Party Deluxe
-	birdsPartyDeluxeRoutes := birdspartydeluxe.NewRouteGroup(rngClient, settingsClient, rngTestClient, settingsTestClient)
+	birdsPartyDeluxeRoutes := birdspartydeluxe.NewRouteGroup(rngClient, settingsClient, rngTestClient, settingsTestClient, sessionStore)
+	// Refuse to serve with a random source that looks broken
+	randomFactory, err := random.NewFactory(prodCfg.RandomSource)
+	if err != nil {
+		log.Fatalf("Error creating random source: %v", err)
+	}
+	if err := random.SelfTest(randomFactory); err != nil {
+		log.Fatalf("Random source self-test failed: %v", err)
+	}
+	birdsPartyDeluxeRoutes.Random = randomFactory
+	birdsPartyDeluxeRoutes.SpinFlow = spinFlow
+	// Refuse to serve with math that does not validate
+	if prodCfg.MathModelFile != "" {
+		if birdsPartyDeluxeRoutes.MathModel, err = birdspartydeluxe.LoadMathModel(prodCfg.MathModelFile); err != nil {
+			log.Fatalf("Error loading math model: %v", err)
+		}
+	}
+	if birdsPartyDeluxeRoutes.OperatorMathModels, err = birdspartydeluxe.LoadOperatorMathModels(prodCfg.OperatorMathModels); err != nil {
+		log.Fatalf("Error loading operator math models: %v", err)
+	}
+	log.Printf("Math model of new rounds: %s", birdsPartyDeluxeRoutes.MathModel.ID())
+	for clientID, model := range birdsPartyDeluxeRoutes.OperatorMathModels {
+		log.Printf("Math model of operator %s: %s", clientID, model.ID())
+	}
+	// Refuse to serve production with weights meant for testers
+	birdsPartyDeluxeRoutes.WeightProfileProd = prodCfg.WeightProfile
+	birdsPartyDeluxeRoutes.WeightProfileTest = testCfg.WeightProfile
+	if err := birdsPartyDeluxeRoutes.CheckWeightProfiles(); err != nil {
+		log.Fatalf("Error checking weight profiles: %v", err)
+	}
+	log.Printf("Weight profiles: %s in production, %s in test", prodCfg.WeightProfile, testCfg.WeightProfile)
+	if birdsPartyDeluxeRoutes.FailurePolicyProd, err = birdspartydeluxe.ParseFailurePolicy(prodCfg.FailurePolicy); err != nil {
+		log.Fatalf("Error reading failure policy: %v", err)
+	}
+	if birdsPartyDeluxeRoutes.FailurePolicyTest, err = birdspartydeluxe.ParseFailurePolicy(testCfg.FailurePolicy); err != nil {
+		log.Fatalf("Error reading test failure policy: %v", err)
+	}
+	if prodCfg.ProvablyFair {
+		// A cycle spin plays its later steps ahead, before their nonces are drawn
+		if spinFlow == birdspartydeluxe.SpinFlowCycle {
+			log.Fatalf("The cycle spin flow cannot be used in provably-fair mode")
+		}
+		// Seeds live next to the sessions so they survive as long as the rounds do
+		birdsPartyDeluxeRoutes.Fairness = fairness.NewManager(sessionStore)
+	}
+	switch prodCfg.StateMode {
+	case "session":
+	case "token":
+		// Stateless deployments round-trip the game state with a signed token
+		keys, err := statetoken.ParseKeys(prodCfg.StateTokenKeys)
+		if err != nil {
+			log.Fatalf("Error parsing state token keys: %v", err)
+		}
+		signer, err := statetoken.NewSigner(keys)
+		if err != nil {
+			log.Fatalf("Error creating state token signer: %v", err)
+		}
+		signer.TTL = prodCfg.StateTokenTTL
+		birdsPartyDeluxeRoutes.StateTokens = signer
+		// The nonce and seed of each player's latest token stay in the session store; an instance
+		// that does not share it rejects the tokens of the others
+		if prodCfg.SessionStore == "memory" {
+			log.Printf("Warning: STATE_MODE=token keeps token records in a memory session store; run a single instance or share SESSION_STORE=file across instances")
+		}
+	default:
+		log.Fatalf("Unknown state mode: %s", prodCfg.StateMode)
+	}
+	if prodCfg.IdempotencyTTL > 0 {
+		birdsPartyDeluxeRoutes.Idempotency = idempotency.NewMemoryStore(prodCfg.IdempotencyTTL)
+	}
+	if prodCfg.WalletServiceURL != "" {
+		birdsPartyDeluxeRoutes.WalletProd = wallet.NewClient(prodCfg.WalletServiceURL)
+	}
+	if testCfg.WalletServiceURL != "" {
+		birdsPartyDeluxeRoutes.WalletTest = wallet.NewClient(testCfg.WalletServiceURL)
+	}
+	if prodCfg.AuditLogFile != "off" {
+		auditStore, err := audit.NewFileStore(prodCfg.AuditLogFile)
+		if err != nil {
+			log.Fatalf("Error opening audit log: %v", err)
+		}
+		defer auditStore.Close()
+		birdsPartyDeluxeRoutes.Audit = auditStore
+	}
+	birdsPartyDeluxeRoutes.AdminToken = prodCfg.AdminToken
 	birdsPartyDeluxeRoutes.Register(app)
+	// Operator endpoints go on their own listener when one is configured
+	if prodCfg.AdminAddr == "" {
+		birdsPartyDeluxeRoutes.RegisterAdmin(app)
+	} else {
+		adminApp := fiber.New(fiber.Config{
+			ErrorHandler: customErrorHandler,
+		})
+		adminApp.Use(recover.New())
+		birdsPartyDeluxeRoutes.RegisterAdmin(adminApp)
+		go func() {
+			log.Printf("Starting operator endpoints on %s", prodCfg.AdminAddr)
+			log.Fatal(adminApp.Listen(prodCfg.AdminAddr))
+		}()
+	}
 
 	// Add a simple status endpoint
 	app.Get("/status", func(c *fiber.Ctx) error {
@@ -69,6 +204,43 @@ func main() {
 	log.Fatal(app.Listen(":" + port))
 }
 
+// newOutcomeProvider creates the RNG provider selected by the configuration
+func newOutcomeProvider(cfg config.Config) (rng.OutcomeProvider, error) {
+	switch cfg.RNGProvider {
+	case "remote":
+		client := rng.NewClient(cfg.RNGServiceURL)
+		client.HTTPClient.Timeout = cfg.RNGTimeout
+		client.MaxRetries = cfg.RNGMaxRetries
+		if cfg.RNGSigningKeys != "" {
+			keys, err := rng.ParseKeys(cfg.RNGSigningKeys)
+			if err != nil {
+				return nil, err
+			}
+			if client.Signer, err = rng.NewSigner(keys); err != nil {
+				return nil, err
+			}
+		}
+		if cfg.RNGCAFile != "" || cfg.RNGCertFile != "" || cfg.RNGKeyFile != "" {
+			tlsConfig, err := rng.NewTLSConfig(cfg.RNGCAFile, cfg.RNGCertFile, cfg.RNGKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = tlsConfig
+			client.HTTPClient.Transport = transport
+		}
+		if client.Signer == nil && strings.HasPrefix(cfg.RNGServiceURL, "http://") {
+			log.Printf("⚠️  RNG service %s is called over plain HTTP without signing keys; its answers are not authenticated", cfg.RNGServiceURL)
+		}
+		return client, nil
+	case "local":
+		log.Printf("Using the local RNG provider; outcomes are not decided by the RNG service")
+		return rng.NewLocalProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown RNG provider: %s", cfg.RNGProvider)
+	}
+}
+
 // Custom error handler
 func customErrorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
@@ -83,4 +255,4 @@ func customErrorHandler(c *fiber.Ctx, err error) error {
 		"status":  "error",
 		"message": err.Error(),
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,127 @@
+// Command rngstub is a local stand-in for the RNG proxy.
+//
+//	rngstub -addr :17003 -win-ratio -1 -latency 50ms -error-rate 0.1
+//
+// It answers rng.Request with rng.Response on every path. By default a payout
+// of m times the bet wins with probability min(1, rtp/m), like the local
+// provider; -win-ratio forces a fixed share of wins instead (0 always loses,
+// 1 always wins). -target makes winning responses ask for a fixed multiple of the
+// bet instead of the requested payout.
+//
+// With -signing-keys it rejects requests whose signature does not verify and signs
+// its responses; -tamper flips pref_outcome after signing to test that clients
+// notice. -tls-cert and -tls-key serve HTTPS, and -client-ca requires client
+// certificates issued by that CA.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/faults"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+)
+
+func main() {
+	addr := flag.String("addr", ":17003", "listen address")
+	winRatio := flag.Float64("win-ratio", -1, "fraction of requests that win (0-1); negative decides from the request's RTP")
+	target := flag.Float64("target", 0, "win_amount of winning responses as a multiple of the bet; 0 returns the requested payout")
+	signingKeys := flag.String("signing-keys", "", "id:secret,... HMAC keys; verifies requests and signs responses when set")
+	tamper := flag.Bool("tamper", false, "flip pref_outcome after signing the response")
+	tlsCert := flag.String("tls-cert", "", "server certificate; serves HTTPS when set")
+	tlsKey := flag.String("tls-key", "", "server certificate key")
+	clientCA := flag.String("client-ca", "", "CA that must have issued client certificates (mutual TLS)")
+	var faultConfig faults.Config
+	faultConfig.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	provider := rng.NewLocalProvider()
+	var signer *rng.Signer
+	if *signingKeys != "" {
+		keys, err := rng.ParseKeys(*signingKeys)
+		if err != nil {
+			log.Fatalf("Invalid signing keys: %v", err)
+		}
+		if signer, err = rng.NewSigner(keys); err != nil {
+			log.Fatalf("Invalid signing keys: %v", err)
+		}
+	}
+
+	app := fiber.New()
+	app.Use(faults.New(faultConfig))
+	app.Post("/*", func(c *fiber.Ctx) error {
+		var req rng.Request
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body",
+			})
+		}
+		if signer != nil {
+			header := http.Header{}
+			for _, name := range []string{rng.HeaderKeyID, rng.HeaderTimestamp, rng.HeaderSignature} {
+				header.Set(name, c.Get(name))
+			}
+			if err := signer.VerifyRequest(header, req.RequestSalt, c.Body()); err != nil {
+				log.Printf("bet %s rejected: %v", req.BetID, err)
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Invalid signature",
+				})
+			}
+		}
+
+		var resp rng.Response
+		if *winRatio < 0 {
+			var err error
+			resp, err = provider.Send(c.UserContext(), req)
+			if err != nil {
+				return err
+			}
+		} else if rand.Float64() < *winRatio {
+			resp = rng.Response{PrefOutcome: "win", WinAmount: req.PayoutMultiplier * req.BetAmount, WinProb: *winRatio}
+		} else {
+			resp = rng.Response{PrefOutcome: "loss", WinProb: *winRatio}
+		}
+
+		if resp.PrefOutcome == "win" && *target > 0 {
+			resp.WinAmount = *target * req.BetAmount
+		}
+
+		log.Printf("bet %s player %s rtp %.2f x%.2f -> %s %.2f", req.BetID, req.PlayerID, req.RTP, req.PayoutMultiplier, resp.PrefOutcome, resp.WinAmount)
+		body, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		if signer != nil {
+			header := http.Header{}
+			signer.SignResponse(header, req.RequestSalt, body)
+			for name := range header {
+				c.Set(name, header.Get(name))
+			}
+		}
+		if *tamper {
+			resp.PrefOutcome = map[string]string{"win": "loss", "loss": "win"}[resp.PrefOutcome]
+			if body, err = json.Marshal(resp); err != nil {
+				return err
+			}
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(body)
+	})
+
+	log.Printf("RNG stub listening on %s", *addr)
+	switch {
+	case *tlsCert != "" && *clientCA != "":
+		log.Fatal(app.ListenMutualTLS(*addr, *tlsCert, *tlsKey, *clientCA))
+	case *tlsCert != "":
+		log.Fatal(app.ListenTLS(*addr, *tlsCert, *tlsKey))
+	default:
+		log.Fatal(app.Listen(*addr))
+	}
+}
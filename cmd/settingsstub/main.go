@@ -0,0 +1,97 @@
+// Command settingsstub is a local stand-in for the game settings service.
+//
+//	settingsstub -addr :17004 -rtp 0.96 -player-rtp p1=0.90,p2=0.97 -error-rate 0.1
+//
+// It answers settings.Request with settings.Response on every path.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/faults"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+)
+
+func main() {
+	addr := flag.String("addr", ":17004", "listen address")
+	rtp := flag.Float64("rtp", 0.96, "RTP returned for players without an override")
+	playerRTP := flag.String("player-rtp", "", "per-player RTP overrides as player=rtp,player=rtp")
+	bets := flag.String("bets", "0.1,0.2,0.3,0.5,1,2,2.5", "game_bets returned to every player")
+	wins := flag.String("wins", "5000", "game_wins returned to every player")
+	var faultConfig faults.Config
+	faultConfig.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	overrides, err := parsePlayerRTP(*playerRTP)
+	if err != nil {
+		log.Fatalf("Invalid -player-rtp: %v", err)
+	}
+
+	app := stub{rtp: *rtp, overrides: overrides, bets: *bets, wins: *wins}.newApp(faultConfig)
+
+	log.Printf("Settings stub listening on %s", *addr)
+	log.Fatal(app.Listen(*addr))
+}
+
+// stub holds the settings every player is given
+type stub struct {
+	rtp       float64
+	overrides map[string]float64 // RTP per player ID
+	bets      string
+	wins      string
+}
+
+// newApp serves the settings on every path behind the fault injection middleware
+func (s stub) newApp(faultConfig faults.Config) *fiber.App {
+	app := fiber.New()
+	app.Use(faults.New(faultConfig))
+	app.Post("/*", func(c *fiber.Ctx) error {
+		var req settings.Request
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body",
+			})
+		}
+
+		playerRTP, ok := s.overrides[req.PlayerID]
+		if !ok {
+			playerRTP = s.rtp
+		}
+
+		var resp settings.Response
+		resp.Data.GameBets = s.bets
+		resp.Data.GameRTP = strconv.FormatFloat(playerRTP, 'f', -1, 64)
+		resp.Data.GameWins = s.wins
+
+		log.Printf("client %s game %s player %s -> rtp %s", req.ClientID, req.GameID, req.PlayerID, resp.Data.GameRTP)
+		return c.JSON(resp)
+	})
+	return app
+}
+
+// parsePlayerRTP parses "player=rtp,player=rtp"
+func parsePlayerRTP(value string) (map[string]float64, error) {
+	overrides := make(map[string]float64)
+	if value == "" {
+		return overrides, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		player, rtp, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("expected player=rtp, got %q", pair)
+		}
+		parsed, err := strconv.ParseFloat(rtp, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RTP for %s: %w", player, err)
+		}
+		overrides[player] = parsed
+	}
+	return overrides, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/faults"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+)
+
+func TestMain(m *testing.M) {
+	// The stub and the client log every request
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// startStub serves the stub on a free port and returns a settings client of it that retries once
+func startStub(t *testing.T, s stub, faultConfig faults.Config) *settings.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := s.newApp(faultConfig)
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	client := settings.NewClient("http://" + ln.Addr().String() + "/get-game-settings")
+	client.HTTPClient = &http.Client{Timeout: 100 * time.Millisecond}
+	client.Backoff = settings.Backoff{MaxRetries: 1, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1}
+	return client
+}
+
+func TestStubAnswersTheSettingsClient(t *testing.T) {
+	client := startStub(t, stub{rtp: 0.96, overrides: map[string]float64{"p2": 0.9}, bets: "0.1,1,2", wins: "5000"}, faults.Config{})
+	tests := []struct {
+		player  string
+		wantRTP float64
+	}{
+		{"p1", 0.96},
+		{"p2", 0.9},
+	}
+	for _, tt := range tests {
+		got, err := client.GetGameSettings("c1", "birdspartydeluxe", tt.player)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.player, err)
+		}
+		want := settings.GameSettings{Bets: []float64{0.1, 1, 2}, RTP: tt.wantRTP, MaxWin: 5000}
+		if got.RTP != want.RTP || got.MaxWin != want.MaxWin || len(got.Bets) != len(want.Bets) {
+			t.Errorf("%s = %+v, want %+v", tt.player, got, want)
+		}
+	}
+}
+
+func TestStubInjectsFaults(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      faults.Config
+		wantStatus  int  // Status of the StatusError expected, 0 for none
+		wantTimeout bool // Whether the client gives up waiting
+		minDuration time.Duration
+	}{
+		{"latency under the client timeout", faults.Config{Latency: 40 * time.Millisecond}, 0, false, 40 * time.Millisecond},
+		// Both attempts wait out the client timeout
+		{"latency over the client timeout", faults.Config{Latency: 300 * time.Millisecond}, 0, true, 200 * time.Millisecond},
+		{"every request fails", faults.Config{ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}, http.StatusServiceUnavailable, false, 0},
+		{"every request is refused", faults.Config{ErrorRate: 1, ErrorStatus: http.StatusBadRequest}, http.StatusBadRequest, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := startStub(t, stub{rtp: 0.96, bets: "1", wins: "0"}, tt.config)
+			start := time.Now()
+			rtp, err := client.GetRTP("c1", "birdspartydeluxe", "p1")
+			if elapsed := time.Since(start); elapsed < tt.minDuration {
+				t.Errorf("call took %s, want at least %s", elapsed, tt.minDuration)
+			}
+			var status *settings.StatusError
+			var transport *settings.TransportError
+			switch {
+			case tt.wantStatus != 0:
+				if !errors.As(err, &status) || status.StatusCode != tt.wantStatus {
+					t.Errorf("GetRTP error = %v, want the injected status %d", err, tt.wantStatus)
+				}
+			case tt.wantTimeout:
+				if !errors.As(err, &transport) {
+					t.Errorf("GetRTP error = %v (%T), want a TransportError", err, err)
+				}
+			case err != nil || rtp != 0.96:
+				t.Errorf("GetRTP = %v, %v, want 0.96", rtp, err)
+			}
+		})
+	}
+}
+
+func TestParsePlayerRTP(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{"", map[string]float64{}, false},
+		{"p1=0.9, p2=0.97", map[string]float64{"p1": 0.9, "p2": 0.97}, false},
+		{"p1", nil, true},
+		{"p1=high", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePlayerRTP(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePlayerRTP(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parsePlayerRTP(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+		for player, rtp := range tt.want {
+			if got[player] != rtp {
+				t.Errorf("parsePlayerRTP(%q)[%s] = %v, want %v", tt.value, player, got[player], rtp)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Command simulate measures the math of the game by playing full game cycles through the
+// same engine as the handlers and printing RTP, hit frequency and volatility.
+//
+//	simulate -rounds 1000000 -rtp 0.96 -seed 42
+//
+// Rounds are split across independent player sessions that run in parallel on every CPU.
+// With -seed, every round seed and every local RNG decision derives from it, so the same
+// flags print the same report whatever the number of workers.
+//
+// With -profiles, every RTP profile of the math model is played at its own RTP and gets a
+// report of its own, to verify the profiles before they are shipped.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/games/birdspartydeluxe"
+)
+
+func main() {
+	rounds := flag.Int("rounds", 1000000, "game cycles to play, free spins included")
+	sessions := flag.Int("sessions", 100, "player sessions the rounds are split across; part of what a seed reproduces")
+	workers := flag.Int("workers", 0, "sessions played in parallel (0 for one per CPU)")
+	seed := flag.String("seed", "", "derive every round from this seed for a reproducible report")
+	rtp := flag.Float64("rtp", 0.96, "settings RTP passed to the outcome provider")
+	betAmount := flag.Float64("bet", birdspartydeluxe.BaseBetAmount, "bet amount")
+	spinFlow := flag.String("spin-flow", string(birdspartydeluxe.SpinFlowRNGFirst), "rng-first, legacy or cycle")
+	provider := flag.String("provider", "local", "local (RTP-driven, in-process), natural (every payout stands) or remote")
+	rngURL := flag.String("rng-url", "", "RNG service called by -provider remote")
+	randomSource := flag.String("random", "crypto", "random source: crypto or math")
+	mathModel := flag.String("math-model", "", "JSON or YAML math model to play (default: the built-in model)")
+	profiles := flag.Bool("profiles", false, "play every RTP profile of the math model at its own RTP instead of -rtp")
+	weightProfile := flag.String("weight-profile", "production", "weight profile of the math model, as the environment's WEIGHT_PROFILE")
+	tolerance := flag.Float64("tolerance", 0.02, "fail when the RTP is further than this from the settings RTP (0 disables the check)")
+	flag.Parse()
+
+	flow, err := birdspartydeluxe.ParseSpinFlow(*spinFlow)
+	if err != nil {
+		fatal(err)
+	}
+	randomFactory, err := random.NewFactory(*randomSource)
+	if err != nil {
+		fatal(err)
+	}
+	newProvider, err := providerFor(*provider, *rngURL)
+	if err != nil {
+		fatal(err)
+	}
+	model := birdspartydeluxe.DefaultMathModel()
+	if *mathModel != "" {
+		if model, err = birdspartydeluxe.LoadMathModel(*mathModel); err != nil {
+			fatal(err)
+		}
+	}
+	if err := model.CheckWeightProfile(*weightProfile, false); err != nil {
+		fatal(err)
+	}
+	if *seed != "" && *provider == "remote" {
+		fmt.Fprintln(os.Stderr, "Warning: the remote RNG service does not follow -seed, so the report is not reproducible")
+	}
+
+	rtps := []float64{*rtp}
+	if *profiles {
+		if len(model.Profiles) == 0 {
+			fatal(fmt.Errorf("math model %s has no profiles", model.ID()))
+		}
+		rtps = rtps[:0]
+		for _, profile := range model.Profiles {
+			rtps = append(rtps, profile.RTP)
+		}
+	}
+
+	// The engine logs every step; keep the report readable
+	log.SetOutput(io.Discard)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// With the natural provider the check tells whether the profile's own math returns its RTP;
+	// with the others, whether the RTP control delivers it
+	check := *tolerance > 0
+	var failures []string
+	for i, rtp := range rtps {
+		if i > 0 {
+			fmt.Println()
+		}
+		started := time.Now()
+		report, err := birdspartydeluxe.Simulate(ctx, birdspartydeluxe.SimulationConfig{
+			Rounds:        *rounds,
+			Sessions:      *sessions,
+			Workers:       *workers,
+			Seed:          *seed,
+			BetAmount:     *betAmount,
+			RTP:           rtp,
+			SpinFlow:      flow,
+			Model:         model,
+			WeightProfile: *weightProfile,
+			Random:        randomFactory,
+			Provider:      newProvider,
+		})
+		if err != nil {
+			fatal(fmt.Errorf("simulation failed: %w", err))
+		}
+		printReport(report, *betAmount)
+		fmt.Fprintf(os.Stderr, "Simulated %d rounds in %s\n", report.Rounds, time.Since(started).Round(time.Millisecond))
+		if check && math.Abs(report.RoundRTP-report.TargetRTP) > *tolerance {
+			failures = append(failures, fmt.Sprintf("RTP %.4f is not within %.4f of the settings RTP %.4f", report.RoundRTP, *tolerance, report.TargetRTP))
+		}
+		// A truncated round's win is incomplete, and so is every RTP of the report
+		if report.Truncated > 0 {
+			failures = append(failures, fmt.Sprintf("%d rounds were stopped after %d steps before they ended", report.Truncated, birdspartydeluxe.MaxSimulatedSteps))
+		}
+	}
+	if len(failures) > 0 {
+		for _, message := range failures {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", message)
+		}
+		os.Exit(1)
+	}
+}
+
+// providerFor returns the outcome provider of each round
+func providerFor(kind, rngURL string) (func(random.Source) rng.OutcomeProvider, error) {
+	switch kind {
+	case "local":
+		return func(r random.Source) rng.OutcomeProvider {
+			return &rng.LocalProvider{Float64: r.Float64}
+		}, nil
+	case "natural":
+		return func(random.Source) rng.OutcomeProvider { return naturalProvider{} }, nil
+	case "remote":
+		if rngURL == "" {
+			return nil, fmt.Errorf("-provider remote needs -rng-url")
+		}
+		client := rng.NewClient(rngURL)
+		return func(random.Source) rng.OutcomeProvider { return client }, nil
+	default:
+		return nil, fmt.Errorf("unknown outcome provider: %s", kind)
+	}
+}
+
+// naturalProvider lets every payout stand, so the grids' own math is measured.
+// With the legacy spin flow no win is steered towards a target.
+type naturalProvider struct{}
+
+func (naturalProvider) Send(ctx context.Context, req rng.Request) (rng.Response, error) {
+	return rng.Response{PrefOutcome: "win", WinAmount: req.PayoutMultiplier * req.BetAmount, WinProb: 1}, nil
+}
+
+func printReport(report birdspartydeluxe.SimulationReport, betAmount float64) {
+	fmt.Printf("Math model:             %s\n", report.MathModel)
+	if report.MathProfile != "" {
+		fmt.Printf("Profile:                %s\n", report.MathProfile)
+	}
+	if report.WeightProfile != "" {
+		fmt.Printf("Weight profile:         %s\n", report.WeightProfile)
+	}
+	fmt.Printf("Spin flow:              %s\n", report.SpinFlow)
+	fmt.Printf("Rounds:                 %d (%d paid, %.2f steps each)\n", report.Rounds, report.PaidRounds, report.StepsPerRound)
+	fmt.Printf("Wagered:                %.2f\n", report.Wagered)
+	fmt.Printf("Won:                    %.2f\n", report.RoundWin)
+	fmt.Printf("Settings RTP:           %.4f\n", report.TargetRTP)
+	fmt.Printf("RTP:                    %.4f (standard error %.4f)\n", report.RoundRTP, report.RTPStdErr)
+	fmt.Printf("Spin RTP:               %.4f\n", report.SpinRTP)
+	fmt.Printf("Cycle RTP:              %.4f\n", report.CycleRTP)
+	fmt.Printf("Hit frequency:          %.4f\n", report.HitFrequency)
+	fmt.Printf("Standard deviation:     %.4f bets\n", report.StdDev)
+	fmt.Printf("Free spin triggers:     %d (%.4f per paid round)\n", report.FreeSpinTriggers, report.FreeSpinTriggerRate)
+	fmt.Printf("Level advances:         %d (%.4f per round)\n", report.LevelAdvances, report.LevelAdvanceRate)
+	fmt.Printf("Target misses:          %d\n", report.TargetMisses)
+	fmt.Printf("RNG bypasses:           %d\n", report.RNGBypasses)
+	fmt.Printf("Truncated rounds:       %d\n", report.Truncated)
+
+	fmt.Println("Peak booming reels:")
+	multipliers := make([]float64, 0, len(report.BoomingReels))
+	for multiplier := range report.BoomingReels {
+		multipliers = append(multipliers, multiplier)
+	}
+	sort.Float64s(multipliers)
+	for _, multiplier := range multipliers {
+		count := report.BoomingReels[multiplier]
+		fmt.Printf("  %5.1fx  %10d  %.4f\n", multiplier, count, float64(count)/float64(report.Rounds))
+	}
+
+	fmt.Println("Max win by level:")
+	for _, level := range []birdspartydeluxe.Level{birdspartydeluxe.Level1, birdspartydeluxe.Level2, birdspartydeluxe.Level3} {
+		win := report.MaxWin[level]
+		fmt.Printf("  Level %d  %10.2f  (%.1fx bet)\n", level, win, win/betAmount)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
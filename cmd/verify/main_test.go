@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/fairness"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/games/birdspartydeluxe"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMain(m *testing.M) {
+	// The server logs every step
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// recordedRound plays a provably-fair round of several steps on a server writing an audit log,
+// then rotates the player's server seed. It returns the round's records and the revealed seed.
+func recordedRound(t *testing.T) ([]audit.Record, fairness.Reveal) {
+	t.Helper()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"game_bets":"1","game_rtp":"0.96","game_wins":""}}`)
+	}))
+	t.Cleanup(stub.Close)
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	store, err := audit.NewFileStore(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	sessions := session.NewMemoryStore()
+	provider := rng.NewLocalProvider()
+	settingsClient := settings.NewClient(stub.URL)
+	rg := birdspartydeluxe.NewRouteGroup(provider, settingsClient, provider, settingsClient, sessions)
+	rg.Fairness = fairness.NewManager(sessions)
+	rg.Audit = store
+	app := fiber.New()
+	rg.Register(app)
+
+	post := func(path string, body map[string]any) map[string]any {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var reply map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s = %d %v %v", path, resp.StatusCode, reply["message"], err)
+		}
+		return reply
+	}
+
+	for n := 1; n <= 100; n++ {
+		body := map[string]any{"client_id": "c1", "game_id": "birdspartydeluxe", "player_id": "p1", "bet_id": fmt.Sprintf("bet-%d", n), "bet_amount": 1.0}
+		reply := post("/spin/birdspartydeluxe", body)
+		roundID := reply["gameState"].(map[string]any)["roundId"].(string)
+		for {
+			var path string
+			switch birdspartydeluxe.RoundPhase(fmt.Sprint(reply["gameState"].(map[string]any)["phase"])) {
+			case birdspartydeluxe.PhaseAwaitingStageCleared:
+				path = "/process-stage-cleared/birdspartydeluxe"
+			case birdspartydeluxe.PhaseCascading:
+				path = "/cascade/birdspartydeluxe"
+			}
+			if path == "" {
+				break
+			}
+			delete(body, "bet_amount")
+			reply = post(path, body)
+		}
+
+		records, err := audit.ReadRound(auditPath, roundID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) < 2 {
+			continue
+		}
+		reveal := post("/fairness/birdspartydeluxe/rotate", map[string]any{"client_id": "c1", "game_id": "birdspartydeluxe", "player_id": "p1"})
+		var revealed fairness.Reveal
+		data, _ := json.Marshal(reveal["revealed"])
+		if err := json.Unmarshal(data, &revealed); err != nil {
+			t.Fatal(err)
+		}
+		return records, revealed
+	}
+	t.Fatal("no round with more than one step")
+	return nil, fairness.Reveal{}
+}
+
+func TestVerifyRound(t *testing.T) {
+	records, revealed := recordedRound(t)
+
+	tests := []struct {
+		name       string
+		serverSeed string
+		clientSeed string
+		edit       func(records []audit.Record)
+		want       bool
+		wantOutput string
+	}{
+		{"revealed seed", revealed.ServerSeed, "", nil, true, "OK: grid and win"},
+		{"revealed seed and client seed", revealed.ServerSeed, revealed.ClientSeed, nil, true, "OK: grid and win"},
+		{"other server seed", "not the seed", "", nil, false, "FAIL: step was committed to server seed hash " + revealed.ServerSeedHash},
+		{"other client seed", revealed.ServerSeed, "not the client seed", nil, false, "FAIL: step was played with client seed"},
+		{"edited grid", revealed.ServerSeed, "", func(records []audit.Record) {
+			last := records[len(records)-1]
+			last.OutputGrid[0][0] = "edited"
+		}, false, "FAIL: recomputed grid differs from the recorded grid"},
+		{"edited win", revealed.ServerSeed, "", func(records []audit.Record) {
+			records[0].Win += 100
+		}, false, "FAIL: recomputed win"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each case edits its own copy of the records
+			var copied []audit.Record
+			data, _ := json.Marshal(records)
+			if err := json.Unmarshal(data, &copied); err != nil {
+				t.Fatal(err)
+			}
+			if tt.edit != nil {
+				tt.edit(copied)
+			}
+
+			var out bytes.Buffer
+			if got := verifyRound(&out, "round", copied, tt.serverSeed, tt.clientSeed); got != tt.want {
+				t.Errorf("verifyRound = %v, want %v:\n%s", got, tt.want, out.String())
+			}
+			if !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("report does not say %q:\n%s", tt.wantOutput, out.String())
+			}
+			if tt.want && strings.Count(out.String(), "OK:") != len(records) {
+				t.Errorf("%d steps, report:\n%s", len(records), out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileStore appends records as JSON lines to a single file. It keeps the offset of
+// every record per round ID, so reading a round does not scan the file.
+type FileStore struct {
+	mu       sync.Mutex
+	file     *os.File
+	reader   *os.File
+	path     string
+	lastHash string
+	size     int64
+	index    map[string][]span // round ID -> records in the order they were written
+}
+
+// span locates one record line in the file
+type span struct {
+	offset int64
+	length int64
+}
+
+// NewFileStore opens (or creates) the JSON-lines file at path and resumes its hash chain
+func NewFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := os.Open(path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	store := &FileStore{file: file, reader: reader, path: path, index: make(map[string][]span)}
+
+	err = scanFile(path, func(r Record, line span) bool {
+		store.lastHash = r.Hash
+		store.index[r.RoundID] = append(store.index[r.RoundID], line)
+		store.size = line.offset + line.length
+		return true
+	})
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Append writes a record, chaining it to the previous one
+func (s *FileStore) Append(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.PrevHash = s.lastHash
+	record.Hash = ""
+	hash, err := hashRecord(record)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := s.file.Write(line); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.lastHash = hash
+	s.index[record.RoundID] = append(s.index[record.RoundID], span{offset: s.size, length: int64(len(line))})
+	s.size += int64(len(line))
+	return nil
+}
+
+// Round returns the records of a round in the order they were written.
+// Only the round's own lines are read, after the lock is released.
+func (s *FileStore) Round(roundID string) ([]Record, error) {
+	s.mu.Lock()
+	lines := append([]span(nil), s.index[roundID]...)
+	s.mu.Unlock()
+
+	records := make([]Record, 0, len(lines))
+	for _, line := range lines {
+		data := make([]byte, line.length)
+		if _, err := s.reader.ReadAt(data, line.offset); err != nil {
+			return nil, err
+		}
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Verify walks the whole file and checks the hash chain
+func (s *FileStore) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := ""
+	var chainErr error
+	err := scanFile(s.path, func(r Record, _ span) bool {
+		hash := r.Hash
+		r.Hash = ""
+		expected, err := hashRecord(r)
+		if err != nil || r.PrevHash != prev || hash != expected {
+			chainErr = errors.New("audit log hash chain broken at round " + r.RoundID)
+			return false
+		}
+		prev = hash
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return chainErr
+}
+
+// Close closes the underlying file
+func (s *FileStore) Close() error {
+	return errors.Join(s.file.Close(), s.reader.Close())
+}
+
+// ReadRound returns the records of a round from an audit log file without opening it for writing
+func ReadRound(path, roundID string) ([]Record, error) {
+	var records []Record
+	err := scanFile(path, func(r Record, _ span) bool {
+		if r.RoundID == roundID {
+			records = append(records, r)
+		}
+		return true
+	})
+	return records, err
+}
+
+// scanFile calls fn with every record of the file and where its line is, until fn returns false
+func scanFile(path string, fn func(Record, span) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var record Record
+			if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+				return jsonErr
+			}
+			if !fn(record, span{offset: offset, length: int64(len(line))}) {
+				return nil
+			}
+			offset += int64(len(line))
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func hashRecord(record Record) (string, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
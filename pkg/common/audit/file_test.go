@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStoreRound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []Record{
+		{RoundID: "r1", Step: 1},
+		{RoundID: "r2", Step: 1},
+		{RoundID: "r1", Step: 2},
+		{RoundID: "r1", Step: 3},
+	} {
+		if err := store.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(t *testing.T, store *FileStore) {
+		t.Helper()
+		tests := []struct {
+			roundID string
+			steps   []int
+		}{
+			{"r1", []int{1, 2, 3}},
+			{"r2", []int{1}},
+			{"unknown", nil},
+		}
+		for _, tt := range tests {
+			records, err := store.Round(tt.roundID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(tt.steps) {
+				t.Fatalf("round %s: %d records, want %d", tt.roundID, len(records), len(tt.steps))
+			}
+			for i, r := range records {
+				if r.RoundID != tt.roundID || r.Step != tt.steps[i] || r.Hash == "" {
+					t.Errorf("round %s record %d = %s step %d, want step %d", tt.roundID, i, r.RoundID, r.Step, tt.steps[i])
+				}
+			}
+		}
+	}
+	t.Run("open store", func(t *testing.T) { check(t, store) })
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	t.Run("reopened store", func(t *testing.T) { check(t, reopened) })
+
+	// Records appended after reopening are indexed after the existing ones
+	if err := reopened.Append(Record{RoundID: "r2", Step: 2}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := reopened.Round("r2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Step != 2 {
+		t.Errorf("round r2 after append = %+v, want steps 1 and 2", records)
+	}
+	if err := reopened.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestFileStoreVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(lines []string) []string
+		wantErr bool
+	}{
+		{"untouched log", func(lines []string) []string { return lines }, false},
+		{"edited record", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"win":2`, `"win":200`, 1)
+			return lines
+		}, true},
+		{"removed record", func(lines []string) []string { return append(lines[:1], lines[2:]...) }, true},
+		{"reordered records", func(lines []string) []string {
+			lines[0], lines[1] = lines[1], lines[0]
+			return lines
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			store, err := NewFileStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for step := 1; step <= 3; step++ {
+				if err := store.Append(Record{RoundID: "r1", Step: step, Win: float64(step)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			store.Close()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tt.tamper(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+			if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err = NewFileStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if err := store.Verify(); (err != nil) != tt.wantErr {
+				t.Errorf("Verify = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileStoreChainsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := 0; i < 2; i++ {
+		store, err := NewFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Append(Record{RoundID: "r1", Step: i + 1}); err != nil {
+			t.Fatal(err)
+		}
+		store.Close()
+	}
+
+	records, err := ReadRound(path, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].PrevHash != "" || records[1].PrevHash != records[0].Hash {
+		t.Errorf("records = %+v, want the second chained to the first", records)
+	}
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+)
+
+// Record is the immutable trace of one round step (spin, stage-cleared or cascade)
+type Record struct {
+	RoundID  string    `json:"round_id"`
+	ClientID string    `json:"client_id"`
+	GameID   string    `json:"game_id"`
+	PlayerID string    `json:"player_id"`
+	BetID    string    `json:"bet_id"`
+	Step     int       `json:"step"`
+	Action   string    `json:"action"`
+	Time     time.Time `json:"time"`
+	Seed     string    `json:"seed"` // Round seed; with the step it reproduces the step's grids
+
+	InputGrid   [][]string      `json:"input_grid"`
+	OutputGrid  [][]string      `json:"output_grid"`
+	Connections json.RawMessage `json:"connections"` // Paying connections with their payouts
+	Win         float64         `json:"win"`
+
+	BoomingReelsLevelBefore int `json:"booming_reels_level_before"`
+	BoomingReelsLevelAfter  int `json:"booming_reels_level_after"`
+
+	RTP         float64       `json:"rtp,omitempty"` // Zero when the step did not need an outcome
+	RNGRequest  *rng.Request  `json:"rng_request,omitempty"`
+	RNGResponse *rng.Response `json:"rng_response,omitempty"`
+	RNGBypassed bool          `json:"rng_bypassed"`
+
+	MathModel     string `json:"math_model"`               // ID of the math model the round was played with: version@content hash
+	MathProfile   string `json:"math_profile,omitempty"`   // RTP profile of the model chosen by the settings RTP
+	WeightProfile string `json:"weight_profile,omitempty"` // Weight profile of the environment the round was played in
+
+	// FailurePolicy names the policy that decided the step because settings or RNG were unavailable:
+	// "degrade" (local outcome provider) or "auto-loss" (round completed as a loss)
+	FailurePolicy string `json:"failure_policy,omitempty"`
+
+	StateBefore json.RawMessage `json:"state_before"`
+	StateAfter  json.RawMessage `json:"state_after"`
+
+	// PrevHash and Hash chain the records of a store so any edit or deletion is detectable
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// Store appends audit records and reads them back per round.
+// Stores never update or delete records.
+type Store interface {
+	Append(record Record) error
+	Round(roundID string) ([]Record, error)
+}
@@ -1,18 +1,79 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Profiles choose the default service URLs. Variables that are set always win over the profile.
+const (
+	ProfileRemote = "remote" // The hosted RNG proxy and settings service
+	ProfileLocal  = "local"  // cmd/rngstub and cmd/settingsstub on their default ports
+)
+
+// Service URLs of the local profile, where the stubs listen by default
+const (
+	LocalRNGServiceURL      = "http://localhost:17003/api/proxy/rng/1"
+	LocalSettingsServiceURL = "http://localhost:17004/get-game-settings"
+)
+
 // Config holds all configuration from environment
 type Config struct {
-	RNGServiceURL      string
-	SettingsServiceURL string
-	ServerPort         string
-	LogFile            string
+	Profile                string // "remote" or "local", the defaults the service URLs were chosen from
+	RNGServiceURL          string
+	SettingsServiceURL     string
+	WalletServiceURL       string // Operator seamless-wallet API; empty leaves money movement to the operator
+	ServerPort             string
+	LogFile                string
+	SessionStore           string        // "memory" or "file"
+	SessionDir             string        // Directory used by the file session store
+	StateMode              string        // "session" (server-side) or "token" (client round-trip with signed token)
+	StateTokenKeys         string        // "id:secret,id:secret" HMAC keys for state tokens, signing key first
+	StateTokenTTL          time.Duration // How long a state token is accepted after it was issued; 0 disables expiry
+	IdempotencyTTL         time.Duration
+	RandomSource           string        // "crypto" (ChaCha8 seeded from crypto/rand) or "math" (math/rand)
+	ProvablyFair           bool          // Derive every step from the player's committed server seed, client seed and nonce
+	AuditLogFile           string        // Append-only JSONL audit log of round steps; "off" disables it
+	AdminToken             string        // Bearer token of the operator endpoints (round replay, settings invalidation); empty disables them
+	AdminAddr              string        // Separate listen address of the operator endpoints, e.g. "127.0.0.1:11401"; empty serves them on PORT
+	SettingsCacheTTL       time.Duration // How long settings are served from cache; 0 disables the cache
+	SettingsCacheStale     time.Duration // How much longer expired settings are served while being refreshed
+	SettingsMaxRetries     int           // Retries after a settings call failed with a transport error, 5xx or 429
+	SettingsBackoffInitial time.Duration // Wait before the first settings retry
+	SettingsBackoffMax     time.Duration // Longest wait between settings retries
+	RNGProvider            string        // "remote" (RNG service) or "local" (in-process, RTP / payout multiplier)
+	SpinFlow               string        // "rng-first" (ask the RNG, then build the grid), "legacy" (build, then overwrite) or "cycle" (one decision per round)
+	RNGTimeout             time.Duration // Per-attempt timeout of RNG calls
+	RNGMaxRetries          int           // Retries after a failed RNG attempt
+	FailurePolicy          string        // "fail-closed", "degrade" or "auto-loss" when settings or RNG cannot decide a step
+	RNGSigningKeys         string        // "id:secret,id:secret" HMAC keys shared with the RNG service, signing key first
+	RNGCAFile              string        // PEM CA bundle trusted for the RNG service instead of the system roots
+	RNGCertFile            string        // PEM client certificate for mutual TLS with the RNG service
+	RNGKeyFile             string        // PEM key of the client certificate
+	MathModelFile          string        // JSON math model of new rounds; empty plays the built-in model
+	OperatorMathModels     string        // "client_id:path,client_id:path" math models chosen per operator
+	WeightProfile          string        // Weight profile of the math models; production refuses one marked test-only
+}
+
+// String renders the configuration for logging with secrets redacted
+func (c Config) String() string {
+	type plain Config
+	redacted := plain(c)
+	if redacted.StateTokenKeys != "" {
+		redacted.StateTokenKeys = "[redacted]"
+	}
+	if redacted.RNGSigningKeys != "" {
+		redacted.RNGSigningKeys = "[redacted]"
+	}
+	if redacted.AdminToken != "" {
+		redacted.AdminToken = "[redacted]"
+	}
+	return fmt.Sprintf("%+v", redacted)
 }
 
 // Load loads configuration from environment variables
@@ -22,11 +83,47 @@ func Load() Config {
 		log.Println("No .env file found or error loading it")
 	}
 
+	profile := getProfile()
+	rngURL, settingsURL := "http://159.89.235.166:17003/api/proxy/rng/1", "https://t3.ibibe.africa/get-game-settings"
+	if profile == ProfileLocal {
+		rngURL, settingsURL = LocalRNGServiceURL, LocalSettingsServiceURL
+	}
+
 	return Config{
-		RNGServiceURL:      getEnv("RNG_API_URL", "http://159.89.235.166:17003/api/proxy/rng/1"),
-		SettingsServiceURL: getEnv("SETTINGS_API_URL", "https://t3.ibibe.africa/get-game-settings"),
-		ServerPort:         getEnv("PORT", "11400"),
-		LogFile:            getEnv("LOG_FILE", "app.log"),
+		Profile:                profile,
+		RNGServiceURL:          getEnv("RNG_API_URL", rngURL),
+		SettingsServiceURL:     getEnv("SETTINGS_API_URL", settingsURL),
+		WalletServiceURL:       getEnv("WALLET_API_URL", ""),
+		ServerPort:             getEnv("PORT", "11400"),
+		LogFile:                getEnv("LOG_FILE", "app.log"),
+		SessionStore:           getEnv("SESSION_STORE", "memory"),
+		SessionDir:             getEnv("SESSION_DIR", "sessions"),
+		StateMode:              getEnv("STATE_MODE", "session"),
+		StateTokenKeys:         getEnv("STATE_TOKEN_KEYS", ""),
+		StateTokenTTL:          getEnvDuration("STATE_TOKEN_TTL", 24*time.Hour),
+		IdempotencyTTL:         getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		RandomSource:           getEnv("RANDOM_SOURCE", "crypto"),
+		ProvablyFair:           getEnv("PROVABLY_FAIR", "false") == "true",
+		AuditLogFile:           getEnv("AUDIT_LOG_FILE", "audit.jsonl"),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+		AdminAddr:              getEnv("ADMIN_ADDR", ""),
+		SettingsCacheTTL:       getEnvDuration("SETTINGS_CACHE_TTL", 30*time.Second),
+		SettingsCacheStale:     getEnvDuration("SETTINGS_CACHE_STALE", 5*time.Minute),
+		SettingsMaxRetries:     getEnvInt("SETTINGS_MAX_RETRIES", 3),
+		SettingsBackoffInitial: getEnvDuration("SETTINGS_BACKOFF_INITIAL", 500*time.Millisecond),
+		SettingsBackoffMax:     getEnvDuration("SETTINGS_BACKOFF_MAX", 5*time.Second),
+		RNGProvider:            getEnv("RNG_PROVIDER", "remote"),
+		SpinFlow:               getEnv("SPIN_FLOW", "rng-first"),
+		RNGTimeout:             getEnvDuration("RNG_TIMEOUT", 5*time.Second),
+		RNGMaxRetries:          getEnvInt("RNG_MAX_RETRIES", 2),
+		FailurePolicy:          getEnv("FAILURE_POLICY", "fail-closed"),
+		RNGSigningKeys:         getEnv("RNG_SIGNING_KEYS", ""),
+		RNGCAFile:              getEnv("RNG_CA_FILE", ""),
+		RNGCertFile:            getEnv("RNG_CERT_FILE", ""),
+		RNGKeyFile:             getEnv("RNG_KEY_FILE", ""),
+		MathModelFile:          getEnv("MATH_MODEL_FILE", ""),
+		OperatorMathModels:     getEnv("MATH_MODEL_OPERATORS", ""),
+		WeightProfile:          getEnv("WEIGHT_PROFILE", "production"),
 	}
 }
 
@@ -39,6 +136,44 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getProfile reads PROFILE, falling back to the remote profile when unset or unknown
+func getProfile() string {
+	profile := getEnv("PROFILE", ProfileRemote)
+	if profile != ProfileRemote && profile != ProfileLocal {
+		log.Printf("Unknown PROFILE %q, using %s", profile, ProfileRemote)
+		return ProfileRemote
+	}
+	return profile
+}
+
+// getEnvDuration reads a duration such as "30s" or "24h", falling back to the default when unset or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s: %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvInt reads a non-negative integer, falling back to the default when unset or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid integer for %s: %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
 // LoadAll loads both production and test configurations from environment variables
 func LoadAll() (prod Config, test Config) {
 	// Try to load .env file, but don't fail if it doesn't exist
@@ -46,17 +181,86 @@ func LoadAll() (prod Config, test Config) {
 		log.Println("No .env file found or error loading it")
 	}
 
+	profile := getProfile()
+	prodRNGURL, prodSettingsURL := "http://159.89.235.166:17003/api/proxy/rng/1", "https://t3.ibibe.africa/get-game-settings"
+	testRNGURL, testSettingsURL := "http://test-rng-url", "https://test-settings-url"
+	if profile == ProfileLocal {
+		// Both environments talk to the same stubs
+		prodRNGURL, prodSettingsURL = LocalRNGServiceURL, LocalSettingsServiceURL
+		testRNGURL, testSettingsURL = LocalRNGServiceURL, LocalSettingsServiceURL
+	}
+
 	prod = Config{
-		RNGServiceURL:      getEnv("PROD_RNG_API_URL", "http://159.89.235.166:17003/api/proxy/rng/1"),
-		SettingsServiceURL: getEnv("PROD_SETTINGS_API_URL", "https://t3.ibibe.africa/get-game-settings"),
-		ServerPort:         getEnv("PORT", "11400"),
-		LogFile:            getEnv("LOG_FILE", "app.log"),
+		Profile:                profile,
+		RNGServiceURL:          getEnv("PROD_RNG_API_URL", prodRNGURL),
+		SettingsServiceURL:     getEnv("PROD_SETTINGS_API_URL", prodSettingsURL),
+		WalletServiceURL:       getEnv("PROD_WALLET_API_URL", ""),
+		ServerPort:             getEnv("PORT", "11400"),
+		LogFile:                getEnv("LOG_FILE", "app.log"),
+		SessionStore:           getEnv("SESSION_STORE", "memory"),
+		SessionDir:             getEnv("SESSION_DIR", "sessions"),
+		StateMode:              getEnv("STATE_MODE", "session"),
+		StateTokenKeys:         getEnv("STATE_TOKEN_KEYS", ""),
+		StateTokenTTL:          getEnvDuration("STATE_TOKEN_TTL", 24*time.Hour),
+		IdempotencyTTL:         getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		RandomSource:           getEnv("RANDOM_SOURCE", "crypto"),
+		ProvablyFair:           getEnv("PROVABLY_FAIR", "false") == "true",
+		AuditLogFile:           getEnv("AUDIT_LOG_FILE", "audit.jsonl"),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+		AdminAddr:              getEnv("ADMIN_ADDR", ""),
+		SettingsCacheTTL:       getEnvDuration("SETTINGS_CACHE_TTL", 30*time.Second),
+		SettingsCacheStale:     getEnvDuration("SETTINGS_CACHE_STALE", 5*time.Minute),
+		SettingsMaxRetries:     getEnvInt("SETTINGS_MAX_RETRIES", 3),
+		SettingsBackoffInitial: getEnvDuration("SETTINGS_BACKOFF_INITIAL", 500*time.Millisecond),
+		SettingsBackoffMax:     getEnvDuration("SETTINGS_BACKOFF_MAX", 5*time.Second),
+		RNGProvider:            getEnv("RNG_PROVIDER", "remote"),
+		SpinFlow:               getEnv("SPIN_FLOW", "rng-first"),
+		RNGTimeout:             getEnvDuration("RNG_TIMEOUT", 5*time.Second),
+		RNGMaxRetries:          getEnvInt("RNG_MAX_RETRIES", 2),
+		FailurePolicy:          getEnv("PROD_FAILURE_POLICY", "fail-closed"),
+		RNGSigningKeys:         getEnv("PROD_RNG_SIGNING_KEYS", ""),
+		RNGCAFile:              getEnv("PROD_RNG_CA_FILE", ""),
+		RNGCertFile:            getEnv("PROD_RNG_CERT_FILE", ""),
+		RNGKeyFile:             getEnv("PROD_RNG_KEY_FILE", ""),
+		MathModelFile:          getEnv("MATH_MODEL_FILE", ""),
+		OperatorMathModels:     getEnv("MATH_MODEL_OPERATORS", ""),
+		WeightProfile:          getEnv("PROD_WEIGHT_PROFILE", "production"),
 	}
 	test = Config{
-		RNGServiceURL:      getEnv("TEST_RNG_API_URL", "http://test-rng-url"),
-		SettingsServiceURL: getEnv("TEST_SETTINGS_API_URL", "https://test-settings-url"),
-		ServerPort:         getEnv("PORT", "11400"),
-		LogFile:            getEnv("LOG_FILE", "app.log"),
+		Profile:                profile,
+		RNGServiceURL:          getEnv("TEST_RNG_API_URL", testRNGURL),
+		SettingsServiceURL:     getEnv("TEST_SETTINGS_API_URL", testSettingsURL),
+		WalletServiceURL:       getEnv("TEST_WALLET_API_URL", ""),
+		ServerPort:             getEnv("PORT", "11400"),
+		LogFile:                getEnv("LOG_FILE", "app.log"),
+		SessionStore:           getEnv("SESSION_STORE", "memory"),
+		SessionDir:             getEnv("SESSION_DIR", "sessions"),
+		StateMode:              getEnv("STATE_MODE", "session"),
+		StateTokenKeys:         getEnv("STATE_TOKEN_KEYS", ""),
+		StateTokenTTL:          getEnvDuration("STATE_TOKEN_TTL", 24*time.Hour),
+		IdempotencyTTL:         getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		RandomSource:           getEnv("RANDOM_SOURCE", "crypto"),
+		ProvablyFair:           getEnv("PROVABLY_FAIR", "false") == "true",
+		AuditLogFile:           getEnv("AUDIT_LOG_FILE", "audit.jsonl"),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+		AdminAddr:              getEnv("ADMIN_ADDR", ""),
+		SettingsCacheTTL:       getEnvDuration("SETTINGS_CACHE_TTL", 30*time.Second),
+		SettingsCacheStale:     getEnvDuration("SETTINGS_CACHE_STALE", 5*time.Minute),
+		SettingsMaxRetries:     getEnvInt("SETTINGS_MAX_RETRIES", 3),
+		SettingsBackoffInitial: getEnvDuration("SETTINGS_BACKOFF_INITIAL", 500*time.Millisecond),
+		SettingsBackoffMax:     getEnvDuration("SETTINGS_BACKOFF_MAX", 5*time.Second),
+		RNGProvider:            getEnv("RNG_PROVIDER", "remote"),
+		SpinFlow:               getEnv("SPIN_FLOW", "rng-first"),
+		RNGTimeout:             getEnvDuration("RNG_TIMEOUT", 5*time.Second),
+		RNGMaxRetries:          getEnvInt("RNG_MAX_RETRIES", 2),
+		FailurePolicy:          getEnv("TEST_FAILURE_POLICY", "fail-closed"),
+		RNGSigningKeys:         getEnv("TEST_RNG_SIGNING_KEYS", ""),
+		RNGCAFile:              getEnv("TEST_RNG_CA_FILE", ""),
+		RNGCertFile:            getEnv("TEST_RNG_CERT_FILE", ""),
+		RNGKeyFile:             getEnv("TEST_RNG_KEY_FILE", ""),
+		MathModelFile:          getEnv("MATH_MODEL_FILE", ""),
+		OperatorMathModels:     getEnv("MATH_MODEL_OPERATORS", ""),
+		WeightProfile:          getEnv("TEST_WEIGHT_PROFILE", "test"),
 	}
 	return
 }
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Loading logs the missing .env file
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestLoadAllProfile(t *testing.T) {
+	const (
+		remoteRNG      = "http://159.89.235.166:17003/api/proxy/rng/1"
+		remoteSettings = "https://t3.ibibe.africa/get-game-settings"
+	)
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantProfile string
+		wantProd    [2]string // RNG and settings URLs
+		wantTest    [2]string
+	}{
+		{"remote by default", nil, ProfileRemote,
+			[2]string{remoteRNG, remoteSettings}, [2]string{"http://test-rng-url", "https://test-settings-url"}},
+		{"local stubs", map[string]string{"PROFILE": "local"}, ProfileLocal,
+			[2]string{LocalRNGServiceURL, LocalSettingsServiceURL}, [2]string{LocalRNGServiceURL, LocalSettingsServiceURL}},
+		{"variables win over the profile", map[string]string{"PROFILE": "local", "PROD_RNG_API_URL": "http://rng.example"}, ProfileLocal,
+			[2]string{"http://rng.example", LocalSettingsServiceURL}, [2]string{LocalRNGServiceURL, LocalSettingsServiceURL}},
+		{"unknown profile", map[string]string{"PROFILE": "staging"}, ProfileRemote,
+			[2]string{remoteRNG, remoteSettings}, [2]string{"http://test-rng-url", "https://test-settings-url"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// An empty variable reads as unset
+			for _, key := range []string{"PROFILE", "PROD_RNG_API_URL", "PROD_SETTINGS_API_URL", "TEST_RNG_API_URL", "TEST_SETTINGS_API_URL"} {
+				t.Setenv(key, tt.env[key])
+			}
+			prod, test := LoadAll()
+			if prod.Profile != tt.wantProfile || test.Profile != tt.wantProfile {
+				t.Errorf("profiles = %q and %q, want %q", prod.Profile, test.Profile, tt.wantProfile)
+			}
+			if got := [2]string{prod.RNGServiceURL, prod.SettingsServiceURL}; got != tt.wantProd {
+				t.Errorf("production URLs = %q, want %q", got, tt.wantProd)
+			}
+			if got := [2]string{test.RNGServiceURL, test.SettingsServiceURL}; got != tt.wantTest {
+				t.Errorf("test URLs = %q, want %q", got, tt.wantTest)
+			}
+		})
+	}
+}
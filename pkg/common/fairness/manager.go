@@ -0,0 +1,250 @@
+package fairness
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+)
+
+var (
+	// ErrUnknownSeed is returned for a server seed hash that was never committed to the player
+	ErrUnknownSeed = errors.New("unknown server seed")
+	// ErrSeedActive is returned when revealing a server seed that is still in play
+	ErrSeedActive = errors.New("server seed is still in use, rotate it first")
+)
+
+// Commitment is what a player sees before playing: the server seed stays secret behind its hash
+type Commitment struct {
+	ServerSeedHash string `json:"serverSeedHash"`
+	ClientSeed     string `json:"clientSeed"`
+	Nonce          int    `json:"nonce"` // Nonce of the next step
+}
+
+// Reveal is a retired server seed with the client seed it was played with
+type Reveal struct {
+	ServerSeed     string `json:"serverSeed"`
+	ServerSeedHash string `json:"serverSeedHash"`
+	ClientSeed     string `json:"clientSeed"`
+	Nonces         int    `json:"nonces"` // Nonces 0 to Nonces-1 were played with the seed
+}
+
+// seedRecord is the stored state of one server seed
+type seedRecord struct {
+	Owner      string `json:"owner"`
+	ServerSeed string `json:"serverSeed"`
+	ClientSeed string `json:"clientSeed"`
+	Nonce      int    `json:"nonce"` // Next unused nonce
+	Retired    bool   `json:"retired"`
+}
+
+// Manager keeps each player's seed pair and nonce. Every round step takes the next
+// nonce, so a step's grids derive from HMAC(serverSeed, clientSeed:nonce:cursor).
+type Manager struct {
+	store session.Store
+	mu    sync.Mutex
+}
+
+// NewManager creates a manager persisting its seeds in store
+func NewManager(store session.Store) *Manager {
+	return &Manager{store: store}
+}
+
+// HashServerSeed returns the published commitment of a server seed
+func HashServerSeed(serverSeed string) string {
+	sum := sha256.Sum256([]byte(serverSeed))
+	return hex.EncodeToString(sum[:])
+}
+
+// FormatSeed builds the step seed recorded with the round
+func FormatSeed(serverSeedHash string, nonce int, clientSeed string) string {
+	return serverSeedHash + ":" + strconv.Itoa(nonce) + ":" + clientSeed
+}
+
+// ParseSeed splits a step seed built by FormatSeed
+func ParseSeed(seed string) (serverSeedHash string, nonce int, clientSeed string, err error) {
+	parts := strings.SplitN(seed, ":", 3)
+	if len(parts) != 3 {
+		return "", 0, "", fmt.Errorf("invalid provably-fair seed %q", seed)
+	}
+	nonce, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, "", fmt.Errorf("invalid nonce in seed %q", seed)
+	}
+	return parts[0], nonce, parts[2], nil
+}
+
+// Commitment returns the player's current seed pair, committing to a new server seed on first use
+func (m *Manager) Commitment(owner string) (Commitment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, record, err := m.current(owner)
+	if err != nil {
+		return Commitment{}, err
+	}
+	return Commitment{ServerSeedHash: hash, ClientSeed: record.ClientSeed, Nonce: record.Nonce}, nil
+}
+
+// NextSeed reserves the player's next nonce and returns the seed of the step that uses it
+func (m *Manager) NextSeed(owner string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, record, err := m.current(owner)
+	if err != nil {
+		return "", err
+	}
+	seed := FormatSeed(hash, record.Nonce, record.ClientSeed)
+	record.Nonce++
+	if err := m.saveRecord(hash, record); err != nil {
+		return "", err
+	}
+	return seed, nil
+}
+
+// Source rebuilds the stream of a step seed returned by NextSeed
+func (m *Manager) Source(seed string) (random.Source, error) {
+	hash, nonce, clientSeed, err := ParseSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	record, err := m.loadRecord(hash)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(record.ServerSeed, clientSeed, nonce), nil
+}
+
+// Rotate retires the player's server seed, revealing it, and commits to a new one
+// played with clientSeed (a random client seed when empty)
+func (m *Manager) Rotate(owner, clientSeed string) (Reveal, Commitment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, record, err := m.current(owner)
+	if err != nil {
+		return Reveal{}, Commitment{}, err
+	}
+	record.Retired = true
+	if err := m.saveRecord(hash, record); err != nil {
+		return Reveal{}, Commitment{}, err
+	}
+
+	nextHash, next, err := m.commit(owner, clientSeed)
+	if err != nil {
+		return Reveal{}, Commitment{}, err
+	}
+	return revealOf(hash, record), Commitment{ServerSeedHash: nextHash, ClientSeed: next.ClientSeed, Nonce: next.Nonce}, nil
+}
+
+// Reveal returns a retired server seed of the player
+func (m *Manager) Reveal(owner, serverSeedHash string) (Reveal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, err := m.loadRecord(serverSeedHash)
+	if err != nil {
+		return Reveal{}, err
+	}
+	if record.Owner != owner {
+		return Reveal{}, ErrUnknownSeed
+	}
+	if !record.Retired {
+		return Reveal{}, ErrSeedActive
+	}
+	return revealOf(serverSeedHash, record), nil
+}
+
+func revealOf(hash string, record seedRecord) Reveal {
+	return Reveal{
+		ServerSeed:     record.ServerSeed,
+		ServerSeedHash: hash,
+		ClientSeed:     record.ClientSeed,
+		Nonces:         record.Nonce,
+	}
+}
+
+// current loads the player's active seed, committing to one if the player has none
+func (m *Manager) current(owner string) (string, seedRecord, error) {
+	data, err := m.store.Load(playerKey(owner))
+	if errors.Is(err, session.ErrNotFound) {
+		return m.commit(owner, "")
+	}
+	if err != nil {
+		return "", seedRecord{}, err
+	}
+	hash := string(data)
+	record, err := m.loadRecord(hash)
+	return hash, record, err
+}
+
+// commit draws a new server seed and makes it the player's active seed
+func (m *Manager) commit(owner, clientSeed string) (string, seedRecord, error) {
+	serverSeed, err := randomHex(32)
+	if err != nil {
+		return "", seedRecord{}, err
+	}
+	if clientSeed == "" {
+		if clientSeed, err = randomHex(16); err != nil {
+			return "", seedRecord{}, err
+		}
+	}
+
+	hash := HashServerSeed(serverSeed)
+	record := seedRecord{Owner: owner, ServerSeed: serverSeed, ClientSeed: clientSeed}
+	if err := m.saveRecord(hash, record); err != nil {
+		return "", seedRecord{}, err
+	}
+	if err := m.store.Save(playerKey(owner), []byte(hash)); err != nil {
+		return "", seedRecord{}, err
+	}
+	return hash, record, nil
+}
+
+func (m *Manager) loadRecord(hash string) (seedRecord, error) {
+	data, err := m.store.Load(seedKey(hash))
+	if errors.Is(err, session.ErrNotFound) {
+		return seedRecord{}, ErrUnknownSeed
+	}
+	if err != nil {
+		return seedRecord{}, err
+	}
+	var record seedRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return seedRecord{}, err
+	}
+	return record, nil
+}
+
+func (m *Manager) saveRecord(hash string, record seedRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return m.store.Save(seedKey(hash), data)
+}
+
+func playerKey(owner string) string {
+	return "fairness/player/" + owner
+}
+
+func seedKey(hash string) string {
+	return "fairness/seed/" + hash
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to draw seed: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,117 @@
+package fairness
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+)
+
+func TestRotateRevealsCommittedSeed(t *testing.T) {
+	m := NewManager(session.NewMemoryStore())
+	committed, err := m.Commitment("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := m.Commitment("p1"); again != committed {
+		t.Errorf("second commitment %+v, want %+v", again, committed)
+	}
+
+	var seeds []string
+	for nonce := 0; nonce < 3; nonce++ {
+		seed, err := m.NextSeed("p1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := FormatSeed(committed.ServerSeedHash, nonce, committed.ClientSeed); seed != want {
+			t.Errorf("step seed %q, want %q", seed, want)
+		}
+		seeds = append(seeds, seed)
+	}
+
+	reveal, next, err := m.Rotate("p1", "my-client-seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The revealed seed is the one the player was shown the hash of before playing
+	if HashServerSeed(reveal.ServerSeed) != committed.ServerSeedHash || reveal.ServerSeedHash != committed.ServerSeedHash {
+		t.Errorf("revealed seed hashes to %s, committed %s", HashServerSeed(reveal.ServerSeed), committed.ServerSeedHash)
+	}
+	if reveal.ClientSeed != committed.ClientSeed || reveal.Nonces != len(seeds) {
+		t.Errorf("reveal %+v, want client seed %s and %d nonces", reveal, committed.ClientSeed, len(seeds))
+	}
+	if next.ServerSeedHash == committed.ServerSeedHash || next.ClientSeed != "my-client-seed" || next.Nonce != 0 {
+		t.Errorf("next commitment %+v, want a new seed played with my-client-seed from nonce 0", next)
+	}
+
+	// Every step recomputes from the revealed seed
+	for nonce, seed := range seeds {
+		src, err := m.Source(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := NewSource(reveal.ServerSeed, reveal.ClientSeed, nonce)
+		for i := 0; i < 10; i++ {
+			if got, wantValue := src.Float64(), want.Float64(); got != wantValue {
+				t.Fatalf("nonce %d value %d: %v, revealed seed gives %v", nonce, i, got, wantValue)
+			}
+		}
+	}
+
+	if got, err := m.Reveal("p1", committed.ServerSeedHash); err != nil || got != reveal {
+		t.Errorf("Reveal = %+v, %v, want %+v", got, err, reveal)
+	}
+}
+
+func TestReveal(t *testing.T) {
+	m := NewManager(session.NewMemoryStore())
+	retired, err := m.Commitment("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Rotate("p1", ""); err != nil {
+		t.Fatal(err)
+	}
+	active, err := m.Commitment("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		owner   string
+		hash    string
+		wantErr error
+	}{
+		{"retired seed", "p1", retired.ServerSeedHash, nil},
+		{"seed still in play", "p1", active.ServerSeedHash, ErrSeedActive},
+		{"seed of another player", "p2", retired.ServerSeedHash, ErrUnknownSeed},
+		{"seed never committed", "p1", HashServerSeed("unknown"), ErrUnknownSeed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reveal, err := m.Reveal(tt.owner, tt.hash)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reveal = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && HashServerSeed(reveal.ServerSeed) != tt.hash {
+				t.Errorf("revealed seed does not hash to %s", tt.hash)
+			}
+		})
+	}
+}
+
+func TestRotateDrawsClientSeed(t *testing.T) {
+	m := NewManager(session.NewMemoryStore())
+	_, first, err := m.Rotate("p1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := m.Rotate("p1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ClientSeed == "" || first.ClientSeed == second.ClientSeed {
+		t.Errorf("client seeds %q and %q, want two random seeds", first.ClientSeed, second.ClientSeed)
+	}
+}
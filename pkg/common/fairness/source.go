@@ -0,0 +1,60 @@
+package fairness
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+)
+
+// valuesPerBlock is how many 8-byte values one HMAC-SHA256 block yields
+const valuesPerBlock = sha256.Size / 8
+
+// Source draws from the provably-fair stream HMAC-SHA256(serverSeed, "clientSeed:nonce:cursor"),
+// where cursor counts the 32-byte blocks consumed so far. Every value uses the next 8 bytes.
+// It satisfies random.Source, so the grid generation and gravity fills can draw from it directly.
+type Source struct {
+	serverSeed []byte
+	clientSeed string
+	nonce      int
+	cursor     int
+	block      [sha256.Size]byte
+	used       int // Values already taken from block
+}
+
+// NewSource creates the stream of one nonce
+func NewSource(serverSeed, clientSeed string, nonce int) *Source {
+	return &Source{
+		serverSeed: []byte(serverSeed),
+		clientSeed: clientSeed,
+		nonce:      nonce,
+		used:       valuesPerBlock,
+	}
+}
+
+// next returns the next 64 bits of the stream
+func (s *Source) next() uint64 {
+	if s.used == valuesPerBlock {
+		mac := hmac.New(sha256.New, s.serverSeed)
+		mac.Write([]byte(s.clientSeed + ":" + strconv.Itoa(s.nonce) + ":" + strconv.Itoa(s.cursor)))
+		copy(s.block[:], mac.Sum(nil))
+		s.cursor++
+		s.used = 0
+	}
+	v := binary.BigEndian.Uint64(s.block[s.used*8:])
+	s.used++
+	return v
+}
+
+// Float64 returns a float in [0, 1) built from the top 53 bits of the next value
+func (s *Source) Float64() float64 {
+	return float64(s.next()>>11) / (1 << 53)
+}
+
+// Intn returns an int in [0, n), scaling Float64 so players can recompute it with any HMAC tool
+func (s *Source) Intn(n int) int {
+	if n <= 0 {
+		panic("invalid argument to Intn")
+	}
+	return int(s.Float64() * float64(n))
+}
@@ -0,0 +1,62 @@
+package fairness
+
+import (
+	"testing"
+)
+
+// Known answers computed independently with Python's hmac module:
+// hmac.new(b"server-seed", b"client-seed:<nonce>:<cursor>", hashlib.sha256), 8 bytes per value
+func TestSourceKnownAnswers(t *testing.T) {
+	tests := []struct {
+		nonce     int
+		wantFloat []float64
+		wantIntn  []int // Intn(16) of a fresh stream
+	}{
+		{
+			nonce:     0,
+			wantFloat: []float64{0.4553733639653279, 0.03993134639219087, 0.016431617997317627, 0.32621311988692536, 0.7310564292151741, 0.5682325707300595},
+			wantIntn:  []int{7, 0, 0, 5, 11, 9},
+		},
+		{
+			nonce:     1,
+			wantFloat: []float64{0.6646030934515438, 0.7549132578371788, 0.5210704172247553, 0.6129679527567967, 0.1586915774066786, 0.8110136245191344},
+			wantIntn:  []int{10, 12, 8, 9, 2, 12},
+		},
+	}
+	for _, tt := range tests {
+		// The fifth value comes from the second block, at cursor 1
+		floats := NewSource("server-seed", "client-seed", tt.nonce)
+		for i, want := range tt.wantFloat {
+			if got := floats.Float64(); got != want {
+				t.Errorf("nonce %d value %d: Float64 = %v, want %v", tt.nonce, i, got, want)
+			}
+		}
+		ints := NewSource("server-seed", "client-seed", tt.nonce)
+		for i, want := range tt.wantIntn {
+			if got := ints.Intn(16); got != want {
+				t.Errorf("nonce %d value %d: Intn(16) = %d, want %d", tt.nonce, i, got, want)
+			}
+		}
+	}
+}
+
+func TestHashServerSeed(t *testing.T) {
+	// sha256sum of "server-seed"
+	const want = "91024ec49c5bec0b689e42892526320fce08337205c91de94c7a588c20d08eeb"
+	if got := HashServerSeed("server-seed"); got != want {
+		t.Errorf("HashServerSeed = %s, want %s", got, want)
+	}
+}
+
+func TestSeedFormat(t *testing.T) {
+	seed := FormatSeed("hash", 42, "client:with:colons")
+	hash, nonce, clientSeed, err := ParseSeed(seed)
+	if err != nil || hash != "hash" || nonce != 42 || clientSeed != "client:with:colons" {
+		t.Errorf("ParseSeed(%q) = %q, %d, %q, %v", seed, hash, nonce, clientSeed, err)
+	}
+	for _, invalid := range []string{"", "hash", "hash:42", "hash:forty-two:client"} {
+		if _, _, _, err := ParseSeed(invalid); err == nil {
+			t.Errorf("ParseSeed(%q) accepted", invalid)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package faults
+
+import (
+	"flag"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Config configures the fault injection middleware used by the local service stubs
+type Config struct {
+	Latency     time.Duration // Added to every request
+	Jitter      time.Duration // Up to this much more, drawn uniformly
+	ErrorRate   float64       // Fraction of requests answered with ErrorStatus
+	ErrorStatus int
+}
+
+// RegisterFlags binds the config to -latency, -jitter, -error-rate and -error-status
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.Latency, "latency", 0, "delay added to every request")
+	fs.DurationVar(&c.Jitter, "jitter", 0, "random extra delay of up to this much")
+	fs.Float64Var(&c.ErrorRate, "error-rate", 0, "fraction of requests that fail (0-1)")
+	fs.IntVar(&c.ErrorStatus, "error-status", fiber.StatusInternalServerError, "status returned by failed requests")
+}
+
+// New creates a middleware that delays requests and fails a fraction of them
+func New(config Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		delay := config.Latency
+		if config.Jitter > 0 {
+			delay += rand.N(config.Jitter)
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+
+		if config.ErrorRate > 0 && rand.Float64() < config.ErrorRate {
+			return c.Status(config.ErrorStatus).JSON(fiber.Map{
+				"status":  "error",
+				"message": "injected failure",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,117 @@
+package faults
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serve sends n requests through the middleware and returns the status and duration of each
+func serve(t *testing.T, config Config, n int) ([]int, []time.Duration) {
+	t.Helper()
+	app := fiber.New()
+	app.Use(New(config))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	statuses := make([]int, n)
+	durations := make([]time.Duration, n)
+	for i := range n {
+		start := time.Now()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		durations[i] = time.Since(start)
+		statuses[i] = resp.StatusCode
+		if resp.StatusCode != http.StatusOK {
+			var body map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body["message"] != "injected failure" {
+				t.Errorf("failed request body = %v, %v, want the injected failure", body, err)
+			}
+		}
+		resp.Body.Close()
+	}
+	return statuses, durations
+}
+
+func TestLatency(t *testing.T) {
+	// Only lower bounds are exact; the upper bounds leave room for a slow machine
+	tests := []struct {
+		name     string
+		config   Config
+		min, max time.Duration
+	}{
+		{"none", Config{}, 0, 100 * time.Millisecond},
+		{"fixed", Config{Latency: 30 * time.Millisecond}, 30 * time.Millisecond, 130 * time.Millisecond},
+		{"with jitter", Config{Latency: 10 * time.Millisecond, Jitter: 20 * time.Millisecond}, 10 * time.Millisecond, 130 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses, durations := serve(t, tt.config, 10)
+			for i, d := range durations {
+				if statuses[i] != http.StatusOK {
+					t.Errorf("request %d = %d, want 200", i, statuses[i])
+				}
+				if d < tt.min || d > tt.max {
+					t.Errorf("request %d took %s, want %s to %s", i, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorRate(t *testing.T) {
+	const requests = 1000
+	tests := []struct {
+		name                 string
+		config               Config
+		wantStatus           int
+		minFailed, maxFailed int
+	}{
+		{"none", Config{}, 0, 0, 0},
+		{"every request", Config{ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}, http.StatusServiceUnavailable, requests, requests},
+		// 30% of 1000 requests, with a margin of more than six standard deviations
+		{"share of requests", Config{ErrorRate: 0.3, ErrorStatus: http.StatusTooManyRequests}, http.StatusTooManyRequests, 200, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses, _ := serve(t, tt.config, requests)
+			failed := 0
+			for _, status := range statuses {
+				switch status {
+				case http.StatusOK:
+				case tt.wantStatus:
+					failed++
+				default:
+					t.Fatalf("status %d, want 200 or %d", status, tt.wantStatus)
+				}
+			}
+			if failed < tt.minFailed || failed > tt.maxFailed {
+				t.Errorf("%d of %d requests failed, want %d to %d", failed, requests, tt.minFailed, tt.maxFailed)
+			}
+		})
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	var config Config
+	fs := flag.NewFlagSet("stub", flag.ContinueOnError)
+	config.RegisterFlags(fs)
+	if config.ErrorStatus != http.StatusInternalServerError {
+		t.Errorf("default error status = %d, want 500", config.ErrorStatus)
+	}
+	if err := fs.Parse([]string{"-latency", "50ms", "-jitter", "10ms", "-error-rate", "0.25", "-error-status", "503"}); err != nil {
+		t.Fatal(err)
+	}
+	want := Config{Latency: 50 * time.Millisecond, Jitter: 10 * time.Millisecond, ErrorRate: 0.25, ErrorStatus: http.StatusServiceUnavailable}
+	if config != want {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+}
@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReplayedHeader is set on responses served from the idempotency store
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Config configures the idempotency middleware
+type Config struct {
+	Store Store
+	// Key extracts the idempotency key from the request.
+	// Requests for which it returns false are passed through unchanged.
+	Key func(c *fiber.Ctx) (string, bool)
+}
+
+// New creates a middleware that replays the stored response for a duplicate request
+// and rejects a request that reuses a key with a different body with 409 Conflict.
+// Only responses below 500 are stored; server errors release the key so the client can retry.
+func New(config Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, ok := config.Key(c)
+		if !ok {
+			return c.Next()
+		}
+
+		sum := sha256.Sum256(append([]byte(c.Method()+" "+c.Path()+"\n"), c.Body()...))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		outcome, stored, err := config.Store.Reserve(key, fingerprint)
+		if err != nil {
+			log.Printf("Idempotency store error for key %s: %v", key, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to check request idempotency",
+			})
+		}
+
+		switch outcome {
+		case Replay:
+			log.Printf("Replaying stored response for idempotency key %s", key)
+			c.Set(ReplayedHeader, "true")
+			c.Set(fiber.HeaderContentType, stored.ContentType)
+			return c.Status(stored.Status).Send(stored.Body)
+		case Conflict:
+			log.Printf("Idempotency key %s reused with a different request body", key)
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Request does not match the original request for this bet and step",
+			})
+		case InProgress:
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Request for this bet and step is already in progress",
+			})
+		}
+
+		if err := c.Next(); err != nil {
+			config.Store.Release(key)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			config.Store.Release(key)
+			return nil
+		}
+
+		resp := Response{
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+		if err := config.Store.Complete(key, resp); err != nil {
+			log.Printf("Failed to store response for idempotency key %s: %v", key, err)
+		}
+		return nil
+	}
+}
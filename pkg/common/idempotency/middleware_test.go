@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMiddleware(t *testing.T) {
+	type call struct {
+		body       string
+		handler    int // Status the handler answers with
+		wantStatus int
+		wantBody   string
+		replayed   bool
+	}
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{"retry replays the stored response", []call{
+			{body: `{"k":"a","v":1}`, handler: 200, wantStatus: 200, wantBody: "run 1"},
+			{body: `{"k":"a","v":1}`, handler: 200, wantStatus: 200, wantBody: "run 1", replayed: true},
+		}},
+		{"same key, different body", []call{
+			{body: `{"k":"a","v":1}`, handler: 200, wantStatus: 200, wantBody: "run 1"},
+			{body: `{"k":"a","v":2}`, handler: 200, wantStatus: 409},
+		}},
+		{"different keys run separately", []call{
+			{body: `{"k":"a"}`, handler: 200, wantStatus: 200, wantBody: "run 1"},
+			{body: `{"k":"b"}`, handler: 200, wantStatus: 200, wantBody: "run 2"},
+		}},
+		{"client errors are stored", []call{
+			{body: `{"k":"a"}`, handler: 400, wantStatus: 400, wantBody: "run 1"},
+			{body: `{"k":"a"}`, handler: 200, wantStatus: 400, wantBody: "run 1", replayed: true},
+		}},
+		{"server errors release the key", []call{
+			{body: `{"k":"a"}`, handler: 503, wantStatus: 503, wantBody: "run 1"},
+			{body: `{"k":"a"}`, handler: 200, wantStatus: 200, wantBody: "run 2"},
+		}},
+		{"requests without a key pass through", []call{
+			{body: `{}`, handler: 200, wantStatus: 200, wantBody: "run 1"},
+			{body: `{}`, handler: 200, wantStatus: 200, wantBody: "run 2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			var status int
+			app := fiber.New()
+			app.Post("/", New(Config{
+				Store: NewMemoryStore(time.Hour),
+				Key: func(c *fiber.Ctx) (string, bool) {
+					key := strings.Split(string(c.Body()), `"k":"`)
+					if len(key) < 2 {
+						return "", false
+					}
+					return key[1][:1], true
+				},
+			}), func(c *fiber.Ctx) error {
+				runs++
+				return c.Status(status).SendString("run " + string(rune('0'+runs)))
+			})
+
+			for i, call := range tt.calls {
+				status = call.handler
+				resp, err := app.Test(httptest.NewRequest("POST", "/", strings.NewReader(call.body)), -1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				if resp.StatusCode != call.wantStatus {
+					t.Errorf("call %d: status %d, want %d", i+1, resp.StatusCode, call.wantStatus)
+				}
+				if call.wantBody != "" && string(body) != call.wantBody {
+					t.Errorf("call %d: body %q, want %q", i+1, body, call.wantBody)
+				}
+				if replayed := resp.Header.Get(ReplayedHeader) == "true"; replayed != call.replayed {
+					t.Errorf("call %d: replayed %v, want %v", i+1, replayed, call.replayed)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewMemoryStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	steps := []struct {
+		name        string
+		fingerprint string
+		complete    bool
+		after       time.Duration
+		want        Outcome
+	}{
+		{"new key", "f1", false, 0, Reserved},
+		{"still running", "f1", true, 0, InProgress},
+		{"completed", "f1", false, 0, Replay},
+		{"other body", "f2", false, 0, Conflict},
+		{"expired", "f2", false, 2 * time.Minute, Reserved},
+	}
+	for _, step := range steps {
+		now = now.Add(step.after)
+		outcome, _, err := store.Reserve("k", step.fingerprint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outcome != step.want {
+			t.Errorf("%s: outcome %d, want %d", step.name, outcome, step.want)
+		}
+		if step.complete {
+			store.Complete("k", Response{Status: 200})
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"sync"
+	"time"
+)
+
+// Outcome of reserving a key
+type Outcome int
+
+const (
+	// Reserved means the key is new and the caller must execute the request
+	Reserved Outcome = iota
+	// Replay means a stored response exists for the same request body
+	Replay
+	// Conflict means the key was used with a different request body
+	Conflict
+	// InProgress means the same request is still being executed
+	InProgress
+)
+
+// Response is a stored handler response
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Store keeps responses per idempotency key
+type Store interface {
+	// Reserve claims key for a request with the given body fingerprint.
+	// For Replay the stored response is returned.
+	Reserve(key, fingerprint string) (Outcome, Response, error)
+	// Complete stores the response for a reserved key
+	Complete(key string, resp Response) error
+	// Release drops a reservation so the request can be retried
+	Release(key string) error
+}
+
+type entry struct {
+	fingerprint string
+	done        bool
+	response    Response
+	expires     time.Time
+}
+
+// MemoryStore keeps idempotency records in process memory for a fixed TTL
+type MemoryStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+// NewMemoryStore creates an in-memory store whose records expire after ttl
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// Reserve claims key for a request
+func (s *MemoryStore) Reserve(key, fingerprint string) (Outcome, Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evictExpired(now)
+
+	e, ok := s.entries[key]
+	if !ok {
+		s.entries[key] = &entry{fingerprint: fingerprint, expires: now.Add(s.ttl)}
+		return Reserved, Response{}, nil
+	}
+	switch {
+	case e.fingerprint != fingerprint:
+		return Conflict, Response{}, nil
+	case !e.done:
+		return InProgress, Response{}, nil
+	default:
+		return Replay, e.response, nil
+	}
+}
+
+// Complete stores the response for a reserved key
+func (s *MemoryStore) Complete(key string, resp Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.done = true
+		e.response = resp
+		e.expires = s.now().Add(s.ttl)
+	}
+	return nil
+}
+
+// Release drops a reservation
+func (s *MemoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) evictExpired(now time.Time) {
+	for key, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package random
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	selfTestSamples = 200000
+	selfTestBuckets = 16
+	// Chi-square critical value for 15 degrees of freedom at p = 0.0001. Float64 and Intn are
+	// both checked, so a healthy stream fails about twice in ten thousand
+	selfTestChiSquareLimit = 44.3
+	// Independent streams checked before the source is refused: a healthy source fails
+	// them all about four times in a hundred million startups, a biased one every time
+	selfTestAttempts = 2
+)
+
+// SelfTest checks that a factory produces usable randomness: fresh seeds differ,
+// a seed and step always reproduce the same sequence, different steps do not, and
+// Float64 and Intn are uniform under a chi-square test. The source is refused only when
+// every one of selfTestAttempts independent streams fails the uniformity test.
+func SelfTest(f Factory) error {
+	seedA, err := f.NewSeed()
+	if err != nil {
+		return err
+	}
+	seedB, err := f.NewSeed()
+	if err != nil {
+		return err
+	}
+	if seedA == seedB {
+		return fmt.Errorf("two fresh seeds are identical: %s", seedA)
+	}
+
+	first, err := f.New(seedA, 1)
+	if err != nil {
+		return err
+	}
+	again, err := f.New(seedA, 1)
+	if err != nil {
+		return err
+	}
+	next, err := f.New(seedA, 2)
+	if err != nil {
+		return err
+	}
+	other, err := f.New(seedB, 1)
+	if err != nil {
+		return err
+	}
+	sameAsNext, sameAsOther := true, true
+	for i := 0; i < 64; i++ {
+		v := first.Float64()
+		if v != again.Float64() {
+			return fmt.Errorf("seed %s does not reproduce its sequence", seedA)
+		}
+		if v != next.Float64() {
+			sameAsNext = false
+		}
+		if v != other.Float64() {
+			sameAsOther = false
+		}
+	}
+	if sameAsNext {
+		return fmt.Errorf("steps 1 and 2 of seed %s share a sequence", seedA)
+	}
+	if sameAsOther {
+		return fmt.Errorf("seeds %s and %s share a sequence", seedA, seedB)
+	}
+
+	// Each attempt checks a fresh stream of seedB; steps 1 and 2 of seedA were read above
+	for attempt := 1; ; attempt++ {
+		src, err := f.New(seedB, 1+attempt)
+		if err != nil {
+			return err
+		}
+		err = checkUniform(src)
+		if err == nil || attempt == selfTestAttempts {
+			return err
+		}
+	}
+}
+
+// checkUniform draws selfTestSamples values of Float64 and Intn and runs a chi-square test on each
+func checkUniform(src Source) error {
+	floats := make([]int, selfTestBuckets)
+	ints := make([]int, selfTestBuckets)
+	for i := 0; i < selfTestSamples; i++ {
+		v := src.Float64()
+		if v < 0 || v >= 1 || math.IsNaN(v) {
+			return fmt.Errorf("Float64 returned %v outside [0, 1)", v)
+		}
+		floats[int(v*selfTestBuckets)]++
+
+		n := src.Intn(selfTestBuckets)
+		if n < 0 || n >= selfTestBuckets {
+			return fmt.Errorf("Intn(%d) returned %d", selfTestBuckets, n)
+		}
+		ints[n]++
+	}
+	if chi := chiSquare(floats, selfTestSamples); chi > selfTestChiSquareLimit {
+		return fmt.Errorf("Float64 is not uniform (chi-square %.1f > %.1f)", chi, selfTestChiSquareLimit)
+	}
+	if chi := chiSquare(ints, selfTestSamples); chi > selfTestChiSquareLimit {
+		return fmt.Errorf("Intn is not uniform (chi-square %.1f > %.1f)", chi, selfTestChiSquareLimit)
+	}
+	return nil
+}
+
+// chiSquare computes the chi-square statistic of bucket counts against a uniform distribution
+func chiSquare(counts []int, samples int) float64 {
+	expected := float64(samples) / float64(len(counts))
+	chi := 0.0
+	for _, count := range counts {
+		d := float64(count) - expected
+		chi += d * d / expected
+	}
+	return chi
+}
@@ -0,0 +1,106 @@
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeFactory wraps CryptoFactory with the flaws a test asks for
+type fakeFactory struct {
+	CryptoFactory
+	fixedSeed   bool           // NewSeed always returns the same seed
+	ignoreStep  bool           // Every step of a seed shares one stream
+	biasedSteps func(int) bool // Steps whose stream is biased
+	calls       map[string]int // Streams created per seed, for sources that do not reproduce
+	counter     bool           // A seed and step give a new stream each time
+}
+
+func (f *fakeFactory) NewSeed() (string, error) {
+	if f.fixedSeed {
+		return f.DeriveSeed("fixed"), nil
+	}
+	return f.CryptoFactory.NewSeed()
+}
+
+func (f *fakeFactory) New(seed string, step int) (Source, error) {
+	stream := step
+	if f.ignoreStep {
+		stream = 0
+	}
+	if f.counter {
+		if f.calls == nil {
+			f.calls = make(map[string]int)
+		}
+		f.calls[seed]++
+		stream = 1000 * f.calls[seed]
+	}
+	src, err := f.CryptoFactory.New(seed, stream)
+	if err != nil || f.biasedSteps == nil || !f.biasedSteps(step) {
+		return src, err
+	}
+	return biasedSource{src}, nil
+}
+
+// biasedSource favours low values: Float64 is squared and Intn lands on 0 a third of the time
+type biasedSource struct {
+	src Source
+}
+
+func (s biasedSource) Float64() float64 {
+	v := s.src.Float64()
+	return v * v
+}
+
+func (s biasedSource) Intn(n int) int {
+	if s.src.Intn(3) == 0 {
+		return 0
+	}
+	return s.src.Intn(n)
+}
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       Factory
+		wantErr string
+	}{
+		{"crypto", CryptoFactory{}, ""},
+		{"math", MathFactory{}, ""},
+		{"fixed seed", &fakeFactory{fixedSeed: true}, "two fresh seeds are identical"},
+		{"not reproducible", &fakeFactory{counter: true}, "does not reproduce its sequence"},
+		{"steps share a stream", &fakeFactory{ignoreStep: true}, "share a sequence"},
+		{"biased source", &fakeFactory{biasedSteps: func(int) bool { return true }}, "is not uniform"},
+		{"one biased stream", &fakeFactory{biasedSteps: func(step int) bool { return step == 2 }}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SelfTest(tt.f)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("SelfTest = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("SelfTest = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChiSquare(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int
+		want   float64
+	}{
+		{"uniform", []int{25, 25, 25, 25}, 0},
+		{"skewed", []int{40, 20, 20, 20}, 12},
+		{"one bucket", []int{100, 0, 0, 0}, 300},
+	}
+	for _, tt := range tests {
+		if got := chiSquare(tt.counts, 100); got != tt.want {
+			t.Errorf("%s: chiSquare = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
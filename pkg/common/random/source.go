@@ -0,0 +1,77 @@
+package random
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+// Source is the randomness consumed by grid generation and the gravity fills.
+// *math/rand.Rand satisfies it.
+type Source interface {
+	Intn(n int) int
+	Float64() float64
+}
+
+// Factory creates the Source for each step of a round from the round's seed
+type Factory interface {
+	// NewSeed draws the seed for a new round
+	NewSeed() (string, error)
+	// New returns the source for one step of the round; the same seed and step always yield the same sequence
+	New(seed string, step int) (Source, error)
+	// DeriveSeed derives a round seed from a fixed label, so simulations can be reproduced.
+	// Players' rounds always use NewSeed.
+	DeriveSeed(label string) string
+}
+
+// NewFactory creates a factory by kind ("crypto" or "math")
+func NewFactory(kind string) (Factory, error) {
+	switch kind {
+	case "", "crypto":
+		return CryptoFactory{}, nil
+	case "math":
+		return MathFactory{}, nil
+	default:
+		return nil, fmt.Errorf("unknown random source: %s", kind)
+	}
+}
+
+// MathFactory derives math/rand sources from a 64-bit round seed
+type MathFactory struct{}
+
+// NewSeed draws a 64-bit seed from crypto/rand so concurrent rounds never share a sequence
+func (MathFactory) NewSeed() (string, error) {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to draw round seed: %w", err)
+	}
+	return strconv.FormatInt(int64(binary.BigEndian.Uint64(b[:])), 10), nil
+}
+
+// DeriveSeed hashes label into a 64-bit round seed
+func (MathFactory) DeriveSeed(label string) string {
+	sum := sha256.Sum256([]byte(label))
+	return strconv.FormatInt(int64(binary.BigEndian.Uint64(sum[:8])), 10)
+}
+
+// New returns a math/rand source for the given step of the round
+func (MathFactory) New(seed string, step int) (Source, error) {
+	value, err := strconv.ParseInt(seed, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid round seed %q: %w", seed, err)
+	}
+	return rand.New(rand.NewSource(StepSeed(value, step))), nil
+}
+
+// StepSeed derives the seed of one step from the round seed, so each request of a
+// round can rebuild its own sequence without replaying the previous steps
+func StepSeed(seed int64, step int) int64 {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(seed))
+	binary.BigEndian.PutUint64(b[8:], uint64(step))
+	sum := sha256.Sum256(b[:])
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
@@ -0,0 +1,105 @@
+package random
+
+import (
+	"testing"
+)
+
+// draws reads n values of Float64 and Intn from a step of seed
+func draws(t *testing.T, f Factory, seed string, step, n int) []float64 {
+	t.Helper()
+	src, err := f.New(seed, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]float64, 0, 2*n)
+	for i := 0; i < n; i++ {
+		values = append(values, src.Float64(), float64(src.Intn(1000)))
+	}
+	return values
+}
+
+func equal(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFactoryStreams(t *testing.T) {
+	for _, kind := range []string{"crypto", "math"} {
+		t.Run(kind, func(t *testing.T) {
+			f, err := NewFactory(kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seed, other := f.DeriveSeed("stream"), f.DeriveSeed("other stream")
+			stream := draws(t, f, seed, 1, 100)
+
+			tests := []struct {
+				name     string
+				seed     string
+				step     int
+				wantSame bool
+			}{
+				{"same seed and step", seed, 1, true},
+				{"next step", seed, 2, false},
+				{"step 0", seed, 0, false},
+				{"other seed", other, 1, false},
+			}
+			for _, tt := range tests {
+				if same := equal(stream, draws(t, f, tt.seed, tt.step, 100)); same != tt.wantSame {
+					t.Errorf("%s: same stream = %v, want %v", tt.name, same, tt.wantSame)
+				}
+			}
+		})
+	}
+}
+
+// A step's stream depends only on the seed and step, not on the steps read before it
+func TestStepStreamIsIndependentOfEarlierSteps(t *testing.T) {
+	f := CryptoFactory{}
+	seed := f.DeriveSeed("independent")
+	want := draws(t, f, seed, 3, 50)
+	draws(t, f, seed, 1, 500)
+	draws(t, f, seed, 2, 500)
+	if !equal(draws(t, f, seed, 3, 50), want) {
+		t.Error("step 3 changed after steps 1 and 2 were read")
+	}
+}
+
+func TestDeriveSeed(t *testing.T) {
+	for _, f := range []Factory{CryptoFactory{}, MathFactory{}} {
+		if f.DeriveSeed("label") != f.DeriveSeed("label") {
+			t.Errorf("%T: the same label derived different seeds", f)
+		}
+		if f.DeriveSeed("label") == f.DeriveSeed("other label") {
+			t.Errorf("%T: different labels derived the same seed", f)
+		}
+		if _, err := f.New(f.DeriveSeed("label"), 1); err != nil {
+			t.Errorf("%T: derived seed rejected: %v", f, err)
+		}
+	}
+}
+
+func TestFactoryRejectsInvalidSeeds(t *testing.T) {
+	tests := []struct {
+		name string
+		f    Factory
+		seed string
+	}{
+		{"crypto, not hex", CryptoFactory{}, "not a seed"},
+		{"crypto, too short", CryptoFactory{}, "abcd"},
+		{"math, not a number", MathFactory{}, "not a seed"},
+		{"math, a crypto seed", MathFactory{}, CryptoFactory{}.DeriveSeed("label")},
+	}
+	for _, tt := range tests {
+		if _, err := tt.f.New(tt.seed, 1); err == nil {
+			t.Errorf("%s: seed %q accepted", tt.name, tt.seed)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package rng
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker is a circuit breaker that stops calls to a failing service.
+// After Threshold consecutive failures it opens for Cooldown, then lets a
+// single trial call through; the trial's result closes or re-opens it.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// NewBreaker creates a closed circuit breaker
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+	}
+}
+
+// Allow reports whether a call may be made now
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.Threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	// Half-open: let one call find out whether the service is back
+	b.trial = true
+	return true
+}
+
+// Success closes the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure counts a failed call, opening the breaker at the threshold
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.failures >= b.Threshold {
+		b.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// Release gives up a trial call that ended without telling anything about the service
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
@@ -0,0 +1,35 @@
+package rng
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name     string
+		calls    func(b *Breaker)
+		cooldown time.Duration
+		want     []bool // Allow results after the calls
+	}{
+		{"closed", func(b *Breaker) {}, time.Minute, []bool{true, true}},
+		{"below the threshold", func(b *Breaker) { b.Failure() }, time.Minute, []bool{true}},
+		{"success resets the count", func(b *Breaker) { b.Failure(); b.Success(); b.Failure() }, time.Minute, []bool{true}},
+		{"open", func(b *Breaker) { b.Failure(); b.Failure() }, time.Minute, []bool{false}},
+		{"half-open lets one trial through", func(b *Breaker) { b.Failure(); b.Failure() }, 0, []bool{true, false}},
+		{"failed trial re-opens", func(b *Breaker) { b.Failure(); b.Failure(); b.Allow(); b.Failure() }, time.Minute, []bool{false}},
+		{"successful trial closes", func(b *Breaker) { b.Failure(); b.Failure(); b.Allow(); b.Success() }, 0, []bool{true, true}},
+		{"released trial allows another", func(b *Breaker) { b.Failure(); b.Failure(); b.Allow(); b.Release() }, 0, []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker(2, tt.cooldown)
+			tt.calls(b)
+			for i, want := range tt.want {
+				if got := b.Allow(); got != want {
+					t.Errorf("Allow %d = %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
@@ -2,23 +2,39 @@ package rng
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/google/uuid"
 )
 
+// ErrRNGUnavailable is returned when the RNG service cannot be reached, keeps
+// failing, or the circuit breaker is refusing calls to it
+var ErrRNGUnavailable = errors.New("RNG service unavailable")
+
 // Client for RNG service
 type Client struct {
 	ServiceURL string
+	HTTPClient *http.Client // Timeout bounds every attempt
+	MaxRetries int          // Retries after the first attempt
+	Breaker    *Breaker
+	Signer     *Signer // Signs requests and verifies responses when set
 }
 
 // NewClient creates a new RNG client
 func NewClient(serviceURL string) *Client {
 	return &Client{
 		ServiceURL: serviceURL,
+		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+		MaxRetries: 2,
+		Breaker:    NewBreaker(5, 30*time.Second),
 	}
 }
 
@@ -37,14 +53,15 @@ type Request struct {
 }
 
 type Response struct {
-	PrefOutcome string  `json:"pref_outcome"`
-	WinAmount   float64 `json:"win_amount"`
-	WinProb     float64 `json:"win_prob"`
+	PrefOutcome  string  `json:"pref_outcome"`
+	WinAmount    float64 `json:"win_amount"`
+	WinProb      float64 `json:"win_prob"`
+	OutcomeClass string  `json:"outcome_class,omitempty"` // Band the win was drawn from, for requests without a payout
 }
 
-// GetOutcome calls the RNG service and returns the outcome
-func (c *Client) GetOutcome(clientID, gameID, playerID, betID string, rtp, payoutMultiplier, betAmount float64, ipAddress string, userAgent string, featureBuy bool) (Response, error) {
-	reqBody, err := json.Marshal(Request{
+// NewRequest builds an RNG request with a fresh request salt
+func NewRequest(clientID, gameID, playerID, betID string, rtp, payoutMultiplier, betAmount float64, ipAddress string, userAgent string, featureBuy bool) Request {
+	return Request{
 		ClientID:         clientID,
 		GameID:           gameID,
 		BetID:            betID,
@@ -56,15 +73,79 @@ func (c *Client) GetOutcome(clientID, gameID, playerID, betID string, rtp, payou
 		IPAddress:        ipAddress,
 		UserAgent:        userAgent,
 		FeatureBuy:       featureBuy,
-	})
+	}
+}
+
+// GetOutcome calls the RNG service and returns the outcome
+func (c *Client) GetOutcome(ctx context.Context, clientID, gameID, playerID, betID string, rtp, payoutMultiplier, betAmount float64, ipAddress string, userAgent string, featureBuy bool) (Response, error) {
+	return c.Send(ctx, NewRequest(clientID, gameID, playerID, betID, rtp, payoutMultiplier, betAmount, ipAddress, userAgent, featureBuy))
+}
+
+// Send posts a prepared request to the RNG service.
+// Callers that need to record exactly what was asked use it instead of GetOutcome.
+// Failed attempts are retried with jittered backoff; every retry resends the same
+// body, so the service sees the same RequestSalt and can treat it as one request.
+func (c *Client) Send(ctx context.Context, req Request) (Response, error) {
+	reqBody, err := json.Marshal(req)
 	if err != nil {
 		log.Printf("Error marshaling RNG request: %v", err)
 		return Response{}, err
 	}
 
+	if c.Breaker != nil && !c.Breaker.Allow() {
+		log.Printf("RNG circuit breaker open, not calling RNG API")
+		return Response{}, ErrRNGUnavailable
+	}
+
 	log.Printf("RNG request: %s", string(reqBody))
 
-	resp, err := http.Post(c.ServiceURL, "application/json", bytes.NewBuffer(reqBody))
+	var rngResp Response
+	operation := func() error {
+		resp, err := c.post(ctx, req.RequestSalt, reqBody)
+		if err != nil {
+			return err
+		}
+		rngResp = resp
+		return nil
+	}
+
+	policy := backoff.NewExponentialBackOff()
+	policy.InitialInterval = 100 * time.Millisecond
+	policy.MaxInterval = time.Second
+	err = backoff.Retry(operation, backoff.WithContext(backoff.WithMaxRetries(policy, uint64(c.MaxRetries)), ctx))
+	c.record(ctx, err)
+	if err != nil {
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return Response{}, permanent.err
+		}
+		return Response{}, fmt.Errorf("%w: %v", ErrRNGUnavailable, err)
+	}
+
+	return rngResp, nil
+}
+
+// permanentError marks a reply that retrying cannot fix. Neutral errors were caused by the
+// request, so they say nothing about the health of the service.
+type permanentError struct {
+	err     error
+	neutral bool
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+// post makes one attempt; errors wrapped in backoff.Permanent are not retried
+func (c *Client) post(ctx context.Context, salt string, reqBody []byte) (Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.ServiceURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return Response{}, backoff.Permanent(&permanentError{err: err, neutral: true})
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.Signer != nil {
+		c.Signer.SignRequest(httpReq.Header, salt, reqBody)
+	}
+
+	resp, err := c.httpClient().Do(httpReq)
 	if err != nil {
 		log.Printf("Error calling RNG API: %v", err)
 		return Response{}, err
@@ -73,14 +154,60 @@ func (c *Client) GetOutcome(clientID, gameID, playerID, betID string, rtp, payou
 
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("RNG API returned non-200 status: %d", resp.StatusCode)
-		return Response{}, errors.New("RNG API call failed")
+		err := fmt.Errorf("RNG API call failed with status %d", resp.StatusCode)
+		switch {
+		// The service refusing our credentials or signature is its fault or ours, not the request's
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return Response{}, backoff.Permanent(&permanentError{err: err})
+		case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+			return Response{}, backoff.Permanent(&permanentError{err: err, neutral: true})
+		}
+		return Response{}, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Error reading RNG response: %v", err)
+		return Response{}, err
+	}
+	// An answer that does not verify may have been altered on the way; asking again will not help
+	if c.Signer != nil {
+		if err := c.Signer.VerifyResponse(resp.Header, salt, respBody); err != nil {
+			log.Printf("⚠️  RNG response signature rejected: %v", err)
+			return Response{}, backoff.Permanent(&permanentError{err: err})
+		}
 	}
 
 	var rngResp Response
-	if err := json.NewDecoder(resp.Body).Decode(&rngResp); err != nil {
+	if err := json.Unmarshal(respBody, &rngResp); err != nil {
 		log.Printf("Error decoding RNG response: %v", err)
 		return Response{}, err
 	}
 
 	return rngResp, nil
 }
+
+// record feeds the outcome of a call to the circuit breaker. Rejected credentials and
+// responses that fail verification count as failures; only errors caused by the request
+// are neutral, and a call abandoned by its caller says nothing about the service.
+func (c *Client) record(ctx context.Context, err error) {
+	if c.Breaker == nil {
+		return
+	}
+	var permanent *permanentError
+	switch {
+	case err == nil, errors.As(err, &permanent) && permanent.neutral:
+		c.Breaker.Success()
+	case errors.Is(ctx.Err(), context.Canceled):
+		c.Breaker.Release()
+	default:
+		c.Breaker.Failure()
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
@@ -0,0 +1,118 @@
+package rng
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// The client logs every request
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// errAny expects a call to fail without naming the error
+var errAny = errors.New("any error")
+
+func TestClientBreakerAccounting(t *testing.T) {
+	signer, err := NewSigner([]Key{{ID: "k1", Secret: []byte("secret")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := NewSigner([]Key{{ID: "k1", Secret: []byte("other")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// answer replies with status, signing the body with sign when it is set
+	answer := func(status int, sign *Signer, tamper bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req Request
+			json.NewDecoder(r.Body).Decode(&req)
+			body, _ := json.Marshal(Response{PrefOutcome: "win", WinAmount: 1})
+			if sign != nil {
+				sign.SignResponse(w.Header(), req.RequestSalt, body)
+			}
+			if tamper {
+				body, _ = json.Marshal(Response{PrefOutcome: "win", WinAmount: 100})
+			}
+			w.WriteHeader(status)
+			w.Write(body)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		signer       *Signer
+		wantErr      error // nil expects a successful call
+		wantFailures int
+	}{
+		{"answer", answer(http.StatusOK, nil, false), nil, nil, 0},
+		{"signed answer", answer(http.StatusOK, signer, false), signer, nil, 0},
+		{"bad request", answer(http.StatusBadRequest, nil, false), nil, errAny, 0},
+		{"credentials refused", answer(http.StatusUnauthorized, nil, false), nil, errAny, 1},
+		{"signature refused", answer(http.StatusForbidden, nil, false), signer, errAny, 1},
+		{"server error", answer(http.StatusInternalServerError, nil, false), nil, ErrRNGUnavailable, 1},
+		{"unsigned answer", answer(http.StatusOK, nil, false), signer, ErrInvalidSignature, 1},
+		{"answer signed with another key", answer(http.StatusOK, otherSigner, false), signer, ErrInvalidSignature, 1},
+		{"tampered answer", answer(http.StatusOK, signer, true), signer, ErrInvalidSignature, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			client := NewClient(server.URL)
+			client.MaxRetries = 0
+			client.Signer = tt.signer
+			client.Breaker = NewBreaker(3, time.Minute)
+
+			_, err := client.Send(context.Background(), NewRequest("c1", "g1", "p1", "b1", 0.96, 2, 1, "", "", false))
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Errorf("err = %v, want none", err)
+			case tt.wantErr == errAny && err == nil:
+				t.Error("err = nil, want an error")
+			case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := client.Breaker.failures; got != tt.wantFailures {
+				t.Errorf("breaker failures = %d, want %d", got, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestClientBreakerOpensOnBadSignatures(t *testing.T) {
+	signer, err := NewSigner([]Key{{ID: "k1", Secret: []byte("secret")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Response{PrefOutcome: "win"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.MaxRetries = 0
+	client.Signer = signer
+	client.Breaker = NewBreaker(2, time.Minute)
+	req := NewRequest("c1", "g1", "p1", "b1", 0.96, 2, 1, "", "", false)
+	for i := 0; i < 2; i++ {
+		if _, err := client.Send(context.Background(), req); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("call %d: err = %v, want %v", i, err, ErrInvalidSignature)
+		}
+	}
+	if _, err := client.Send(context.Background(), req); !errors.Is(err, ErrRNGUnavailable) {
+		t.Errorf("call after repeated bad signatures: err = %v, want the open breaker's %v", err, ErrRNGUnavailable)
+	}
+}
@@ -0,0 +1,170 @@
+package rng
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync"
+)
+
+// ErrScriptExhausted is returned by a ScriptedProvider with no responses left
+var ErrScriptExhausted = errors.New("scripted RNG responses exhausted")
+
+// OutcomeProvider decides whether a step's payout may stand.
+// *Client asks the remote RNG service; LocalProvider and ScriptedProvider decide in-process.
+type OutcomeProvider interface {
+	// Send returns the outcome for a prepared request
+	Send(ctx context.Context, req Request) (Response, error)
+}
+
+// LocalProvider decides outcomes in-process from the request's RTP and payout multiplier.
+// A payout of m times the bet is allowed with probability
+//
+//	p = min(1, RTP / m)
+//
+// so a step's expected payout is p*m = RTP times the bet, or the whole payout when
+// m < RTP.
+//
+// A request without a payout (m = 0) asks the provider to choose the win, as the
+// RNG-first spin does. The win is drawn from Classes: with E the mean multiple of a
+// class-weighted win, the request wins with probability q = min(1, RTP / E) and pays
+// a multiple drawn uniformly from the chosen class, so its expected payout is q*E = RTP.
+type LocalProvider struct {
+	// Float64 draws the decision; nil uses math/rand/v2, which is seeded from crypto/rand
+	Float64 func() float64
+	// Classes are the wins chosen for requests without a payout; nil uses DefaultOutcomeClasses
+	Classes []OutcomeClass
+}
+
+// OutcomeClass is a band of wins, as multiples of the bet
+type OutcomeClass struct {
+	Name   string
+	Min    float64
+	Max    float64
+	Weight float64 // Relative frequency among wins
+}
+
+// DefaultOutcomeClasses make most wins small and a few large
+var DefaultOutcomeClasses = []OutcomeClass{
+	{Name: "small", Min: 0.2, Max: 1, Weight: 60},
+	{Name: "medium", Min: 1, Max: 5, Weight: 30},
+	{Name: "big", Min: 5, Max: 20, Weight: 8},
+	{Name: "huge", Min: 20, Max: 100, Weight: 2},
+}
+
+// NewLocalProvider creates a local provider using math/rand/v2
+func NewLocalProvider() *LocalProvider {
+	return &LocalProvider{}
+}
+
+// Send decides the outcome locally
+func (p *LocalProvider) Send(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	draw := rand.Float64
+	if p.Float64 != nil {
+		draw = p.Float64
+	}
+	if req.PayoutMultiplier <= 0 {
+		return p.chooseWin(req, draw), nil
+	}
+
+	winProb := WinProbability(req.RTP, req.PayoutMultiplier)
+	if draw() < winProb {
+		return Response{PrefOutcome: "win", WinAmount: req.PayoutMultiplier * req.BetAmount, WinProb: winProb}, nil
+	}
+	return Response{PrefOutcome: "loss", WinProb: winProb}, nil
+}
+
+// chooseWin draws a win class and amount for a request without a payout
+func (p *LocalProvider) chooseWin(req Request, draw func() float64) Response {
+	classes := p.Classes
+	if classes == nil {
+		classes = DefaultOutcomeClasses
+	}
+
+	totalWeight, meanMultiple := 0.0, 0.0
+	for _, class := range classes {
+		totalWeight += class.Weight
+		meanMultiple += class.Weight * (class.Min + class.Max) / 2
+	}
+	if totalWeight <= 0 || meanMultiple <= 0 {
+		return Response{PrefOutcome: "loss"}
+	}
+	meanMultiple /= totalWeight
+
+	winProb := min(1, max(0, req.RTP/meanMultiple))
+	if draw() >= winProb {
+		return Response{PrefOutcome: "loss", WinProb: winProb}
+	}
+
+	pick := draw() * totalWeight
+	class := classes[len(classes)-1]
+	for _, candidate := range classes {
+		if pick < candidate.Weight {
+			class = candidate
+			break
+		}
+		pick -= candidate.Weight
+	}
+	multiple := class.Min + draw()*(class.Max-class.Min)
+	return Response{
+		PrefOutcome:  "win",
+		WinAmount:    math.Round(multiple*req.BetAmount*100) / 100,
+		WinProb:      winProb,
+		OutcomeClass: class.Name,
+	}
+}
+
+// WinProbability is the chance LocalProvider allows a payout of payoutMultiplier times the bet
+func WinProbability(rtp, payoutMultiplier float64) float64 {
+	if payoutMultiplier <= 0 {
+		return 1
+	}
+	return min(1, max(0, rtp/payoutMultiplier))
+}
+
+// ScriptedProvider replays a fixed queue of responses for tests and keeps the requests it was sent
+type ScriptedProvider struct {
+	mu        sync.Mutex
+	responses []Response
+	requests  []Request
+}
+
+// NewScriptedProvider creates a provider that answers with responses in order
+func NewScriptedProvider(responses ...Response) *ScriptedProvider {
+	return &ScriptedProvider{responses: responses}
+}
+
+// Push queues more responses
+func (p *ScriptedProvider) Push(responses ...Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.responses = append(p.responses, responses...)
+}
+
+// Send returns the next queued response
+func (p *ScriptedProvider) Send(ctx context.Context, req Request) (Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return Response{}, ErrScriptExhausted
+	}
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return resp, nil
+}
+
+// Requests returns the requests sent so far
+func (p *ScriptedProvider) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Request(nil), p.requests...)
+}
@@ -0,0 +1,84 @@
+package rng
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestWinProbability(t *testing.T) {
+	tests := []struct {
+		rtp, multiplier, want float64
+	}{
+		{0.96, 0, 1},
+		{0.96, 0.5, 1},
+		{0.96, 2, 0.48},
+		{0.96, 96, 0.01},
+		{-1, 2, 0},
+	}
+	for _, tt := range tests {
+		if got := WinProbability(tt.rtp, tt.multiplier); got != tt.want {
+			t.Errorf("WinProbability(%g, %g) = %g, want %g", tt.rtp, tt.multiplier, got, tt.want)
+		}
+	}
+}
+
+func TestLocalProviderReturnsRTP(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	provider := &LocalProvider{Float64: r.Float64}
+
+	tests := []struct {
+		name       string
+		multiplier float64
+	}{
+		{"payout of 2x", 2},
+		{"payout of 20x", 20},
+		{"provider chooses the win", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const rounds = 200000
+			paid := 0.0
+			for i := 0; i < rounds; i++ {
+				resp, err := provider.Send(context.Background(), Request{RTP: 0.96, PayoutMultiplier: tt.multiplier, BetAmount: 1})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.PrefOutcome == "win" {
+					paid += resp.WinAmount
+				}
+			}
+			if rtp := paid / rounds; rtp < 0.93 || rtp > 0.99 {
+				t.Errorf("RTP = %.4f, want about 0.96", rtp)
+			}
+		})
+	}
+}
+
+func TestLocalProviderHonoursContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewLocalProvider().Send(ctx, Request{RTP: 0.96}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Send with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestScriptedProvider(t *testing.T) {
+	provider := NewScriptedProvider(Response{PrefOutcome: "win", WinAmount: 2})
+	provider.Push(Response{PrefOutcome: "loss"})
+
+	want := []string{"win", "loss"}
+	for i, outcome := range want {
+		resp, err := provider.Send(context.Background(), Request{BetID: want[i]})
+		if err != nil || resp.PrefOutcome != outcome {
+			t.Errorf("response %d = %+v, %v, want %s", i, resp, err, outcome)
+		}
+	}
+	if _, err := provider.Send(context.Background(), Request{BetID: "extra"}); !errors.Is(err, ErrScriptExhausted) {
+		t.Errorf("Send after the script = %v, want %v", err, ErrScriptExhausted)
+	}
+	if requests := provider.Requests(); len(requests) != 3 || requests[2].BetID != "extra" {
+		t.Errorf("Requests = %+v, want the 3 requests sent", requests)
+	}
+}
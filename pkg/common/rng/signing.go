@@ -0,0 +1,137 @@
+package rng
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers carrying RNG request and response signatures
+const (
+	HeaderKeyID     = "X-RNG-Key-Id"
+	HeaderTimestamp = "X-RNG-Timestamp"
+	HeaderSignature = "X-RNG-Signature"
+)
+
+// ErrInvalidSignature is returned when a signed request or response does not verify
+var ErrInvalidSignature = errors.New("invalid RNG signature")
+
+// Key is a named HMAC secret shared with the RNG service
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKeys parses a "id:secret,id:secret" list, active key first
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(part, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("RNG signing key %q is not in id:secret form", id)
+		}
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+// Signer signs and verifies RNG messages with HMAC-SHA256.
+//
+// A request is signed over its request_salt, a Unix timestamp and its body; the salt is
+// the nonce the RNG service can use to reject replays. A response is signed over the salt
+// of the request it answers and its body, so it cannot be replayed for another request.
+// The first key signs; every key verifies, so keys can be rotated on both sides.
+type Signer struct {
+	keys []Key
+
+	// MaxSkew bounds how old or early a signed request's timestamp may be
+	MaxSkew time.Duration
+}
+
+// NewSigner creates a signer from an ordered key list
+func NewSigner(keys []Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one RNG signing key is required")
+	}
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicate RNG signing key id: %s", k.ID)
+		}
+		seen[k.ID] = true
+	}
+	return &Signer{keys: keys, MaxSkew: 5 * time.Minute}, nil
+}
+
+// SignRequest sets the signature headers of a request body
+func (s *Signer) SignRequest(header http.Header, salt string, body []byte) {
+	key := s.keys[0]
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header.Set(HeaderKeyID, key.ID)
+	header.Set(HeaderTimestamp, timestamp)
+	header.Set(HeaderSignature, hex.EncodeToString(mac(key.Secret, "request", salt, timestamp, body)))
+}
+
+// VerifyRequest checks the signature headers of a request body
+func (s *Signer) VerifyRequest(header http.Header, salt string, body []byte) error {
+	timestamp := header.Get(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if skew := time.Since(time.Unix(seconds, 0)); skew > s.MaxSkew || skew < -s.MaxSkew {
+		return fmt.Errorf("%w: timestamp outside the allowed skew", ErrInvalidSignature)
+	}
+	return s.verify(header, "request", salt, timestamp, body)
+}
+
+// SignResponse sets the signature headers of a response body answering the request with salt
+func (s *Signer) SignResponse(header http.Header, salt string, body []byte) {
+	key := s.keys[0]
+	header.Set(HeaderKeyID, key.ID)
+	header.Set(HeaderSignature, hex.EncodeToString(mac(key.Secret, "response", salt, "", body)))
+}
+
+// VerifyResponse checks that a response body was signed for the request with salt
+func (s *Signer) VerifyResponse(header http.Header, salt string, body []byte) error {
+	return s.verify(header, "response", salt, "", body)
+}
+
+func (s *Signer) verify(header http.Header, kind, salt, timestamp string, body []byte) error {
+	key, ok := s.key(header.Get(HeaderKeyID))
+	if !ok {
+		return fmt.Errorf("%w: unknown key %q", ErrInvalidSignature, header.Get(HeaderKeyID))
+	}
+	signature, err := hex.DecodeString(header.Get(HeaderSignature))
+	if err != nil || !hmac.Equal(signature, mac(key.Secret, kind, salt, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *Signer) key(id string) (Key, bool) {
+	for _, k := range s.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// mac signs kind, salt and timestamp on their own lines followed by the body
+func mac(secret []byte, kind, salt, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(kind + "\n" + salt + "\n" + timestamp + "\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,137 @@
+package rng
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"k1:secret", []string{"k1"}, false},
+		{"k2:new, k1:old", []string{"k2", "k1"}, false},
+		{"k1:se:cret", []string{"k1"}, false},
+		{"k1", nil, true},
+		{":secret", nil, true},
+		{"k1:", nil, true},
+	}
+	for _, tt := range tests {
+		keys, err := ParseKeys(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseKeys(%q) err = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if len(keys) != len(tt.want) {
+			t.Errorf("ParseKeys(%q) = %d keys, want %d", tt.spec, len(keys), len(tt.want))
+			continue
+		}
+		for i, key := range keys {
+			if key.ID != tt.want[i] {
+				t.Errorf("ParseKeys(%q) key %d = %s, want %s", tt.spec, i, key.ID, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestNewSignerRejectsDuplicateKeys(t *testing.T) {
+	if _, err := NewSigner(nil); err == nil {
+		t.Error("NewSigner without keys succeeded")
+	}
+	if _, err := NewSigner([]Key{{ID: "k1", Secret: []byte("a")}, {ID: "k1", Secret: []byte("b")}}); err == nil {
+		t.Error("NewSigner with a duplicate key id succeeded")
+	}
+}
+
+func TestSignerRequests(t *testing.T) {
+	current, err := NewSigner([]Key{{ID: "k2", Secret: []byte("new")}, {ID: "k1", Secret: []byte("old")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := NewSigner([]Key{{ID: "k1", Secret: []byte("old")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"bet_id":"b1"}`)
+
+	tests := []struct {
+		name   string
+		signer *Signer
+		edit   func(h http.Header) (salt string, body []byte)
+		want   bool
+	}{
+		{"signed request", current, nil, true},
+		{"request signed with the previous key", previous, nil, true},
+		{"other body", current, func(h http.Header) (string, []byte) { return "salt", []byte(`{"bet_id":"b2"}`) }, false},
+		{"other salt", current, func(h http.Header) (string, []byte) { return "salt2", body }, false},
+		{"unknown key", current, func(h http.Header) (string, []byte) { h.Set(HeaderKeyID, "k9"); return "salt", body }, false},
+		{"no signature", current, func(h http.Header) (string, []byte) { h.Del(HeaderSignature); return "salt", body }, false},
+		{"stale timestamp", current, func(h http.Header) (string, []byte) {
+			h.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+			return "salt", body
+		}, false},
+		{"missing timestamp", current, func(h http.Header) (string, []byte) { h.Del(HeaderTimestamp); return "salt", body }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			tt.signer.SignRequest(header, "salt", body)
+			salt, sent := "salt", body
+			if tt.edit != nil {
+				salt, sent = tt.edit(header)
+			}
+			err := current.VerifyRequest(header, salt, sent)
+			if tt.want && err != nil {
+				t.Errorf("VerifyRequest: %v", err)
+			}
+			if !tt.want && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifyRequest = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func TestSignerResponses(t *testing.T) {
+	signer, err := NewSigner([]Key{{ID: "k1", Secret: []byte("secret")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"pref_outcome":"win"}`)
+	header := http.Header{}
+	signer.SignResponse(header, "salt", body)
+
+	tests := []struct {
+		name string
+		salt string
+		body []byte
+		want bool
+	}{
+		{"answer to the request", "salt", body, true},
+		{"answer replayed for another request", "salt2", body, false},
+		{"altered answer", "salt", []byte(`{"pref_outcome":"loss"}`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := signer.VerifyResponse(header, tt.salt, tt.body)
+			if tt.want && err != nil {
+				t.Errorf("VerifyResponse: %v", err)
+			}
+			if !tt.want && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifyResponse = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+
+	// A request signature is not a response signature
+	requestHeader := http.Header{}
+	signer.SignRequest(requestHeader, "salt", body)
+	if err := signer.VerifyResponse(requestHeader, "salt", body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("request signature accepted as a response signature: %v", err)
+	}
+}
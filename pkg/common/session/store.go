@@ -0,0 +1,41 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned when no session exists for a key
+var ErrNotFound = errors.New("session not found")
+
+// Store persists serialized round state between requests
+type Store interface {
+	// Load returns the stored state for key, or ErrNotFound
+	Load(key string) ([]byte, error)
+	// Save stores the state for key, replacing any previous value
+	Save(key string, data []byte) error
+	// Delete removes the state for key
+	Delete(key string) error
+}
+
+// keyEscaper escapes the separator, so "a/b" + "c" and "a" + "b/c" never share a key.
+// IDs without "/" or "%" keep the key they always had.
+var keyEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+// Key builds the session key for a player of an operator
+func Key(clientID, playerID string) string {
+	return keyEscaper.Replace(clientID) + "/" + keyEscaper.Replace(playerID)
+}
+
+// NewStore creates a store by kind ("memory" or "file")
+func NewStore(kind, dir string) (Store, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(dir)
+	default:
+		return nil, fmt.Errorf("unknown session store: %s", kind)
+	}
+}
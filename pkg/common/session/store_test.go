@@ -0,0 +1,71 @@
+package session
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name               string
+		clientID, playerID string
+		want               string
+	}{
+		{"plain IDs", "c1", "p1", "c1/p1"},
+		{"slash in client", "a/b", "c", "a%2Fb/c"},
+		{"slash in player", "a", "b/c", "a/b%2Fc"},
+		{"percent", "a%2Fb", "c", "a%252Fb/c"},
+		{"empty", "", "", "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key(tt.clientID, tt.playerID); got != tt.want {
+				t.Errorf("Key(%q, %q) = %q, want %q", tt.clientID, tt.playerID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyIsUnambiguous(t *testing.T) {
+	pairs := [][2]string{
+		{"a/b", "c"}, {"a", "b/c"}, {"a%2Fb", "c"}, {"a", "b%2Fc"}, {"a/", "b"}, {"a", "/b"},
+	}
+	seen := make(map[string][2]string)
+	for _, pair := range pairs {
+		key := Key(pair[0], pair[1])
+		if other, ok := seen[key]; ok {
+			t.Errorf("%q and %q share key %q", pair, other, key)
+		}
+		seen[key] = pair
+	}
+}
+
+func TestStores(t *testing.T) {
+	file, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Load("k"); err != ErrNotFound {
+				t.Fatalf("Load of a missing key = %v, want ErrNotFound", err)
+			}
+			if err := store.Save("k", []byte("v1")); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Save("k", []byte("v2")); err != nil {
+				t.Fatal(err)
+			}
+			data, err := store.Load("k")
+			if err != nil || string(data) != "v2" {
+				t.Fatalf("Load = %q, %v, want v2", data, err)
+			}
+			if err := store.Delete("k"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete("k"); err != nil {
+				t.Fatalf("second Delete = %v, want nil", err)
+			}
+			if _, err := store.Load("k"); err != ErrNotFound {
+				t.Fatalf("Load after Delete = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
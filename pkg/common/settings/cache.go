@@ -0,0 +1,157 @@
+package settings
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Key identifies one player's settings
+type Key struct {
+	ClientID string
+	GameID   string
+	PlayerID string
+}
+
+// Cache keeps settings responses for TTL. For a further Stale window an
+// expired entry is still served while one background call refreshes it.
+// Concurrent misses for the same key share a single call.
+type Cache struct {
+	TTL   time.Duration
+	Stale time.Duration
+
+	mu      sync.Mutex
+	entries map[Key]cacheEntry
+	calls   map[Key]*cacheCall
+	gen     uint64 // Bumped by Invalidate so calls already in flight do not store old settings
+	swept   time.Time
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	resp    Response
+	fetched time.Time
+}
+
+type cacheCall struct {
+	done chan struct{}
+	resp Response
+	err  error
+}
+
+// NewCache creates an empty cache
+func NewCache(ttl, stale time.Duration) *Cache {
+	return &Cache{
+		TTL:     ttl,
+		Stale:   stale,
+		entries: make(map[Key]cacheEntry),
+		calls:   make(map[Key]*cacheCall),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached settings for key, calling fetch when they are missing or expired
+func (c *Cache) Get(key Key, fetch func() (Response, error)) (Response, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok {
+		age := c.now().Sub(entry.fetched)
+		if age < c.TTL {
+			c.mu.Unlock()
+			return entry.resp, nil
+		}
+		if age < c.TTL+c.Stale {
+			if _, inFlight := c.calls[key]; !inFlight {
+				go c.refresh(key, c.start(key), fetch)
+			}
+			c.mu.Unlock()
+			return entry.resp, nil
+		}
+	}
+
+	call, inFlight := c.calls[key]
+	if !inFlight {
+		call = c.start(key)
+		c.mu.Unlock()
+		c.refresh(key, call, fetch)
+	} else {
+		c.mu.Unlock()
+	}
+	<-call.done
+	return call.resp, call.err
+}
+
+// start registers a call for key; c.mu must be held
+func (c *Cache) start(key Key) *cacheCall {
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	return call
+}
+
+// refresh runs fetch for a registered call and stores a successful result
+func (c *Cache) refresh(key Key, call *cacheCall, fetch func() (Response, error)) {
+	c.mu.Lock()
+	gen := c.gen
+	c.mu.Unlock()
+
+	call.resp, call.err = fetch()
+
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	if call.err == nil && gen == c.gen {
+		c.entries[key] = cacheEntry{resp: call.resp, fetched: c.now()}
+	} else if call.err != nil {
+		log.Printf("Settings refresh for player %s failed: %v", key.PlayerID, call.err)
+	}
+	c.sweep()
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// sweep drops entries too old to be served, at most once per TTL+Stale; c.mu must be held
+func (c *Cache) sweep() {
+	maxAge := c.TTL + c.Stale
+	now := c.now()
+	if now.Sub(c.swept) < maxAge {
+		return
+	}
+	c.swept = now
+	for key, entry := range c.entries {
+		if now.Sub(entry.fetched) >= maxAge {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Invalidate drops cached settings matching the key. Empty fields match any value,
+// so Key{ClientID: "c"} drops every player of client c and Key{} drops everything.
+// It returns the number of entries dropped.
+func (c *Cache) Invalidate(match Key) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for key := range c.calls {
+		if match.matches(key) {
+			// Later callers must not join a call that may return the old settings
+			delete(c.calls, key)
+		}
+	}
+	dropped := 0
+	for key := range c.entries {
+		if match.matches(key) {
+			delete(c.entries, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// matches reports whether key matches the pattern k, where empty fields match any value
+func (k Key) matches(key Key) bool {
+	return (k.ClientID == "" || k.ClientID == key.ClientID) &&
+		(k.GameID == "" || k.GameID == key.GameID) &&
+		(k.PlayerID == "" || k.PlayerID == key.PlayerID)
+}
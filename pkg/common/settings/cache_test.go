@@ -0,0 +1,218 @@
+package settings
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock the test moves by hand; refreshes read it from other goroutines
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// countingFetcher answers call n with RTP "n", optionally holding each call until release is closed
+type countingFetcher struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (f *countingFetcher) fetch() (Response, error) {
+	n := f.calls.Add(1)
+	if f.started != nil {
+		f.started <- struct{}{}
+	}
+	if f.release != nil {
+		<-f.release
+	}
+	var resp Response
+	resp.Data.GameRTP = strconv.Itoa(int(n))
+	return resp, f.err
+}
+
+func newTestCache(ttl, stale time.Duration) (*Cache, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := NewCache(ttl, stale)
+	cache.now = clock.Now
+	return cache, clock
+}
+
+// waitIdle waits until no call for key is in flight, so a background refresh has stored its result
+func waitIdle(t *testing.T, c *Cache, key Key) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		_, inFlight := c.calls[key]
+		c.mu.Unlock()
+		if !inFlight {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refresh did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+var testKey = Key{ClientID: "c1", GameID: "g1", PlayerID: "p1"}
+
+func TestCacheExpiry(t *testing.T) {
+	steps := []struct {
+		name        string
+		after       time.Duration // Clock advance before the Get
+		want        string        // RTP served
+		wantFetches int32         // Fetches once any refresh has finished
+	}{
+		{"miss", 0, "1", 1},
+		{"fresh", 59 * time.Second, "1", 1},
+		{"stale, served while refreshing", 2 * time.Second, "1", 2},
+		{"refreshed", time.Second, "2", 2},
+		{"stale again", 61 * time.Second, "2", 3},
+		{"past the stale window", 3 * time.Minute, "4", 4},
+	}
+	cache, clock := newTestCache(time.Minute, time.Minute)
+	fetcher := &countingFetcher{}
+	for _, step := range steps {
+		clock.Advance(step.after)
+		resp, err := cache.Get(testKey, fetcher.fetch)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		waitIdle(t, cache, testKey)
+		if resp.Data.GameRTP != step.want {
+			t.Errorf("%s: served %q, want %q", step.name, resp.Data.GameRTP, step.want)
+		}
+		if got := fetcher.calls.Load(); got != step.wantFetches {
+			t.Errorf("%s: %d fetches, want %d", step.name, got, step.wantFetches)
+		}
+	}
+}
+
+func TestCacheDoesNotStoreErrors(t *testing.T) {
+	cache, _ := newTestCache(time.Minute, time.Minute)
+	fetcher := &countingFetcher{err: errors.New("unavailable")}
+	for i := 1; i <= 2; i++ {
+		if _, err := cache.Get(testKey, fetcher.fetch); err == nil {
+			t.Fatalf("get %d: no error", i)
+		}
+		if got := fetcher.calls.Load(); got != int32(i) {
+			t.Errorf("get %d: %d fetches, want %d", i, got, i)
+		}
+	}
+}
+
+func TestCacheCollapsesConcurrentMisses(t *testing.T) {
+	cache, _ := newTestCache(time.Minute, time.Minute)
+	fetcher := &countingFetcher{started: make(chan struct{}, 1), release: make(chan struct{})}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	served := make([]string, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := cache.Get(testKey, fetcher.fetch)
+			if err != nil {
+				t.Error(err)
+			}
+			served[i] = resp.Data.GameRTP
+		}()
+	}
+	<-fetcher.started
+	// Callers that arrive after the release are served from the cache, so the count holds either way
+	time.Sleep(10 * time.Millisecond)
+	close(fetcher.release)
+	wg.Wait()
+
+	if got := fetcher.calls.Load(); got != 1 {
+		t.Errorf("%d fetches for %d concurrent misses, want 1", got, callers)
+	}
+	for i, rtp := range served {
+		if rtp != "1" {
+			t.Errorf("caller %d served %q, want %q", i, rtp, "1")
+		}
+	}
+}
+
+func TestCacheInvalidateDuringFetch(t *testing.T) {
+	tests := []struct {
+		name  string
+		match Key
+	}{
+		{"same player", testKey},
+		{"whole client", Key{ClientID: testKey.ClientID}},
+		{"everything", Key{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, clock := newTestCache(time.Minute, time.Minute)
+			old := &countingFetcher{}
+			if _, err := cache.Get(testKey, old.fetch); err != nil {
+				t.Fatal(err)
+			}
+
+			// A refresh of the stale entry is in flight when the settings change
+			old.started, old.release = make(chan struct{}, 1), make(chan struct{})
+			clock.Advance(90 * time.Second)
+			if _, err := cache.Get(testKey, old.fetch); err != nil {
+				t.Fatal(err)
+			}
+			<-old.started
+			cache.mu.Lock()
+			call := cache.calls[testKey]
+			cache.mu.Unlock()
+			if dropped := cache.Invalidate(tt.match); dropped != 1 {
+				t.Errorf("Invalidate dropped %d entries, want 1", dropped)
+			}
+			close(old.release)
+			<-call.done
+
+			// The refresh started before Invalidate must not have been stored
+			fresh := &countingFetcher{}
+			resp, err := cache.Get(testKey, fresh.fetch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fresh.calls.Load(); got != 1 || resp.Data.GameRTP != "1" {
+				t.Errorf("after Invalidate: %d new fetches serving %q, want 1 serving %q", got, resp.Data.GameRTP, "1")
+			}
+		})
+	}
+}
+
+func TestCacheInvalidateLeavesOtherPlayers(t *testing.T) {
+	cache, _ := newTestCache(time.Minute, time.Minute)
+	fetcher := &countingFetcher{}
+	if _, err := cache.Get(testKey, fetcher.fetch); err != nil {
+		t.Fatal(err)
+	}
+	if dropped := cache.Invalidate(Key{ClientID: testKey.ClientID, PlayerID: "p2"}); dropped != 0 {
+		t.Errorf("Invalidate dropped %d entries, want 0", dropped)
+	}
+	if _, err := cache.Get(testKey, fetcher.fetch); err != nil {
+		t.Fatal(err)
+	}
+	if got := fetcher.calls.Load(); got != 1 {
+		t.Errorf("%d fetches, want 1", got)
+	}
+}
@@ -3,10 +3,14 @@ package settings
 import (
     "bytes"
     "encoding/json"
-    "errors"
+    "fmt"
     "log"
+    "math"
     "net/http"
+    "sort"
     "strconv"
+    "strings"
+    "time"
 
     "github.com/cenkalti/backoff/v4"
 )
@@ -14,12 +18,31 @@ import (
 // Client for game settings service
 type Client struct {
     ServiceURL string
+    HTTPClient *http.Client // Timeout bounds every attempt
+    Cache      *Cache       // Optional; nil calls the service every time
+    Backoff    Backoff
+}
+
+// Backoff is the retry schedule of settings calls. Only transport failures,
+// 5xx and 429 replies are retried.
+type Backoff struct {
+    MaxRetries      int           // Retries after the first attempt
+    InitialInterval time.Duration // Wait before the first retry
+    MaxInterval     time.Duration // Longest wait between retries
+    Multiplier      float64       // Growth of the wait after each retry
 }
 
 // NewClient creates a new settings client
 func NewClient(serviceURL string) *Client {
     return &Client{
         ServiceURL: serviceURL,
+        HTTPClient: &http.Client{Timeout: 5 * time.Second},
+        Backoff: Backoff{
+            MaxRetries:      3,
+            InitialInterval: 500 * time.Millisecond,
+            MaxInterval:     5 * time.Second,
+            Multiplier:      1.5,
+        },
     }
 }
 
@@ -39,50 +62,161 @@ type Response struct {
 
 // GetRTP retrieves the RTP settings for a player with retry logic (Improvement #4)
 func (c *Client) GetRTP(clientID, gameID, playerID string) (float64, error) {
-    reqBody, err := json.Marshal(Request{
-        ClientID: clientID,
-        GameID:   gameID,
-        PlayerID: playerID,
+    gameSettings, err := c.GetGameSettings(clientID, gameID, playerID)
+    if err != nil {
+        return 0, err
+    }
+    return gameSettings.RTP, nil
+}
+
+// getSettings returns the player's settings from the cache, calling the service on a miss
+func (c *Client) getSettings(req Request) (Response, error) {
+    if c.Cache == nil {
+        return c.fetch(req)
+    }
+    return c.Cache.Get(Key{ClientID: req.ClientID, GameID: req.GameID, PlayerID: req.PlayerID}, func() (Response, error) {
+        return c.fetch(req)
     })
+}
+
+// fetch calls the settings service, retrying with exponential backoff.
+// Replies that fail validation are returned as errors, so they are never cached.
+func (c *Client) fetch(req Request) (Response, error) {
+    reqBody, err := json.Marshal(req)
     if err != nil {
         log.Printf("Error marshaling settings request: %v", err)
-        return 0, err
+        return Response{}, err
     }
 
     log.Printf("Settings request: %s", string(reqBody))
 
     var settingsResp Response
     operation := func() error {
-        resp, err := http.Post(c.ServiceURL, "application/json", bytes.NewBuffer(reqBody))
+        resp, err := c.httpClient().Post(c.ServiceURL, "application/json", bytes.NewBuffer(reqBody))
         if err != nil {
             log.Printf("Error calling settings API: %v", err)
-            return err
+            return &TransportError{Err: err}
         }
         defer resp.Body.Close()
 
         if resp.StatusCode != http.StatusOK {
             log.Printf("Settings API returned non-200 status: %d", resp.StatusCode)
-            return errors.New("Settings API call failed")
+            statusErr := &StatusError{StatusCode: resp.StatusCode}
+            if !statusErr.Temporary() {
+                return backoff.Permanent(statusErr)
+            }
+            return statusErr
         }
 
+        settingsResp = Response{}
         if err := json.NewDecoder(resp.Body).Decode(&settingsResp); err != nil {
             log.Printf("Error decoding settings response: %v", err)
-            return err
+            return backoff.Permanent(&DecodeError{Err: err})
+        }
+        if _, err := settingsResp.Parse(); err != nil {
+            log.Printf("Settings API returned invalid settings: %v", err)
+            return backoff.Permanent(err)
         }
 
         return nil
     }
 
-    // Retry with exponential backoff
-    err = backoff.Retry(operation, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 3))
+    err = backoff.Retry(operation, c.Backoff.policy())
     if err != nil {
-        return 0, err
+        return Response{}, err
     }
+    return settingsResp, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+    if c.HTTPClient == nil {
+        return http.DefaultClient
+    }
+    return c.HTTPClient
+}
 
-    rtp, err := strconv.ParseFloat(settingsResp.Data.GameRTP, 64)
+// policy builds the backoff schedule of one call
+func (b Backoff) policy() backoff.BackOff {
+    policy := backoff.NewExponentialBackOff()
+    policy.InitialInterval = b.InitialInterval
+    policy.MaxInterval = b.MaxInterval
+    policy.Multiplier = b.Multiplier
+    policy.MaxElapsedTime = 0 // Bounded by MaxRetries instead
+    return backoff.WithMaxRetries(policy, uint64(max(0, b.MaxRetries)))
+}
+
+// GameSettings are a player's settings parsed into typed values
+type GameSettings struct {
+    Bets   []float64 // Allowed bet amounts, ascending
+    RTP    float64
+    MaxWin float64 // Most a single round may pay; 0 means no limit
+}
+
+// AllowsBet reports whether amount is on the bet ladder
+func (s GameSettings) AllowsBet(amount float64) bool {
+    for _, bet := range s.Bets {
+        if math.Abs(bet-amount) < 1e-9 {
+            return true
+        }
+    }
+    return false
+}
+
+// GetGameSettings retrieves and parses all settings of a player
+func (c *Client) GetGameSettings(clientID, gameID, playerID string) (GameSettings, error) {
+    settingsResp, err := c.getSettings(Request{
+        ClientID: clientID,
+        GameID:   gameID,
+        PlayerID: playerID,
+    })
     if err != nil {
-        log.Printf("Error parsing RTP value: %v", err)
-        return 0, err
+        return GameSettings{}, err
     }
-    return rtp, nil
-}
\ No newline at end of file
+
+    gameSettings, err := settingsResp.Parse()
+    if err != nil {
+        log.Printf("Error parsing settings of player %s: %v", playerID, err)
+        return GameSettings{}, err
+    }
+    return gameSettings, nil
+}
+
+// Parse converts the service's string fields: game_bets is a comma-separated
+// bet ladder, game_rtp the RTP and game_wins the maximum round win (empty or 0 for none)
+func (r Response) Parse() (GameSettings, error) {
+    var gameSettings GameSettings
+
+    for _, field := range strings.Split(r.Data.GameBets, ",") {
+        field = strings.TrimSpace(field)
+        if field == "" {
+            continue
+        }
+        bet, err := strconv.ParseFloat(field, 64)
+        if err != nil || bet <= 0 {
+            return GameSettings{}, &ValidationError{Field: "game_bets", Value: field, Reason: "not a positive bet amount"}
+        }
+        gameSettings.Bets = append(gameSettings.Bets, bet)
+    }
+    if len(gameSettings.Bets) == 0 {
+        return GameSettings{}, &ValidationError{Field: "game_bets", Value: r.Data.GameBets, Reason: "no bet amounts"}
+    }
+    sort.Float64s(gameSettings.Bets)
+
+    rtp, err := strconv.ParseFloat(strings.TrimSpace(r.Data.GameRTP), 64)
+    if err != nil {
+        return GameSettings{}, &ValidationError{Field: "game_rtp", Value: r.Data.GameRTP, Reason: "not a number"}
+    }
+    if rtp < MinRTP || rtp > MaxRTP {
+        return GameSettings{}, &ValidationError{Field: "game_rtp", Value: r.Data.GameRTP, Reason: fmt.Sprintf("outside %.2f-%.2f", MinRTP, MaxRTP)}
+    }
+    gameSettings.RTP = rtp
+
+    if wins := strings.TrimSpace(r.Data.GameWins); wins != "" {
+        maxWin, err := strconv.ParseFloat(wins, 64)
+        if err != nil || maxWin < 0 {
+            return GameSettings{}, &ValidationError{Field: "game_wins", Value: r.Data.GameWins, Reason: "not a non-negative amount"}
+        }
+        gameSettings.MaxWin = maxWin
+    }
+    return gameSettings, nil
+}
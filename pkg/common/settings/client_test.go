@@ -0,0 +1,189 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// The client logs every request
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// attempt is how the stub service answers one call
+type attempt struct {
+	status int
+	body   string
+	delay  time.Duration
+}
+
+func settingsBody(rtp string) string {
+	return fmt.Sprintf(`{"data":{"game_bets":"0.5,1","game_rtp":%q,"game_wins":""}}`, rtp)
+}
+
+var (
+	okAttempt      = attempt{status: http.StatusOK, body: settingsBody("0.96")}
+	timeoutAttempt = attempt{status: http.StatusOK, body: settingsBody("0.96"), delay: time.Second}
+)
+
+// newScriptedClient serves the attempts in order, repeating the last one, and counts the calls
+func newScriptedClient(t *testing.T, attempts ...attempt) (*Client, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := attempts[min(int(calls.Add(1)), len(attempts))-1]
+		select {
+		case <-time.After(a.delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(a.status)
+		fmt.Fprint(w, a.body)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL)
+	client.HTTPClient = &http.Client{Timeout: 50 * time.Millisecond}
+	client.Backoff = Backoff{MaxRetries: 2, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1}
+	return client, &calls
+}
+
+func TestFetchRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		attempts      []attempt
+		wantCalls     int32
+		wantErr       any // Pointer to the error type expected, nil for success
+		wantPermanent bool
+	}{
+		{"ok", []attempt{okAttempt}, 1, nil, false},
+		{"bad request", []attempt{{status: http.StatusBadRequest}}, 1, new(*StatusError), true},
+		{"not found", []attempt{{status: http.StatusNotFound}}, 1, new(*StatusError), true},
+		{"server error, then ok", []attempt{{status: http.StatusInternalServerError}, okAttempt}, 2, nil, false},
+		{"too many requests, then ok", []attempt{{status: http.StatusTooManyRequests}, okAttempt}, 2, nil, false},
+		{"unavailable on every attempt", []attempt{{status: http.StatusServiceUnavailable}}, 3, new(*StatusError), false},
+		{"timeout, then ok", []attempt{timeoutAttempt, okAttempt}, 2, nil, false},
+		{"timeout on every attempt", []attempt{timeoutAttempt}, 3, new(*TransportError), false},
+		{"body that does not decode", []attempt{{status: http.StatusOK, body: `{"data":`}}, 1, new(*DecodeError), true},
+		{"wrong field type", []attempt{{status: http.StatusOK, body: `{"data":{"game_rtp":0.96}}`}}, 1, new(*DecodeError), true},
+		{"lowest RTP", []attempt{{status: http.StatusOK, body: settingsBody("0.80")}}, 1, nil, false},
+		{"highest RTP", []attempt{{status: http.StatusOK, body: settingsBody("0.99")}}, 1, nil, false},
+		{"RTP below range", []attempt{{status: http.StatusOK, body: settingsBody("0.79")}}, 1, new(*ValidationError), true},
+		{"RTP above range", []attempt{{status: http.StatusOK, body: settingsBody("0.995")}}, 1, new(*ValidationError), true},
+		{"RTP as a percentage", []attempt{{status: http.StatusOK, body: settingsBody("96")}}, 1, new(*ValidationError), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, calls := newScriptedClient(t, tt.attempts...)
+			rtp, err := client.GetRTP("c1", "g1", "p1")
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d calls, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil || rtp < MinRTP || rtp > MaxRTP {
+					t.Errorf("GetRTP = %v, %v, want a valid RTP", rtp, err)
+				}
+				return
+			}
+			if !errors.As(err, tt.wantErr) {
+				t.Fatalf("GetRTP error = %v (%T), want %T", err, err, tt.wantErr)
+			}
+			if Permanent(err) != tt.wantPermanent {
+				t.Errorf("Permanent(%v) = %v, want %v", err, !tt.wantPermanent, tt.wantPermanent)
+			}
+		})
+	}
+}
+
+func TestCachedClientDoesNotStoreRejectedSettings(t *testing.T) {
+	client, calls := newScriptedClient(t, attempt{status: http.StatusOK, body: settingsBody("0.5")}, okAttempt)
+	client.Cache = NewCache(time.Minute, time.Minute)
+	if _, err := client.GetRTP("c1", "g1", "p1"); !Permanent(err) {
+		t.Fatalf("first call error = %v, want a permanent error", err)
+	}
+	if rtp, err := client.GetRTP("c1", "g1", "p1"); err != nil || rtp != 0.96 {
+		t.Errorf("second call = %v, %v, want 0.96", rtp, err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d calls, want 2", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		bets      string
+		rtp       string
+		wins      string
+		want      GameSettings
+		wantField string // Field of the ValidationError, empty for success
+	}{
+		{"ladder", "0.1,0.5,1", "0.96", "5000", GameSettings{Bets: []float64{0.1, 0.5, 1}, RTP: 0.96, MaxWin: 5000}, ""},
+		{"unsorted ladder with spaces", " 2, 0.1 ,1,", " 0.94 ", "", GameSettings{Bets: []float64{0.1, 1, 2}, RTP: 0.94}, ""},
+		{"no max win", "1", "0.96", "0", GameSettings{Bets: []float64{1}, RTP: 0.96}, ""},
+		{"empty ladder", "", "0.96", "", GameSettings{}, "game_bets"},
+		{"only separators", " , ,", "0.96", "", GameSettings{}, "game_bets"},
+		{"bet that is not a number", "0.1,one", "0.96", "", GameSettings{}, "game_bets"},
+		{"zero bet", "0,1", "0.96", "", GameSettings{}, "game_bets"},
+		{"negative bet", "-1,1", "0.96", "", GameSettings{}, "game_bets"},
+		{"missing RTP", "1", "", "", GameSettings{}, "game_rtp"},
+		{"RTP out of range", "1", "1.2", "", GameSettings{}, "game_rtp"},
+		{"negative max win", "1", "0.96", "-5", GameSettings{}, "game_wins"},
+		{"max win that is not a number", "1", "0.96", "lots", GameSettings{}, "game_wins"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp Response
+			resp.Data.GameBets, resp.Data.GameRTP, resp.Data.GameWins = tt.bets, tt.rtp, tt.wins
+			got, err := resp.Parse()
+			if tt.wantField != "" {
+				var validation *ValidationError
+				if !errors.As(err, &validation) || validation.Field != tt.wantField {
+					t.Fatalf("Parse error = %v, want a ValidationError of %s", err, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowsBet(t *testing.T) {
+	gameSettings := GameSettings{Bets: []float64{0.1, 0.5, 1, 2}}
+	tests := []struct {
+		amount float64
+		want   bool
+	}{
+		{0.1, true},
+		{0.5, true},
+		{2, true},
+		{0.3 - 0.2, true}, // Off by a rounding error
+		{0.2, false},
+		{1.5, false},
+		{3, false},
+		{0, false},
+		{-1, false},
+	}
+	for _, tt := range tests {
+		if got := gameSettings.AllowsBet(tt.amount); got != tt.want {
+			t.Errorf("AllowsBet(%v) = %v, want %v", tt.amount, got, tt.want)
+		}
+	}
+	if (GameSettings{}).AllowsBet(1) {
+		t.Error("an empty ladder allows a bet")
+	}
+}
@@ -0,0 +1,66 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Range of RTP values accepted from the settings service
+const (
+	MinRTP = 0.80
+	MaxRTP = 0.99
+)
+
+// TransportError is a settings call that did not get an answer from the service
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string { return "settings service unreachable: " + e.Err.Error() }
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// StatusError is a settings reply with a status other than 200
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("settings service returned status %d", e.StatusCode)
+}
+
+// Temporary reports whether the service may answer the same call differently later
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// DecodeError is a settings reply whose body is not a settings response
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string { return "invalid settings response: " + e.Err.Error() }
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// ValidationError is a settings value that is missing, malformed or out of range
+type ValidationError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// Permanent reports whether retrying the settings call cannot fix err: the service
+// refused the request with a 4xx status or answered with a payload that is not valid
+func Permanent(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return !status.Temporary()
+	}
+	var decode *DecodeError
+	var validation *ValidationError
+	return errors.As(err, &decode) || errors.As(err, &validation)
+}
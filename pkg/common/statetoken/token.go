@@ -0,0 +1,159 @@
+package statetoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned when a token is malformed or its signature does not verify
+	ErrInvalidToken = errors.New("invalid state token")
+	// ErrUnknownKey is returned when a token was signed with a key that is no longer configured
+	ErrUnknownKey = errors.New("state token signed with unknown key")
+	// ErrExpiredToken is returned when a token is older than the signer's TTL
+	ErrExpiredToken = errors.New("state token expired")
+)
+
+// Claims are the values bound into a state token.
+// The signature only proves the server issued the token; the caller checks Nonce against the
+// nonce it issued last, so a token can be used once and older tokens of the player are stale.
+type Claims struct {
+	KeyID     string `json:"kid"`
+	ClientID  string `json:"client_id"`
+	PlayerID  string `json:"player_id"`
+	BetID     string `json:"bet_id"`
+	Step      int    `json:"step"`
+	StateHash string `json:"state_hash"`
+	Nonce     string `json:"nonce"`
+	ExpiresAt int64  `json:"exp,omitempty"` // Unix seconds, set by Sign when the signer has a TTL
+}
+
+// Key is a named HMAC secret
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Signer signs and verifies state tokens.
+// The first key signs new tokens; every key verifies, so keys can be rotated without
+// invalidating rounds that are in flight.
+type Signer struct {
+	keys []Key
+
+	// TTL, when positive, limits how long a token is accepted after it was signed
+	TTL time.Duration
+
+	now func() time.Time
+}
+
+// NewSigner creates a signer from an ordered key list
+func NewSigner(keys []Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one state token key is required")
+	}
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if k.ID == "" || len(k.Secret) == 0 {
+			return nil, errors.New("state token keys need an id and a secret")
+		}
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicate state token key id: %s", k.ID)
+		}
+		seen[k.ID] = true
+	}
+	return &Signer{keys: keys, now: time.Now}, nil
+}
+
+// ParseKeys parses a "id:secret,id:secret" list, active key first
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("state token key %q is not in id:secret form", id)
+		}
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+// HashState returns the hex SHA-256 of a serialized state
+func HashState(state []byte) string {
+	sum := sha256.Sum256(state)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign issues a token for the claims using the active key
+func (s *Signer) Sign(claims Claims) (string, error) {
+	key := s.keys[0]
+	claims.KeyID = key.ID
+	if s.TTL > 0 {
+		claims.ExpiresAt = s.now().Add(s.TTL).Unix()
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac(key.Secret, encoded)), nil
+}
+
+// Verify checks the token signature and expiry and returns its claims
+func (s *Signer) Verify(token string) (Claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	key, ok := s.key(claims.KeyID)
+	if !ok {
+		return Claims{}, ErrUnknownKey
+	}
+	if !hmac.Equal(sig, mac(key.Secret, encoded)) {
+		return Claims{}, ErrInvalidToken
+	}
+	if s.TTL > 0 && (claims.ExpiresAt == 0 || s.now().Unix() > claims.ExpiresAt) {
+		return Claims{}, ErrExpiredToken
+	}
+	return claims, nil
+}
+
+func (s *Signer) key(id string) (Key, bool) {
+	for _, k := range s.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func mac(secret []byte, data string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
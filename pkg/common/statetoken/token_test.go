@@ -0,0 +1,146 @@
+package statetoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T, spec string) *Signer {
+	t.Helper()
+	keys, err := ParseKeys(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := newTestSigner(t, "k1:secret1,k0:secret0")
+	claims := Claims{ClientID: "c", PlayerID: "p", BetID: "b", Step: 3, StateHash: HashState([]byte("{}")), Nonce: "n"}
+	token, err := signer.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := signer.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims.KeyID = "k1"
+	if got != claims {
+		t.Errorf("Verify = %+v, want %+v", got, claims)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	signer := newTestSigner(t, "k1:secret1")
+	token, err := signer.Sign(Claims{ClientID: "c", PlayerID: "p", BetID: "b", Step: 1, Nonce: "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+	other := newTestSigner(t, "k1:other")
+	otherToken, _ := other.Sign(Claims{PlayerID: "p"})
+	unknown := newTestSigner(t, "k2:secret1")
+	unknownToken, _ := unknown.Sign(Claims{PlayerID: "p"})
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"no separator", payload, ErrInvalidToken},
+		{"payload not base64", "!!." + sig, ErrInvalidToken},
+		{"signature not base64", payload + ".!!", ErrInvalidToken},
+		{"payload not JSON", "bm90anNvbg." + sig, ErrInvalidToken},
+		{"edited payload", payload[:len(payload)-2] + "x" + payload[len(payload)-1:] + "." + sig, ErrInvalidToken},
+		{"other secret", otherToken, ErrInvalidToken},
+		{"unknown key", unknownToken, ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := signer.Verify(tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := newTestSigner(t, "k0:secret0")
+	token, err := old.Sign(Claims{PlayerID: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := newTestSigner(t, "k1:secret1,k0:secret0")
+	if _, err := rotated.Verify(token); err != nil {
+		t.Errorf("token of a retired signing key: %v", err)
+	}
+	newToken, _ := rotated.Sign(Claims{PlayerID: "p"})
+	if _, err := old.Verify(newToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token of the new key on the old signer: %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	signer := newTestSigner(t, "k1:secret1")
+	signer.TTL = time.Hour
+	now := time.Unix(1_700_000_000, 0)
+	signer.now = func() time.Time { return now }
+	token, err := signer.Sign(Claims{PlayerID: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  error
+	}{
+		{"fresh", 0, nil},
+		{"at expiry", time.Hour, nil},
+		{"expired", time.Hour + time.Second, ErrExpiredToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer.now = func() time.Time { return now.Add(tt.after) }
+			if _, err := signer.Verify(token); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// A token signed without expiry is refused once the signer has a TTL
+	signer.TTL = 0
+	unbounded, _ := signer.Sign(Claims{PlayerID: "p"})
+	signer.TTL = time.Hour
+	if _, err := signer.Verify(unbounded); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("token without expiry: %v, want ErrExpiredToken", err)
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []Key
+		ok   bool
+	}{
+		{"none", nil, false},
+		{"missing id", []Key{{Secret: []byte("s")}}, false},
+		{"missing secret", []Key{{ID: "k"}}, false},
+		{"duplicate id", []Key{{ID: "k", Secret: []byte("a")}, {ID: "k", Secret: []byte("b")}}, false},
+		{"valid", []Key{{ID: "k", Secret: []byte("a")}, {ID: "j", Secret: []byte("b")}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSigner(tt.keys); (err == nil) != tt.ok {
+				t.Errorf("NewSigner error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
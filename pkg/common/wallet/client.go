@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Client for the operator's seamless-wallet API.
+// It posts JSON to {ServiceURL}/debit, /credit, /rollback and /balance.
+type Client struct {
+	ServiceURL string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a new wallet client
+func NewClient(serviceURL string) *Client {
+	return &Client{
+		ServiceURL: serviceURL,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// response is the wallet API reply
+type response struct {
+	Status  string  `json:"status"` // "ok", "insufficient_funds" or "error"
+	Balance float64 `json:"balance"`
+	Message string  `json:"message"`
+}
+
+// Debit takes the bet from the player
+func (c *Client) Debit(ctx context.Context, txn Transaction) (float64, error) {
+	return c.call(ctx, "/debit", txn)
+}
+
+// Credit pays a win to the player
+func (c *Client) Credit(ctx context.Context, txn Transaction) (float64, error) {
+	return c.call(ctx, "/credit", txn)
+}
+
+// Rollback reverses an earlier transaction
+func (c *Client) Rollback(ctx context.Context, rb Rollback) (float64, error) {
+	return c.call(ctx, "/rollback", rb)
+}
+
+// Balance returns the player's current balance
+func (c *Client) Balance(ctx context.Context, clientID, playerID string) (float64, error) {
+	return c.call(ctx, "/balance", struct {
+		ClientID string `json:"client_id"`
+		PlayerID string `json:"player_id"`
+	}{clientID, playerID})
+}
+
+func (c *Client) call(ctx context.Context, path string, payload any) (float64, error) {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshaling wallet request: %v", err)
+		return 0, err
+	}
+
+	log.Printf("Wallet request %s: %s", path, string(reqBody))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.ServiceURL+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		log.Printf("Error calling wallet API: %v", err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var walletResp response
+	if err := json.NewDecoder(resp.Body).Decode(&walletResp); err != nil {
+		log.Printf("Error decoding wallet response (status %d): %v", resp.StatusCode, err)
+		return 0, fmt.Errorf("wallet API returned status %d", resp.StatusCode)
+	}
+
+	switch {
+	case walletResp.Status == "insufficient_funds" || resp.StatusCode == http.StatusPaymentRequired:
+		return walletResp.Balance, ErrInsufficientFunds
+	case walletResp.Status == "reversed" || resp.StatusCode == http.StatusGone:
+		return walletResp.Balance, ErrTransactionReversed
+	case resp.StatusCode == http.StatusConflict:
+		return walletResp.Balance, ErrTransactionConflict
+	case resp.StatusCode != http.StatusOK || walletResp.Status != "ok":
+		log.Printf("Wallet API returned status %d: %s", resp.StatusCode, walletResp.Message)
+		return 0, fmt.Errorf("wallet API call failed: %s", walletResp.Message)
+	}
+	return walletResp.Balance, nil
+}
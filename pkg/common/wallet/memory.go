@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+)
+
+// MemoryWallet is an in-memory wallet for tests and local development
+type MemoryWallet struct {
+	mu           sync.Mutex
+	balances     map[string]float64
+	transactions map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	player   string
+	delta    float64
+	reversed bool
+}
+
+// NewMemoryWallet creates an empty in-memory wallet
+func NewMemoryWallet() *MemoryWallet {
+	return &MemoryWallet{
+		balances:     make(map[string]float64),
+		transactions: make(map[string]memoryEntry),
+	}
+}
+
+func playerKey(clientID, playerID string) string {
+	return session.Key(clientID, playerID)
+}
+
+// SetBalance sets a player's balance
+func (w *MemoryWallet) SetBalance(clientID, playerID string, balance float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.balances[playerKey(clientID, playerID)] = balance
+}
+
+// Debit takes the bet from the player
+func (w *MemoryWallet) Debit(_ context.Context, txn Transaction) (float64, error) {
+	return w.apply(txn, -txn.Amount)
+}
+
+// Credit pays a win to the player
+func (w *MemoryWallet) Credit(_ context.Context, txn Transaction) (float64, error) {
+	return w.apply(txn, txn.Amount)
+}
+
+// Rollback reverses an earlier transaction. Rolling back an unknown transaction records it as
+// reversed, so a debit or credit that arrives after its rollback is refused.
+func (w *MemoryWallet) Rollback(_ context.Context, rb Rollback) (float64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	player := playerKey(rb.ClientID, rb.PlayerID)
+	entry, ok := w.transactions[rb.ReferenceID]
+	if !ok {
+		w.transactions[rb.ReferenceID] = memoryEntry{player: player, reversed: true}
+		return w.balances[player], nil
+	}
+	if entry.reversed {
+		return w.balances[player], nil
+	}
+	if entry.player != player {
+		return w.balances[player], ErrTransactionConflict
+	}
+
+	entry.reversed = true
+	w.transactions[rb.ReferenceID] = entry
+	w.balances[player] -= entry.delta
+	return w.balances[player], nil
+}
+
+// Balance returns the player's current balance
+func (w *MemoryWallet) Balance(_ context.Context, clientID, playerID string) (float64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.balances[playerKey(clientID, playerID)], nil
+}
+
+func (w *MemoryWallet) apply(txn Transaction, delta float64) (float64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	player := playerKey(txn.ClientID, txn.PlayerID)
+	if entry, ok := w.transactions[txn.TransactionID]; ok {
+		if entry.reversed {
+			return w.balances[player], ErrTransactionReversed
+		}
+		if entry.player != player || entry.delta != delta {
+			return w.balances[player], ErrTransactionConflict
+		}
+		return w.balances[player], nil
+	}
+	if w.balances[player]+delta < 0 {
+		return w.balances[player], ErrInsufficientFunds
+	}
+
+	w.transactions[txn.TransactionID] = memoryEntry{player: player, delta: delta}
+	w.balances[player] += delta
+	return w.balances[player], nil
+}
@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryWallet(t *testing.T) {
+	txn := func(id string, amount float64) Transaction {
+		return Transaction{TransactionID: id, ClientID: "c1", PlayerID: "p1", Amount: amount}
+	}
+	rollback := func(refID string) Rollback {
+		return Rollback{TransactionID: refID + ":rollback", ReferenceID: refID, ClientID: "c1", PlayerID: "p1"}
+	}
+	debit := func(id string, amount float64) func(*MemoryWallet) (float64, error) {
+		return func(w *MemoryWallet) (float64, error) { return w.Debit(context.Background(), txn(id, amount)) }
+	}
+	credit := func(id string, amount float64) func(*MemoryWallet) (float64, error) {
+		return func(w *MemoryWallet) (float64, error) { return w.Credit(context.Background(), txn(id, amount)) }
+	}
+	reverse := func(refID string) func(*MemoryWallet) (float64, error) {
+		return func(w *MemoryWallet) (float64, error) { return w.Rollback(context.Background(), rollback(refID)) }
+	}
+
+	tests := []struct {
+		name    string
+		setup   []func(*MemoryWallet) (float64, error)
+		call    func(*MemoryWallet) (float64, error)
+		want    float64
+		wantErr error
+	}{
+		{"debit", nil, debit("d1", 3), 7, nil},
+		{"credit", nil, credit("c1", 3), 13, nil},
+		{"insufficient funds", nil, debit("d1", 11), 10, ErrInsufficientFunds},
+		{"repeated debit", []func(*MemoryWallet) (float64, error){debit("d1", 3)}, debit("d1", 3), 7, nil},
+		{"reused with another amount", []func(*MemoryWallet) (float64, error){debit("d1", 3)}, debit("d1", 4), 7, ErrTransactionConflict},
+		{"debit reused as credit", []func(*MemoryWallet) (float64, error){debit("d1", 3)}, credit("d1", 3), 7, ErrTransactionConflict},
+		{"rollback", []func(*MemoryWallet) (float64, error){debit("d1", 3)}, reverse("d1"), 10, nil},
+		{"repeated rollback", []func(*MemoryWallet) (float64, error){debit("d1", 3), reverse("d1")}, reverse("d1"), 10, nil},
+		{"rollback of unknown transaction", nil, reverse("d1"), 10, nil},
+		{"debit after its rollback", []func(*MemoryWallet) (float64, error){debit("d1", 3), reverse("d1")}, debit("d1", 3), 10, ErrTransactionReversed},
+		{"debit after a rollback that came first", []func(*MemoryWallet) (float64, error){reverse("d1")}, debit("d1", 3), 10, ErrTransactionReversed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewMemoryWallet()
+			w.SetBalance("c1", "p1", 10)
+			for _, setup := range tt.setup {
+				if _, err := setup(w); err != nil {
+					t.Fatalf("setup: %v", err)
+				}
+			}
+			balance, err := tt.call(w)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if balance != tt.want {
+				t.Errorf("balance = %v, want %v", balance, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryWalletKeepsPlayersApart(t *testing.T) {
+	w := NewMemoryWallet()
+	w.SetBalance("c1", "p1", 10)
+	w.SetBalance("c1", "p2", 10)
+
+	if _, err := w.Debit(context.Background(), Transaction{TransactionID: "d1", ClientID: "c1", PlayerID: "p1", Amount: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Debit(context.Background(), Transaction{TransactionID: "d1", ClientID: "c1", PlayerID: "p2", Amount: 3}); !errors.Is(err, ErrTransactionConflict) {
+		t.Errorf("debit of another player's transaction = %v, want %v", err, ErrTransactionConflict)
+	}
+	if _, err := w.Rollback(context.Background(), Rollback{ReferenceID: "d1", ClientID: "c1", PlayerID: "p2"}); !errors.Is(err, ErrTransactionConflict) {
+		t.Errorf("rollback of another player's transaction = %v, want %v", err, ErrTransactionConflict)
+	}
+	if balance, _ := w.Balance(context.Background(), "c1", "p2"); balance != 10 {
+		t.Errorf("other player's balance = %v, want 10", balance)
+	}
+}
@@ -0,0 +1,50 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrInsufficientFunds is returned when a debit exceeds the player's balance
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrTransactionConflict is returned when a transaction ID is reused with a different amount or player
+	ErrTransactionConflict = errors.New("transaction id reused with different details")
+	// ErrTransactionReversed is returned when a transaction ID that was rolled back is used again
+	ErrTransactionReversed = errors.New("transaction id was rolled back")
+)
+
+// Transaction is a single money movement in a round.
+// TransactionID must be unique and stable across retries so the wallet can deduplicate it.
+type Transaction struct {
+	TransactionID string  `json:"transaction_id"`
+	RoundID       string  `json:"round_id"`
+	ClientID      string  `json:"client_id"`
+	GameID        string  `json:"game_id"`
+	PlayerID      string  `json:"player_id"`
+	Amount        float64 `json:"amount"`
+}
+
+// Rollback reverses an earlier transaction identified by ReferenceID
+type Rollback struct {
+	TransactionID string `json:"transaction_id"`
+	ReferenceID   string `json:"reference_id"`
+	RoundID       string `json:"round_id"`
+	ClientID      string `json:"client_id"`
+	GameID        string `json:"game_id"`
+	PlayerID      string `json:"player_id"`
+}
+
+// Wallet moves money for rounds and reports player balances.
+// Implementations must treat a repeated TransactionID as a no-op that returns the current balance,
+// and must refuse a TransactionID that was rolled back, even when the rollback arrived first.
+type Wallet interface {
+	// Debit takes the bet from the player and returns the new balance
+	Debit(ctx context.Context, txn Transaction) (float64, error)
+	// Credit pays a win to the player and returns the new balance
+	Credit(ctx context.Context, txn Transaction) (float64, error)
+	// Rollback reverses a debit or credit; rolling back an unknown transaction is not an error
+	Rollback(ctx context.Context, rb Rollback) (float64, error)
+	// Balance returns the player's current balance
+	Balance(ctx context.Context, clientID, playerID string) (float64, error)
+}
@@ -0,0 +1,34 @@
+package birdspartydeluxe
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterAdmin registers the operator endpoints, which expose audit data and change server
+// state. Every call must send "Authorization: Bearer <AdminToken>"; without a token they are disabled.
+// Mount them on an internal listener where one is available.
+func (rg *RouteGroup) RegisterAdmin(app *fiber.App) {
+	app.Get("/rounds/:id/replay", rg.requireAdmin, rg.ReplayHandler)
+	app.Post("/settings/birdspartydeluxe/invalidate", rg.requireAdmin, rg.InvalidateSettingsHandler)
+}
+
+// requireAdmin rejects requests that do not carry the operator token
+func (rg *RouteGroup) requireAdmin(c *fiber.Ctx) error {
+	if rg.AdminToken == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Admin endpoints are disabled",
+		})
+	}
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(rg.AdminToken)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid admin token",
+		})
+	}
+	return c.Next()
+}
@@ -0,0 +1,88 @@
+package birdspartydeluxe
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+)
+
+func TestReplayRequiresAdminToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"disabled without a token", "", "Bearer ", http.StatusForbidden},
+		{"no header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"token without scheme", "secret", "secret", http.StatusUnauthorized},
+		{"operator token", "secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(rg *RouteGroup) {
+				store, err := audit.NewFileStore(filepath.Join(t.TempDir(), "audit.jsonl"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { store.Close() })
+				rg.Audit = store
+				rg.AdminToken = tt.token
+			})
+			last := s.playRound(testPlayer, betID(1), nil)
+			roundID := last["gameState"].(map[string]any)["roundId"].(string)
+
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set("Authorization", tt.header)
+			}
+			status, data := s.do(http.MethodGet, "/rounds/"+roundID+"/replay", nil, header)
+			if status != tt.want {
+				t.Fatalf("replay = %d %s, want %d", status, data, tt.want)
+			}
+			if reply := mustJSON(t, data); status == http.StatusOK && reply["diverged"] != false {
+				t.Errorf("replay diverged: %s", data)
+			}
+		})
+	}
+}
+
+func TestInvalidateSettingsRequiresAdminToken(t *testing.T) {
+	tests := []struct {
+		name            string
+		header          string
+		want            int
+		wantInvalidated float64
+	}{
+		{"no header", "", http.StatusUnauthorized, 0},
+		{"wrong token", "Bearer wrong", http.StatusUnauthorized, 0},
+		{"operator token", "Bearer secret", http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(rg *RouteGroup) {
+				rg.SettingsProd.Cache = settings.NewCache(time.Minute, time.Minute)
+				rg.AdminToken = "secret"
+			})
+			// The spin caches the player's settings
+			s.playRound(testPlayer, betID(1), nil)
+
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set("Authorization", tt.header)
+			}
+			status, data := s.do(http.MethodPost, "/settings/birdspartydeluxe/invalidate", map[string]any{"client_id": testPlayer.ClientID}, header)
+			if status != tt.want {
+				t.Fatalf("invalidate = %d %s, want %d", status, data, tt.want)
+			}
+			if reply := mustJSON(t, data); status == http.StatusOK && reply["invalidated"] != tt.wantInvalidated {
+				t.Errorf("invalidated = %v, want %v", reply["invalidated"], tt.wantInvalidated)
+			}
+		})
+	}
+}
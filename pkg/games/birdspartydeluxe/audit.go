@@ -0,0 +1,72 @@
+package birdspartydeluxe
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
+)
+
+// stepTrace collects what a step did for its audit record
+type stepTrace struct {
+	Action      RoundAction
+	Before      GameState    // State as loaded, before the step ran
+	Connections []Connection // Paying connections with their final payouts
+	Outcome     *Outcome     // Nil when the step did not consult the RNG
+	RNGBypassed bool
+	Failure     FailurePolicy // Policy that decided the step, if settings or RNG were unavailable
+}
+
+// recordStep appends the audit record of a completed step
+func (rg *RouteGroup) recordStep(ref roundRef, trace stepTrace, after *GameState) error {
+	if rg.Audit == nil {
+		return nil
+	}
+
+	connections, err := json.Marshal(trace.Connections)
+	if err != nil {
+		return err
+	}
+	stateBefore, err := json.Marshal(trace.Before)
+	if err != nil {
+		return err
+	}
+	stateAfter, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	record := audit.Record{
+		RoundID:                 after.RoundID,
+		ClientID:                ref.ClientID,
+		GameID:                  ref.GameID,
+		PlayerID:                ref.PlayerID,
+		BetID:                   after.BetID,
+		Step:                    after.Step,
+		Action:                  string(trace.Action),
+		Time:                    time.Now().UTC(),
+		Seed:                    after.Seed,
+		OutputGrid:              after.Grid,
+		Connections:             connections,
+		Win:                     after.TotalWin,
+		BoomingReelsLevelBefore: trace.Before.FreeSpins.BoomingReelsLevel,
+		BoomingReelsLevelAfter:  after.FreeSpins.BoomingReelsLevel,
+		RNGBypassed:             trace.RNGBypassed,
+		FailurePolicy:           string(trace.Failure),
+		MathModel:               after.MathModel,
+		MathProfile:             after.MathProfile,
+		WeightProfile:           after.WeightProfile,
+		StateBefore:             stateBefore,
+		StateAfter:              stateAfter,
+	}
+	// A spin generates a fresh grid, so only round steps have an input grid
+	if trace.Action != ActionSpin {
+		record.InputGrid = trace.Before.Grid
+	}
+	if trace.Outcome != nil {
+		record.RTP = trace.Outcome.RTP
+		record.RNGRequest = &trace.Outcome.Request
+		record.RNGResponse = &trace.Outcome.Response
+	}
+	return rg.Audit.Append(record)
+}
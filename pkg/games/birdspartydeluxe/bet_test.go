@@ -0,0 +1,156 @@
+package birdspartydeluxe
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+)
+
+func TestValidateBetAmount(t *testing.T) {
+	gameSettings := settings.GameSettings{Bets: []float64{0.1, 1, 2}}
+	tests := []struct {
+		amount  float64
+		wantErr string
+	}{
+		{0.1, ""},
+		{1, ""},
+		{2, ""},
+		{0.5, "invalid bet amount, allowed values are 0.1, 1, 2"},
+		{3, "invalid bet amount, allowed values are 0.1, 1, 2"},
+		{0, "invalid bet amount, allowed values are 0.1, 1, 2"},
+	}
+	for _, tt := range tests {
+		err := validateBetAmount(tt.amount, gameSettings)
+		if got := errString(err); got != tt.wantErr {
+			t.Errorf("validateBetAmount(%v) = %q, want %q", tt.amount, got, tt.wantErr)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// A bet off the operator's ladder is refused before the round starts
+func TestSpinRejectsBetNotOnLadder(t *testing.T) {
+	s := newTestServer(t, nil)
+	body := stepBody(testPlayer, betID(1), nil)
+	body["bet_amount"] = 0.5
+	status, reply := s.post("/spin/birdspartydeluxe", body)
+	if status != http.StatusBadRequest || reply["message"] != "invalid bet amount, allowed values are 0.1, 1, 2" {
+		t.Fatalf("spin = %d %v, want 400 with the allowed bets", status, reply["message"])
+	}
+	if _, err := s.rg.Sessions.Load(session.Key(testPlayer.ClientID, testPlayer.PlayerID)); !errors.Is(err, session.ErrNotFound) {
+		t.Error("the refused spin started a round")
+	}
+}
+
+func TestCapStep(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxWin     float64
+		roundWin   float64 // Won by the round's earlier steps
+		stepWin    float64
+		want       float64
+		wantCapped bool
+	}{
+		{"no limit", 0, 900, 500, 500, false},
+		{"under the limit", 1000, 100, 500, 500, false},
+		{"exactly the limit", 1000, 500, 500, 500, false},
+		{"over the limit", 1000, 800, 500, 200, true},
+		{"limit already reached", 1000, 1000, 500, 0, true},
+		{"large win of a single step", 50, 0, 12345.67, 50, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := GameState{MaxWin: tt.maxWin, RoundWin: tt.roundWin, TotalWin: tt.stepWin}
+			var result StepResult
+			capStep(&gs, &result)
+			if gs.TotalWin != tt.want || result.WinCapped != tt.wantCapped {
+				t.Errorf("step win = %v (capped %v), want %v (capped %v)", gs.TotalWin, result.WinCapped, tt.want, tt.wantCapped)
+			}
+		})
+	}
+}
+
+// No round pays more than the operator's maximum win, however its steps add up
+func TestRoundWinIsCappedThroughHandlers(t *testing.T) {
+	const maxWin = 0.5
+	s := newTestServer(t, nil)
+	s.settings.wins = "0.5"
+
+	capped := 0
+	for n := 1; n <= 300; n++ {
+		body := stepBody(testPlayer, betID(n), nil)
+		body["bet_amount"] = 2.0
+		status, reply := s.post("/spin/birdspartydeluxe", body)
+		for i := 0; ; i++ {
+			if status != http.StatusOK {
+				t.Fatalf("round %d step %d: %d %v", n, i, status, reply["message"])
+			}
+			state := reply["gameState"].(map[string]any)
+			roundWin := state["roundWin"].(float64)
+			if state["maxWin"] != maxWin || roundWin > maxWin {
+				t.Fatalf("round %d step %d: round win %v with max win %v, want at most %v", n, i, roundWin, state["maxWin"], maxWin)
+			}
+			if roundWin == maxWin {
+				capped++
+			}
+			path := nextStep(reply)
+			if path == "" {
+				break
+			}
+			status, reply = s.post(path, stepBody(testPlayer, betID(n), nil))
+		}
+	}
+	if capped == 0 {
+		t.Error("no round reached the maximum win")
+	}
+}
+
+func TestBetMultiplier(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   float64
+	}{
+		{BaseBetAmount, 1},
+		{0.2, 2},
+		{0.5, 5},
+		{1, 10},
+		{2, 20},
+		{0.15, 1.5},
+		{0.3, 3}, // 0.3/0.1 is 2.9999999999999996
+	}
+	for _, tt := range tests {
+		if got := BetMultiplier(tt.amount); got != tt.want {
+			t.Errorf("BetMultiplier(%v) = %v, want %v", tt.amount, got, tt.want)
+		}
+	}
+}
+
+// Every paytable entry pays in proportion to the bet
+func TestPayoutScalesWithBet(t *testing.T) {
+	for level, levelModel := range DefaultMathModel().Levels {
+		for symbol, payouts := range levelModel.Paytable {
+			for count, payout := range payouts {
+				base := calculatePayout(symbol, count, levelModel, 1)
+				if want := round(payout * 0.01); base != want {
+					t.Errorf("level %d %s x%d at the base bet = %v, want %v", level, symbol, count, base, want)
+				}
+				for _, amount := range []float64{0.5, 1, 2} {
+					got := calculatePayout(symbol, count, levelModel, BetMultiplier(amount))
+					if want := base * amount / BaseBetAmount; math.Abs(got-want) > 0.005 {
+						t.Errorf("level %d %s x%d at bet %v = %v, want %v", level, symbol, count, amount, got, want)
+					}
+				}
+			}
+		}
+	}
+}
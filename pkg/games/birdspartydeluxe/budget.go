@@ -0,0 +1,70 @@
+package birdspartydeluxe
+
+import "math"
+
+// baseSpinShare is the part of the budget a base game spin's decision may return. The rest is
+// kept for the free spins the spin may trigger and for the stage-cleared and cascade steps after it.
+const baseSpinShare = 0.5
+
+// The budget is the return the player's decisions may still pay, in currency. Each paid round adds
+// its stake at the settings RTP, and every decision, whether it is a spin, a stage-cleared or
+// cascade step or a free spin, asks the outcome provider for a part of it instead of a whole bet's
+// return. A decision takes out what it could pay on average, not what it paid, so the player's luck
+// never moves the budget. What a round leaves unspent carries over to the player's next round.
+
+// depositBudget adds what a paid round may return to the player's budget.
+// Free spins are not charged, so they play on what the round that triggered them left.
+func depositBudget(gs *GameState) {
+	gs.Budget += gs.RTP * gs.RoundCost
+}
+
+// stepBudget is the part of the budget the next decision of gs may return. Free spins share
+// what is left evenly with the free spins still to come, so the last one may return all of it.
+func stepBudget(gs *GameState) float64 {
+	budget := math.Max(0, gs.Budget)
+	if gs.FreeSpins.Remaining > 0 {
+		return budget / float64(gs.FreeSpins.Remaining)
+	}
+	return budget * baseSpinShare
+}
+
+// budgetShare is the part of a whole bet's return that budget gives a decision, the factor the
+// settings RTP is scaled by when the outcome provider is asked
+func budgetShare(gs *GameState, betAmount, budget float64) float64 {
+	if gs.RTP <= 0 || betAmount <= 0 {
+		return 0
+	}
+	return budget / (gs.RTP * betAmount)
+}
+
+// spendBudget takes a decision's expected payout out of the budget. A payout within the part it
+// was given may stand in full, so only the payout is taken; a larger one, or a win the provider
+// chooses, is allowed just often enough to return the whole part on average.
+func spendBudget(gs *GameState, budget, totalWinnings float64) {
+	if totalWinnings > 0 {
+		budget = math.Min(budget, totalWinnings)
+	}
+	gs.Budget -= budget
+}
+
+// settleBudget charges the budget with the difference between what a step paid and what its
+// decision asked for: a loss the grid could not be made to show, a target it missed, or the whole
+// payout of a step no decision covered, such as the new grid a level advance deals. The expected
+// payout only holds for what the decision controls, and a step that overpaid leaves a debt the
+// player's next rounds pay off.
+func settleBudget(gs *GameState, result StepResult) {
+	paid := gs.TotalWin
+	if gs.SpinFlow == SpinFlowCycle && result.Action == ActionSpin {
+		paid = result.chainWin
+	}
+	decided := paid
+	switch {
+	case result.Outcome == nil:
+		decided = 0
+	case result.Outcome.Response.PrefOutcome == "loss":
+		decided = 0
+	case result.TargetWin > 0:
+		decided = result.TargetWin
+	}
+	gs.Budget -= paid - decided
+}
@@ -0,0 +1,279 @@
+package birdspartydeluxe
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+)
+
+func TestStepBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    float64
+		remaining int // Free spins left, the one being played included
+		want      float64
+	}{
+		{"base spin keeps a share for free spins", 1, 0, baseSpinShare},
+		{"free spins share evenly", 0.9, 3, 0.3},
+		{"last free spin takes the rest", 0.9, 1, 0.9},
+		{"debt gives nothing", -0.5, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := InitializeGameState()
+			gs.Budget = tt.budget
+			gs.FreeSpins.Remaining = tt.remaining
+			if got := stepBudget(&gs); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("stepBudget = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpendBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		winnings float64
+		want     float64
+	}{
+		{"provider chooses the win", 0, 0.6},
+		{"payout within the part", 0.1, 0.9},
+		{"payout above the part", 3, 0.6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := GameState{Budget: 1}
+			spendBudget(&gs, 0.4, tt.winnings)
+			if math.Abs(gs.Budget-tt.want) > 1e-9 {
+				t.Errorf("budget = %v, want %v", gs.Budget, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettleBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		flow    SpinFlow
+		result  StepResult
+		outcome string // The decision's PrefOutcome, empty when none was asked for
+		paid    float64
+		want    float64
+	}{
+		{"allowed payout", SpinFlowLegacy, StepResult{Action: ActionCascade}, "win", 2, 1},
+		{"bypassed loss is charged", SpinFlowLegacy, StepResult{Action: ActionCascade, RNGBypassed: true}, "loss", 2, -1},
+		{"target met", SpinFlowRNGFirst, StepResult{Action: ActionSpin, TargetWin: 2}, "win", 2, 1},
+		{"missed target is given back", SpinFlowRNGFirst, StepResult{Action: ActionSpin, TargetWin: 2}, "win", 0, 3},
+		{"undecided payout is charged", SpinFlowLegacy, StepResult{Action: ActionStageCleared, LevelAdvanced: true}, "", 2, -1},
+		{"cycle spin settles its chain", SpinFlowCycle, StepResult{Action: ActionSpin, TargetWin: 2, chainWin: 2.5}, "win", 0, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := GameState{Budget: 1, SpinFlow: tt.flow, TotalWin: tt.paid}
+			if tt.outcome != "" {
+				tt.result.Outcome = &Outcome{Response: rng.Response{PrefOutcome: tt.outcome}}
+			}
+			settleBudget(&gs, tt.result)
+			if math.Abs(gs.Budget-tt.want) > 1e-9 {
+				t.Errorf("budget = %v, want %v", gs.Budget, tt.want)
+			}
+		})
+	}
+}
+
+// Every decision, free spins and later steps included, is asked from the player's budget,
+// so whole rounds return the settings RTP rather than a multiple of it
+func TestSimulatedRoundRTPFollowsSettings(t *testing.T) {
+	for _, flow := range []SpinFlow{SpinFlowRNGFirst, SpinFlowLegacy, SpinFlowCycle} {
+		t.Run(string(flow), func(t *testing.T) {
+			report, err := Simulate(context.Background(), SimulationConfig{
+				Rounds:        10000,
+				Sessions:      10,
+				Seed:          "budget",
+				BetAmount:     BaseBetAmount,
+				RTP:           0.96,
+				SpinFlow:      flow,
+				WeightProfile: "production",
+				Random:        random.CryptoFactory{},
+				Provider: func(r random.Source) rng.OutcomeProvider {
+					// Wins of about one bet keep the variance of a short run low
+					return &rng.LocalProvider{Float64: r.Float64, Classes: []rng.OutcomeClass{{Name: "even", Min: 0.5, Max: 1.5, Weight: 1}}}
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(report.RoundRTP-report.TargetRTP) > 0.2 {
+				t.Errorf("Round RTP = %.4f, want within 0.2 of %.2f", report.RoundRTP, report.TargetRTP)
+			}
+			if report.RTPStdErr <= 0 || report.RTPStdErr > 0.2 {
+				t.Errorf("RTP standard error = %.4f, want above 0 and at most 0.2", report.RTPStdErr)
+			}
+		})
+	}
+}
+
+// recordingProvider passes every request on to a provider and keeps it
+type recordingProvider struct {
+	rng.OutcomeProvider
+	mu       sync.Mutex
+	requests []rng.Request
+}
+
+func (p *recordingProvider) Send(ctx context.Context, req rng.Request) (rng.Response, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+	return p.OutcomeProvider.Send(ctx, req)
+}
+
+// sent returns how many requests were sent and the ones after the first n
+func (p *recordingProvider) sent(n int) (int, []rng.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.requests), append([]rng.Request(nil), p.requests[n:]...)
+}
+
+// newBudgetServer runs the handlers with a local RNG whose every request is recorded
+func newBudgetServer(t *testing.T) (*testServer, *recordingProvider) {
+	t.Helper()
+	recorder := &recordingProvider{OutcomeProvider: &rng.LocalProvider{Float64: seededFloat64(3)}}
+	s := newTestServer(t, func(rg *RouteGroup) {
+		rg.RNGProd, rg.RNGTest = recorder, recorder
+	})
+	return s, recorder
+}
+
+// storedState is the player's state as the session store keeps it, budget included
+func (s *testServer) storedState(p player) GameState {
+	s.t.Helper()
+	gameState, err := s.rg.loadGameState(roundRef{ClientID: p.ClientID, GameID: p.GameID, PlayerID: p.PlayerID})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return gameState
+}
+
+// budgetStep is one handler call of a round, the player's state around it and what it asked the RNG
+type budgetStep struct {
+	path          string
+	before, after GameState
+	requests      []rng.Request
+}
+
+// playBudgetRound plays betID to its end through the handlers, one budgetStep per call
+func (s *testServer) playBudgetRound(recorder *recordingProvider, p player, betID string) []budgetStep {
+	s.t.Helper()
+	path, body := "/spin/birdspartydeluxe", stepBody(p, betID, map[string]any{"bet_amount": 1.0})
+	var steps []budgetStep
+	for path != "" {
+		before := s.storedState(p)
+		n, _ := recorder.sent(0)
+		status, reply := s.post(path, body)
+		if status != http.StatusOK {
+			s.t.Fatalf("%s of %s: %d %v", path, betID, status, reply["message"])
+		}
+		_, requests := recorder.sent(n)
+		steps = append(steps, budgetStep{path: path, before: before, after: s.storedState(p), requests: requests})
+		path, body = nextStep(reply), stepBody(p, betID, nil)
+	}
+	return steps
+}
+
+// askedBudget is the part of the budget a request asked the provider to return, in currency,
+// as the request's RTP is the settings RTP scaled by the part's share of a whole bet's return
+func askedBudget(req rng.Request) float64 {
+	return req.RTP * req.BetAmount
+}
+
+// Rounds played through the handlers ask every decision from the budget the player's earlier
+// rounds and steps left, and a level advance's grid, which no decision covers, is charged to it
+func TestBudgetThroughHandlers(t *testing.T) {
+	s, recorder := newBudgetServer(t)
+	// levelAdvances counts the level advances whose new grid pays
+	var stageCleared, levelAdvances, freeSpins int
+	carried := 0.0 // Left by the player's previous round
+	for n := 1; stageCleared == 0 || levelAdvances == 0 || freeSpins == 0; n++ {
+		if n > 3000 {
+			t.Fatalf("after %d rounds: %d stage-cleared steps, %d paying level advances, %d free spins, want each", n, stageCleared, levelAdvances, freeSpins)
+		}
+		steps := s.playBudgetRound(recorder, testPlayer, betID(n))
+		for i, step := range steps {
+			decided := step.before
+			if i == 0 {
+				if math.Abs(step.before.Budget-carried) > 1e-9 {
+					t.Fatalf("round %d starts with budget %v, the previous round left %v", n, step.before.Budget, carried)
+				}
+				// The spin deposits its stake at the settings RTP before it is decided
+				decided.Budget += step.after.RTP * step.after.RoundCost
+				if step.after.RoundCost == 0 {
+					freeSpins++
+				}
+			}
+			if len(step.requests) > 0 {
+				if got, want := askedBudget(step.requests[0]), stepBudget(&decided); math.Abs(got-want) > 1e-9 {
+					t.Fatalf("round %d, %s asked for %v of the budget, want %v", n, step.path, got, want)
+				}
+			}
+			if step.path == "/process-stage-cleared/birdspartydeluxe" {
+				stageCleared++
+				if step.after.CurrentLevel != step.before.CurrentLevel && step.after.TotalWin > 0 {
+					levelAdvances++
+					if len(step.requests) > 0 {
+						t.Fatalf("round %d: level advance asked the RNG", n)
+					}
+					if got, want := step.after.Budget, step.before.Budget-step.after.TotalWin; math.Abs(got-want) > 1e-9 {
+						t.Fatalf("round %d: level advance paying %v left budget %v, want %v", n, step.after.TotalWin, got, want)
+					}
+				}
+			}
+		}
+		carried = steps[len(steps)-1].after.Budget
+	}
+}
+
+// A player in debt is not granted anything until paid spins have paid the debt off
+func TestBudgetDebtIsPaidOffThroughHandlers(t *testing.T) {
+	s, recorder := newBudgetServer(t)
+	s.playRound(testPlayer, betID(1), nil)
+	indebted := s.storedState(testPlayer)
+	indebted.Budget = -3
+	if _, err := s.rg.saveGameState(roundRef{ClientID: testPlayer.ClientID, GameID: testPlayer.GameID, PlayerID: testPlayer.PlayerID}, indebted); err != nil {
+		t.Fatal(err)
+	}
+
+	// Free spins deposit nothing, so only paid spins count towards the debt
+	for n, paid := 2, 0; ; n++ {
+		if paid > 10 {
+			t.Fatalf("a debt of 3 bets was not paid off after %d paid spins", paid)
+		}
+		steps := s.playBudgetRound(recorder, testPlayer, betID(n))
+		first, last := steps[0], steps[len(steps)-1]
+		deposit := first.after.RTP * first.after.RoundCost
+		if deposit > 0 {
+			paid++
+		}
+		if first.before.Budget+deposit > 0 {
+			// Paid off: the round's spin is granted a part of what is left
+			if first.requests[0].RTP <= 0 {
+				t.Errorf("round %d with budget %v asked for no return", n, first.before.Budget+deposit)
+			}
+			break
+		}
+		for _, step := range steps {
+			for _, req := range step.requests {
+				if req.RTP != 0 {
+					t.Fatalf("round %d, %s asked for RTP %v while the player was in debt", n, step.path, req.RTP)
+				}
+			}
+		}
+		if last.after.Budget > first.before.Budget+deposit+1e-9 {
+			t.Fatalf("round %d took the budget from %v to %v, more than its deposit %v", n, first.before.Budget, last.after.Budget, deposit)
+		}
+	}
+}
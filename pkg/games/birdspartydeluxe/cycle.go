@@ -0,0 +1,188 @@
+package birdspartydeluxe
+
+import (
+	"errors"
+	"log"
+	"math"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+)
+
+// Search budget of a cycle spin
+const (
+	cycleCandidates = 150  // Spin grids whose whole cascade chain is played ahead
+	maxCycleSteps   = 100  // Longest cascade chain a candidate may have; a longer one is rejected
+	maxCycleWork    = 1500 // Later steps played ahead for all the candidates of one spin together
+)
+
+var (
+	// ErrNoStepSources is returned when a cycle spin cannot play its later steps ahead
+	ErrNoStepSources = errors.New("cycle spins need the random sources of the later steps")
+	// ErrNoCycleChain is returned when no candidate's cascade chain ended within the search budget
+	ErrNoCycleChain = errors.New("no spin grid found whose cascade chain ends within the cycle search budget")
+
+	// errChainTruncated is returned by playAhead for a chain still going when its step limit is reached
+	errChainTruncated = errors.New("cascade chain played ahead is too long")
+)
+
+// playCycleSpin is the cycle spin flow: it asks the outcome provider once for the whole
+// game cycle, then tries spin grids, playing each one's cascade chain ahead with the
+// sources of the later steps, and keeps the grid whose round pays what was decided.
+// The later steps draw from the same sources, so they reveal exactly that chain.
+// A free spin is a cycle of its own, decided from the budget it shares with the other
+// free spins of its sequence. Candidates stop once maxCycleWork later steps were played
+// ahead, and a chain longer than maxCycleSteps is never chosen, as it was not fully checked.
+func playCycleSpin(gs *GameState, r random.Source, decide stepDecision, sources StepSources) (StepResult, error) {
+	result := StepResult{Action: ActionSpin}
+	if sources == nil {
+		return result, ErrNoStepSources
+	}
+	prepareSpin(gs)
+
+	// A zero payout asks the provider to choose the win of the whole cycle
+	decision, err := decide(gs.Bet.Amount, 0)
+	if err != nil {
+		return result, err
+	}
+	result.Outcome = decision
+	rngResp := decision.Response
+
+	loss := rngResp.PrefOutcome == "loss"
+	target := 0.0
+	if !loss && rngResp.WinAmount > 0 {
+		target = capWin(gs, round(rngResp.WinAmount))
+	}
+	met := func(roundWin float64) bool {
+		switch {
+		case loss:
+			return roundWin == 0
+		case target > 0:
+			return withinTarget(roundWin, target)
+		default:
+			// The provider allowed a win without naming it
+			return roundWin > 0
+		}
+	}
+	upper := math.Inf(1)
+	if loss {
+		upper = 0
+	} else if target > 0 {
+		upper = target + targetSlack(target)
+	}
+
+	var chosen, under, lowest [][]string
+	var chosenWin, underWin float64
+	underDiff, lowestWin := math.Inf(1), math.Inf(1)
+	work := 0
+	for candidate := 0; candidate < cycleCandidates && work < maxCycleWork; candidate++ {
+		grid := cycleGrid(gs, r, loss, target, candidate)
+		roundWin, steps, err := playAhead(gs, grid, sources, min(maxCycleSteps, maxCycleWork-work))
+		work += steps
+		if errors.Is(err, errChainTruncated) {
+			log.Printf("Cascade chain of spin grid %d is longer than %d steps, rejected", candidate+1, steps)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		if met(roundWin) {
+			log.Printf("Cycle win %.2f reached by spin grid %d", roundWin, candidate+1)
+			chosen, chosenWin = grid, roundWin
+			break
+		}
+		if diff := math.Abs(roundWin - target); roundWin <= upper && diff < underDiff {
+			under, underWin, underDiff = grid, roundWin, diff
+		}
+		if roundWin < lowestWin {
+			lowest, lowestWin = grid, roundWin
+		}
+	}
+
+	switch {
+	case chosen != nil:
+		result.TargetMet = true
+	case lowest == nil:
+		return result, ErrNoCycleChain
+	case under != nil:
+		log.Printf("Cycle target %.2f not reached, paying the closest chain", target)
+		chosen, chosenWin = under, underWin
+	default:
+		log.Printf("⚠️  RNG BYPASS: no spin grid found whose cascade chain pays a %s of %.2f, paying the lowest chain %.2f", rngResp.PrefOutcome, target, lowestWin)
+		chosen, chosenWin = lowest, lowestWin
+		result.RNGBypassed = true
+	}
+	result.chainWin = chosenWin
+	if target > 0 {
+		result.TargetWin = target
+	}
+
+	gs.Grid = chosen
+	switch {
+	case loss:
+		result.frame("loss", gs.Grid)
+	case target > 0:
+		result.frame("target", gs.Grid)
+	default:
+		result.frame("generated", gs.Grid)
+	}
+	finishSpin(gs, payGrid(gs), &result)
+	return result, nil
+}
+
+// cycleGrid draws the next candidate spin grid of a cycle spin
+func cycleGrid(gs *GameState, r random.Source, loss bool, target float64, candidate int) [][]string {
+	switch {
+	case loss:
+		return GenerateLossGrid(gs.level(), r, gs.GameMode)
+	case target <= 0 || candidate%3 == 0:
+		return GenerateGridWithWin(gs.level(), r, gs.GameMode)
+	case candidate%3 == 1:
+		return GenerateGrid(gs.level(), r, gs.GameMode)
+	}
+	// Large targets are rarely drawn by chance; seed them from the paytable instead
+	if grid, ok := clusterGrid(gs, r, target); ok {
+		return grid
+	}
+	return GenerateGrid(gs.level(), r, gs.GameMode)
+}
+
+// playAhead plays a copy of the round from the spin grid to the end of its cascade chain,
+// with the same engine and bookkeeping as the handlers, and returns what the round pays
+// and how many later steps were played. A chain that needs more than limit later steps
+// returns errChainTruncated.
+func playAhead(start *GameState, grid [][]string, sources StepSources, limit int) (float64, int, error) {
+	gs := cloneGameState(*start)
+	gs.Grid = copyGrid(grid)
+	var result StepResult
+	finishSpin(&gs, payGrid(&gs), &result)
+	capStep(&gs, &result)
+	gs.RoundWin = gs.TotalWin
+
+	steps := 0
+	for gs.Phase = NextPhase(&gs, result.HasStageCleared); !roundEnded(gs.Phase); gs.Phase = NextPhase(&gs, result.HasStageCleared) {
+		if steps >= limit {
+			return gs.RoundWin, steps, errChainTruncated
+		}
+		steps++
+		action := ActionCascade
+		if gs.Phase == PhaseAwaitingStageCleared {
+			action = ActionStageCleared
+		}
+		gs.Step++
+		r, err := sources(gs.Step)
+		if err != nil {
+			return 0, steps, err
+		}
+		if result, err = PlayStep(action, &gs, r, revealChain, nil); err != nil {
+			return 0, steps, err
+		}
+		gs.RoundWin = round(gs.RoundWin + gs.TotalWin)
+	}
+	return gs.RoundWin, steps, nil
+}
+
+// revealChain lets the natural payout of a later cycle step stand
+func revealChain(betAmount, totalWinnings, share float64) (*Outcome, error) {
+	return &Outcome{Response: rng.Response{PrefOutcome: "win"}}, nil
+}
@@ -0,0 +1,84 @@
+package birdspartydeluxe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+)
+
+func TestPlayAheadRejectsLongChains(t *testing.T) {
+	factory := random.CryptoFactory{}
+	seed := factory.DeriveSeed("cycle")
+	sources := func(step int) (random.Source, error) {
+		return factory.New(seed, step)
+	}
+	gs := InitializeGameState()
+	beginRound(&gs, "bet-1", BaseBetAmount, settings.GameSettings{RTP: 0.96}, SpinFlowCycle, DefaultMathModel(), "production")
+	r, err := sources(providerStep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A winning grid cascades into later steps; a losing one without stage-cleared symbols ends the round
+	chained := GenerateGridWithWin(gs.level(), r, gs.GameMode)
+	ended := GenerateLossGrid(gs.level(), r, gs.GameMode)
+	for len(FindStageClearedSymbols(ended, gs.level())) > 0 {
+		ended = GenerateLossGrid(gs.level(), r, gs.GameMode)
+	}
+
+	tests := []struct {
+		name    string
+		grid    [][]string
+		limit   int
+		wantErr error
+	}{
+		{"chain within the limit", chained, maxCycleSteps, nil},
+		{"chain over the limit", chained, 0, errChainTruncated},
+		{"round ended by the spin", ended, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, steps, err := playAhead(&gs, tt.grid, sources, tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("playAhead = %v, want %v", err, tt.wantErr)
+			}
+			if steps > tt.limit {
+				t.Errorf("played %d later steps, over the limit of %d", steps, tt.limit)
+			}
+		})
+	}
+}
+
+func TestCycleSpinWorkIsBounded(t *testing.T) {
+	factory := random.CryptoFactory{}
+	seed := factory.DeriveSeed("cycle-work")
+	played := 0
+	sources := func(step int) (random.Source, error) {
+		played++
+		return factory.New(seed, step)
+	}
+	gs := InitializeGameState()
+	beginRound(&gs, "bet-1", BaseBetAmount, settings.GameSettings{RTP: 0.96}, SpinFlowCycle, DefaultMathModel(), "production")
+	r, err := factory.New(seed, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No chain pays a target this large, so every candidate is tried until the work runs out
+	unreachable := func(betAmount, totalWinnings, share float64) (*Outcome, error) {
+		return &Outcome{Response: rng.Response{PrefOutcome: "win", WinAmount: 1e6}}, nil
+	}
+	result, err := PlayStep(ActionSpin, &gs, r, unreachable, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TargetMet {
+		t.Fatal("unreachable target was met")
+	}
+	if played > maxCycleWork {
+		t.Errorf("played %d later steps ahead, over the limit of %d", played, maxCycleWork)
+	}
+}
@@ -0,0 +1,791 @@
+package birdspartydeluxe
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+)
+
+// Outcome is the RNG decision for one step, kept for the audit record
+type Outcome struct {
+	RTP      float64
+	Request  rng.Request
+	Response rng.Response
+	Degraded bool // Decided under the degrade policy because settings or RNG were unavailable
+}
+
+// DecideFunc asks whether a step's payout may stand. The engine only calls it when
+// the step has paying connections. share scales the settings RTP the provider is asked
+// with: the part of a whole bet's return the player's budget gives the step.
+// The handlers back it with the RNG service; replay and verification back it with
+// the recorded responses.
+type DecideFunc func(betAmount, totalWinnings, share float64) (*Outcome, error)
+
+// stepDecision is a DecideFunc bound to the step's budget
+type stepDecision func(betAmount, totalWinnings float64) (*Outcome, error)
+
+// StepResult is what one step of the engine produced, besides the updated game state
+type StepResult struct {
+	Action              RoundAction
+	Connections         []Connection // Connections reported to the client and kept for the next cascade
+	CloverConnections   []Connection // Paying clover connections with their payouts
+	BirdConnections     []Connection // Paying bird connections with their payouts
+	StageClearedSymbols []StageClearedSymbol
+	HasStageCleared     bool // Whether the next call must be process-stage-cleared
+	StageClearedCount   int  // Symbols collected by a stage-cleared step
+	LevelAdvanced       bool
+	OldLevel            Level
+	NewLevel            Level
+	Outcome             *Outcome // Nil when the step had nothing to pay
+	RNGBypassed         bool     // The RNG asked for a loss that could not be applied
+	WinCapped           bool     // The step's win was cut to the round's MaxWin
+	TargetWin           float64  // Win amount the RNG asked for when the grid was steered towards it
+	TargetMet           bool     // The steered grid pays within TargetWinTolerance of TargetWin
+
+	chainWin float64 // What the cascade chain chosen by a cycle spin pays, the spin included
+
+	// How the grid got there, for replays
+	Frames  []Frame    // Intermediate grids in order
+	Removed []Position // Cells emptied before gravity
+	Moves   []Move     // Symbols dropped by gravity
+	Filled  []Position // Cells refilled with new symbols
+}
+
+// Frame is an intermediate grid of a step
+type Frame struct {
+	Stage string     `json:"stage"` // generated, removed, gravity, levelUp, loss or target
+	Grid  [][]string `json:"grid"`
+}
+
+// frame appends a copy of the grid as the next intermediate frame
+func (sr *StepResult) frame(stage string, grid [][]string) {
+	sr.Frames = append(sr.Frames, Frame{Stage: stage, Grid: copyGrid(grid)})
+}
+
+// PayingConnections returns the clover and bird connections that paid
+func (sr StepResult) PayingConnections() []Connection {
+	paying := make([]Connection, 0, len(sr.CloverConnections)+len(sr.BirdConnections))
+	paying = append(paying, sr.CloverConnections...)
+	return append(paying, sr.BirdConnections...)
+}
+
+// StepSources returns the random source of a later step of the round, so a cycle
+// spin can play ahead the steps its round will reveal
+type StepSources func(step int) (random.Source, error)
+
+// PlayStep runs the game logic of one round step on gs, drawing every symbol from r.
+// It leaves round bookkeeping (phase, round win, money) to the caller, so the
+// handlers, replay and verification all share the exact same engine.
+// sources is only used by the spin of a SpinFlowCycle round.
+func PlayStep(action RoundAction, gs *GameState, r random.Source, decide DecideFunc, sources StepSources) (StepResult, error) {
+	if err := gs.bindMathModel(); err != nil {
+		return StepResult{}, err
+	}
+
+	// Cycle rounds were decided by their spin, so later steps only reveal the chain it played ahead
+	revealed := gs.SpinFlow == SpinFlowCycle && action != ActionSpin
+	if revealed {
+		decide = revealChain
+	}
+
+	// The RNG is asked about the payout the player can actually receive, from the player's budget
+	capped := func(betAmount, totalWinnings float64) (*Outcome, error) {
+		totalWinnings = capWin(gs, totalWinnings)
+		if revealed {
+			return decide(betAmount, totalWinnings, 0)
+		}
+		budget := stepBudget(gs)
+		decision, err := decide(betAmount, totalWinnings, budgetShare(gs, betAmount, budget))
+		if err != nil {
+			return nil, err
+		}
+		spendBudget(gs, budget, totalWinnings)
+		return decision, nil
+	}
+
+	var result StepResult
+	var err error
+	switch {
+	case action == ActionSpin && gs.SpinFlow == SpinFlowCycle:
+		result, err = playCycleSpin(gs, r, capped, sources)
+	case action == ActionSpin:
+		result, err = playSpin(gs, r, capped)
+	case action == ActionStageCleared:
+		result, err = playStageCleared(gs, r, capped)
+	case action == ActionCascade:
+		result, err = playCascade(gs, r, capped)
+	default:
+		return StepResult{}, fmt.Errorf("unknown round action: %s", action)
+	}
+	if err != nil {
+		return result, err
+	}
+	if revealed {
+		result.Outcome = nil
+	}
+
+	capStep(gs, &result)
+	if !revealed {
+		settleBudget(gs, result)
+	}
+	return result, nil
+}
+
+// capStep cuts the step's win to what is left of the round's MaxWin
+func capStep(gs *GameState, result *StepResult) {
+	if win := capWin(gs, gs.TotalWin); win < gs.TotalWin {
+		log.Printf("Step win %.2f capped to %.2f by the maximum round win %.2f", gs.TotalWin, win, gs.MaxWin)
+		gs.TotalWin = win
+		result.WinCapped = true
+	}
+}
+
+// capWin limits a step's win to what is left of the round's MaxWin
+func capWin(gs *GameState, win float64) float64 {
+	if gs.MaxWin <= 0 {
+		return win
+	}
+	remaining := round(max(0, gs.MaxWin-gs.RoundWin))
+	if win > remaining {
+		return remaining
+	}
+	return win
+}
+
+// playSpin generates the grid of a new spin and pays its connections.
+// The round's SpinFlow decides whether the grid is generated before or after the RNG decision.
+func playSpin(gs *GameState, r random.Source, decide stepDecision) (StepResult, error) {
+	result := StepResult{Action: ActionSpin}
+	prepareSpin(gs)
+
+	var spin spinGrid
+	var err error
+	if gs.SpinFlow == SpinFlowRNGFirst {
+		spin, err = decideThenGenerate(gs, r, decide, &result)
+	} else {
+		spin, err = generateThenDecide(gs, r, decide, &result)
+	}
+	if err != nil {
+		return result, err
+	}
+	finishSpin(gs, spin, &result)
+	return result, nil
+}
+
+// prepareSpin sets up gs for the grid of a new spin
+func prepareSpin(gs *GameState) {
+	// Ensure grid size matches current level
+	expectedGridSize := gs.level().GridSize
+	if gs.GridSize != expectedGridSize {
+		gs.GridSize = expectedGridSize
+		log.Printf("Corrected grid size to %d for level %d", expectedGridSize, gs.CurrentLevel)
+	}
+
+	// Set bet multiplier
+	gs.Bet.Multiplier = BetMultiplier(gs.Bet.Amount)
+
+	// DELUXE: Reset booming reels multiplier for new spin (cascade sequence resets)
+	ResetBoomingReels(gs)
+}
+
+// finishSpin settles the spin on the grid it paid: cascading, free spins and the step result
+func finishSpin(gs *GameState, spin spinGrid, result *StepResult) {
+	stageClearedSymbols := spin.stageClearedSymbols
+	allConnections := spin.allConnections
+	cloverConnections := spin.cloverConnections
+	birdConnections := spin.birdConnections
+	totalWinnings := spin.totalWinnings
+
+	// Reset cascade count for new spin
+	gs.CascadeCount = 0
+	gs.TotalWin = totalWinnings
+	gs.LastConnections = allConnections // Store all connections for cascade processing
+	gs.Cascading = len(allConnections) > 0
+
+	// DELUXE: Check for free game symbols (rainbow eggs) - separate from clovers
+	freeGameCount := CountFreeGameSymbols(gs.Grid)
+	if gs.GameMode == "base" && freeGameCount > 0 {
+		// Trigger free spins with rainbow egg
+		gs.GameMode = "freeSpins"
+		gs.FreeSpins.Remaining = gs.math().FreeSpinsAwarded
+		gs.FreeSpins.TotalAwarded = gs.math().FreeSpinsAwarded
+		log.Printf("Free Spins triggered by rainbow egg, current booming reels multiplier: %.1fx", gs.FreeSpins.CurrentMultiplier)
+	}
+
+	// Update free spins count (DELUXE: no re-triggering during free spins)
+	if gs.GameMode == "freeSpins" {
+		gs.FreeSpins.Remaining--
+		if gs.FreeSpins.Remaining <= 0 {
+			gs.GameMode = "base"
+			// Reset free spins data but keep booming reels for this cascade sequence
+			gs.FreeSpins.Remaining = 0
+			gs.FreeSpins.TotalAwarded = 0
+			log.Printf("Free Spins ended, booming reels multiplier continues: %.1fx", gs.FreeSpins.CurrentMultiplier)
+		}
+	}
+
+	result.Connections = allConnections
+	result.CloverConnections = cloverConnections
+	result.BirdConnections = birdConnections
+	result.StageClearedSymbols = stageClearedSymbols
+	result.HasStageCleared = len(stageClearedSymbols) > 0
+}
+
+// spinGrid is the grid a spin settled on and what it pays
+type spinGrid struct {
+	stageClearedSymbols []StageClearedSymbol
+	allConnections      []Connection
+	cloverConnections   []Connection
+	birdConnections     []Connection
+	totalWinnings       float64
+}
+
+// decideThenGenerate is the RNG-first spin flow: it asks the outcome provider for the
+// spin's outcome before any symbol is drawn, then generates a grid of that outcome class
+func decideThenGenerate(gs *GameState, r random.Source, decide stepDecision, result *StepResult) (spinGrid, error) {
+	// A zero payout asks the provider to choose the win
+	decision, err := decide(gs.Bet.Amount, 0)
+	if err != nil {
+		return spinGrid{}, err
+	}
+	result.Outcome = decision
+	rngResp := decision.Response
+
+	switch {
+	case rngResp.PrefOutcome == "loss":
+		log.Printf("RNG determined a loss outcome before generating the spin")
+		gs.Grid = GenerateLossGrid(gs.level(), r, gs.GameMode)
+		result.frame("loss", gs.Grid)
+	case rngResp.WinAmount > 0:
+		target := capWin(gs, round(rngResp.WinAmount))
+		log.Printf("RNG determined a %s win of %.2f before generating the spin", rngResp.OutcomeClass, target)
+		gs.Grid, result.TargetMet = GenerateGridForTarget(gs, r, target)
+		result.TargetWin = target
+		result.frame("target", gs.Grid)
+	default:
+		// The provider allowed a win without naming it, so the grid decides
+		gs.Grid = GenerateGridWithWin(gs.level(), r, gs.GameMode)
+		result.frame("generated", gs.Grid)
+	}
+	return payGrid(gs), nil
+}
+
+// payGrid pays the connections of the spin grid on gs
+func payGrid(gs *GameState) spinGrid {
+	allConnections := FindAllConnections(gs.Grid, gs.level())
+	cloverConnections, birdConnections, totalWinnings := payConnections(gs, allConnections)
+	stageClearedSymbols := FindStageClearedSymbols(gs.Grid, gs.level())
+	gs.StageClearedSymbols = stageClearedSymbols
+
+	return spinGrid{
+		stageClearedSymbols: stageClearedSymbols,
+		allConnections:      allConnections,
+		cloverConnections:   cloverConnections,
+		birdConnections:     birdConnections,
+		totalWinnings:       totalWinnings,
+	}
+}
+
+// generateThenDecide is the legacy spin flow: it generates a winning grid, then asks
+// the RNG whether its payout may stand and replaces it with a loss grid if not
+func generateThenDecide(gs *GameState, r random.Source, decide stepDecision, result *StepResult) (spinGrid, error) {
+	// DELUXE: Generate grid with potential connection-forming symbol connections (birds + clovers)
+	gs.Grid = GenerateGridWithWin(gs.level(), r, gs.GameMode)
+	result.frame("generated", gs.Grid)
+
+	// Find stage-cleared symbols (do NOT remove them yet)
+	stageClearedSymbols := FindStageClearedSymbols(gs.Grid, gs.level())
+	gs.StageClearedSymbols = stageClearedSymbols
+
+	// DELUXE: Find all connections and separate clover vs bird connections
+	allConnections := FindAllConnections(gs.Grid, gs.level())
+	cloverConnections, birdConnections := SeparateConnections(allConnections)
+
+	// DELUXE: Process clover connections first - they upgrade multiplier but pay base value only
+	totalWinnings := 0.0
+	for i, cloverConnection := range cloverConnections {
+		// Upgrade multiplier first
+		UpgradeBoomingReels(gs)
+		log.Printf("Clover connection found (%d symbols), multiplier upgraded to %.1fx",
+			cloverConnection.Count, gs.FreeSpins.CurrentMultiplier)
+
+		// Calculate clover payout - BASE VALUE ONLY, NO MULTIPLIER
+		payout := calculatePayout(cloverConnection.Symbol, cloverConnection.Count, gs.level(), gs.Bet.Multiplier)
+		// Clovers pay base value only - no booming reels multiplier applied
+		cloverConnections[i].Payout = payout
+		totalWinnings += payout
+	}
+
+	// Calculate total winnings from bird connections using current multiplier
+	for i, connection := range birdConnections {
+		payout := calculatePayout(connection.Symbol, connection.Count, gs.level(), gs.Bet.Multiplier)
+		// Apply booming reels multiplier to bird connections
+		payout *= gs.FreeSpins.CurrentMultiplier
+		birdConnections[i].Payout = payout
+		totalWinnings += payout
+	}
+	totalWinnings = round(totalWinnings)
+
+	// Get RTP and call RNG for ALL paying connections (birds + clovers)
+	allPayingConnections := append(cloverConnections, birdConnections...)
+	if len(allPayingConnections) > 0 {
+		decision, err := decide(gs.Bet.Amount, totalWinnings)
+		if err != nil {
+			return spinGrid{}, err
+		}
+		result.Outcome = decision
+		rngResp := decision.Response
+
+		// Adjust outcome based on RNG
+		if rngResp.PrefOutcome == "loss" {
+			log.Printf("RNG determined a loss outcome")
+			gs.Grid = GenerateLossGrid(gs.level(), r, gs.GameMode)
+			result.frame("loss", gs.Grid)
+
+			// Re-find stage-cleared symbols in loss grid
+			stageClearedSymbols = FindStageClearedSymbols(gs.Grid, gs.level())
+			gs.StageClearedSymbols = stageClearedSymbols
+
+			// Reset connections and winnings
+			allConnections = nil
+			cloverConnections = nil
+			birdConnections = nil
+			totalWinnings = 0
+
+			// Reset booming reels since we regenerated the grid
+			ResetBoomingReels(gs)
+		} else if target, ok := targetWin(gs, rngResp, totalWinnings); ok {
+			log.Printf("RNG asked for a win of %.2f, grid pays %.2f", target, totalWinnings)
+			// Build a new grid around the RNG's target, paying from fresh booming reels
+			ResetBoomingReels(gs)
+			gs.Grid, result.TargetMet = GenerateGridForTarget(gs, r, target)
+			result.TargetWin = target
+			result.frame("target", gs.Grid)
+
+			stageClearedSymbols = FindStageClearedSymbols(gs.Grid, gs.level())
+			gs.StageClearedSymbols = stageClearedSymbols
+			allConnections = FindAllConnections(gs.Grid, gs.level())
+			cloverConnections, birdConnections, totalWinnings = payConnections(gs, allConnections)
+		}
+	}
+
+	return spinGrid{
+		stageClearedSymbols: stageClearedSymbols,
+		allConnections:      allConnections,
+		cloverConnections:   cloverConnections,
+		birdConnections:     birdConnections,
+		totalWinnings:       totalWinnings,
+	}, nil
+}
+
+// playStageCleared removes the stage-cleared symbols, refills their columns and
+// advances the level once enough symbols have been collected
+func playStageCleared(gs *GameState, r random.Source, decide stepDecision) (StepResult, error) {
+	result := StepResult{Action: ActionStageCleared}
+
+	// Get stage-cleared symbols from the current grid
+	stageClearedSymbols := gs.StageClearedSymbols
+	if len(stageClearedSymbols) == 0 {
+		// If none provided, find them from the grid
+		stageClearedSymbols = FindStageClearedSymbols(gs.Grid, gs.level())
+	}
+
+	stageClearedCount := len(stageClearedSymbols)
+
+	// PRESERVE ORIGINAL GRID before processing
+	originalGrid := make([][]string, len(gs.Grid))
+	for i := range gs.Grid {
+		originalGrid[i] = make([]string, len(gs.Grid[i]))
+		copy(originalGrid[i], gs.Grid[i])
+	}
+
+	// Prepare variables for level advancement
+	var (
+		levelAdvanced bool
+		oldLevel      = gs.CurrentLevel
+		newLevel      = gs.CurrentLevel
+	)
+
+	// Process stage-cleared symbols (remove, apply gravity, check level advancement)
+	var newPositions []Position
+	{
+		// Remove stage-cleared symbols from grid surgically
+		RemoveStageClearedSymbolsSurgical(gs.Grid, stageClearedSymbols)
+		for _, stageSymbol := range stageClearedSymbols {
+			result.Removed = append(result.Removed, stageSymbol.Position)
+		}
+		result.frame("removed", gs.Grid)
+		result.Moves = GravityMoves(gs.Grid, result.Removed)
+		// Apply gravity surgically and get new positions
+		newPositions = ApplyGravitySurgical(gs.Grid, stageClearedSymbols, gs.level(), r, gs.GameMode)
+		result.Filled = newPositions
+		result.frame("gravity", gs.Grid)
+		// Update stage progress
+		gs.StageProgress += len(stageClearedSymbols)
+		stageProgressTarget := gs.math().StageProgressTarget
+		log.Printf("Added %d stage-cleared symbols to progress, total: %d/%d", len(stageClearedSymbols), gs.StageProgress, stageProgressTarget)
+		// Check for level advancement
+		if gs.StageProgress >= stageProgressTarget {
+			newLevel = AdvanceLevel(oldLevel)
+			excessProgress := gs.StageProgress - stageProgressTarget
+			UpdateGameStateForLevel(gs, newLevel)
+
+			if oldLevel == Level3 {
+				gs.StageProgress = 0 // Reset progress when looping from level 3 to 1
+			} else {
+				gs.StageProgress = excessProgress // Carry over excess progress otherwise
+			}
+
+			// Generate new grid for the new level
+			gs.Grid = GenerateGrid(gs.level(), r, gs.GameMode)
+			result.frame("levelUp", gs.Grid)
+			levelAdvanced = true
+			log.Printf("Level advanced from %d to %d, excess progress: %d", oldLevel, newLevel, excessProgress)
+
+			// --- NEW: Analyze the brand new grid for wins and special symbols ---
+			allConnections := FindAllConnections(gs.Grid, gs.level())
+			cloverConnections, birdConnections := SeparateConnections(allConnections)
+			stageClearedSymbolsAfterLevelUp := FindStageClearedSymbols(gs.Grid, gs.level())
+			gs.StageClearedSymbols = stageClearedSymbolsAfterLevelUp
+
+			// DELUXE: Process clover connections first - upgrade multiplier but pay base value only
+			totalWinnings := 0.0
+			for i, cloverConnection := range cloverConnections {
+				// Upgrade multiplier first
+				UpgradeBoomingReels(gs)
+				log.Printf("Clover connection found on new level (%d symbols), multiplier upgraded to %.1fx",
+					cloverConnection.Count, gs.FreeSpins.CurrentMultiplier)
+
+				// Calculate clover payout - BASE VALUE ONLY, NO MULTIPLIER
+				payout := calculatePayout(cloverConnection.Symbol, cloverConnection.Count, gs.level(), gs.Bet.Multiplier)
+				// Clovers pay base value only - no booming reels multiplier applied
+				cloverConnections[i].Payout = payout
+				totalWinnings += payout
+			}
+
+			// Calculate winnings from bird connections using current multiplier
+			for i, connection := range birdConnections {
+				payout := calculatePayout(connection.Symbol, connection.Count, gs.level(), gs.Bet.Multiplier)
+				payout *= gs.FreeSpins.CurrentMultiplier
+				birdConnections[i].Payout = payout
+				totalWinnings += payout
+			}
+
+			// DELUXE: Check for and trigger free spins on the new grid (rainbow eggs)
+			freeGameCount := CountFreeGameSymbols(gs.Grid)
+			if gs.GameMode == "base" && freeGameCount > 0 {
+				gs.GameMode = "freeSpins"
+				gs.FreeSpins.Remaining = gs.math().FreeSpinsAwarded
+				gs.FreeSpins.TotalAwarded = gs.math().FreeSpinsAwarded
+				log.Printf("Free Spins triggered on new level by rainbow egg, current booming reels multiplier: %.1fx", gs.FreeSpins.CurrentMultiplier)
+			}
+
+			// Update game state for the response
+			gs.TotalWin = round(totalWinnings)
+			gs.LastConnections = allConnections
+			gs.Cascading = len(allConnections) > 0
+			gs.CascadeCount = 0 // Reset for new level
+
+			result.Connections = allConnections
+			result.CloverConnections = cloverConnections
+			result.BirdConnections = birdConnections
+			result.StageClearedSymbols = stageClearedSymbolsAfterLevelUp
+			result.HasStageCleared = len(stageClearedSymbolsAfterLevelUp) > 0
+			result.StageClearedCount = stageClearedCount
+			result.LevelAdvanced = levelAdvanced
+			result.OldLevel = oldLevel
+			result.NewLevel = newLevel
+			return result, nil
+		}
+	}
+	// Clear the stage-cleared symbols from game state since they've been processed
+	gs.StageClearedSymbols = []StageClearedSymbol{}
+
+	// NOW check for connection-forming symbol connections in the new grid after gravity
+	allConnections := FindAllConnections(gs.Grid, gs.level())
+	cloverConnections, birdConnections := SeparateConnections(allConnections)
+	freeSpinsBefore := gs.FreeSpins
+
+	// DELUXE: Process clover connections first - upgrade multiplier but pay base value only
+	totalWinnings := 0.0
+	for i, cloverConnection := range cloverConnections {
+		// Upgrade multiplier first
+		UpgradeBoomingReels(gs)
+		log.Printf("Clover connection found after stage-cleared processing (%d symbols), multiplier upgraded to %.1fx",
+			cloverConnection.Count, gs.FreeSpins.CurrentMultiplier)
+
+		// Calculate clover payout - BASE VALUE ONLY, NO MULTIPLIER
+		payout := calculatePayout(cloverConnection.Symbol, cloverConnection.Count, gs.level(), gs.Bet.Multiplier)
+		// Clovers pay base value only - no booming reels multiplier applied
+		cloverConnections[i].Payout = payout
+		totalWinnings += payout
+	}
+
+	// Calculate total winnings from bird connections using current multiplier
+	for i, connection := range birdConnections {
+		payout := calculatePayout(connection.Symbol, connection.Count, gs.level(), gs.Bet.Multiplier)
+		// Apply booming reels multiplier to bird connections
+		payout *= gs.FreeSpins.CurrentMultiplier
+		birdConnections[i].Payout = payout
+		totalWinnings += payout
+	}
+	totalWinnings = round(totalWinnings)
+
+	// Handle RNG for ALL paying connections (birds + clovers) with surgical loss approach
+	allPayingConnections := append(cloverConnections, birdConnections...)
+	if len(allPayingConnections) > 0 {
+		decision, err := decide(gs.Bet.Amount, totalWinnings)
+		if err != nil {
+			return result, err
+		}
+		result.Outcome = decision
+		rngResp := decision.Response
+
+		// SURGICAL LOSS: Adjust outcome based on RNG while preserving grid structure
+		if rngResp.PrefOutcome == "loss" {
+			log.Printf("RNG determined a loss outcome for stage-cleared processing")
+			// Try surgical loss approach first (only new positions)
+			success := ApplySurgicalLoss(gs, originalGrid, stageClearedSymbols, gs.level(), r, newPositions)
+			if !success {
+				// If surgical loss is impossible, bypass RNG and allow the win
+				log.Printf("⚠️  RNG BYPASS: Surgical loss impossible after stage-cleared processing - preserving natural outcome")
+				log.Printf("⚠️  GRID PRESERVATION: Maintaining grid structure as surgical loss would break game mechanics")
+				log.Printf("⚠️  REASON: Stage-cleared symbol removal at positions %+v made loss impossible", stageClearedSymbols)
+				result.RNGBypassed = true
+				// Keep the original connections and winnings
+				// Grid remains as it is after stage-cleared processing
+			} else {
+				// Surgical loss successful - remove ALL paying connections but keep multiplier upgrades
+				cloverConnections = nil
+				birdConnections = nil
+				allConnections = nil // IMPORTANT: Clear allConnections when surgical loss is successful
+				totalWinnings = 0
+				log.Printf("Surgical loss applied successfully after stage-cleared processing (all paying connections)")
+				result.frame("loss", gs.Grid)
+
+				// IMPORTANT: Re-find connections in the modified grid to ensure consistency
+				allConnections = FindAllConnections(gs.Grid, gs.level())
+			}
+		} else if target, ok := targetWin(gs, rngResp, totalWinnings); ok {
+			log.Printf("RNG asked for a win of %.2f, stage-cleared processing pays %.2f", target, totalWinnings)
+			// Redraw only the refilled cells, paying from the booming reels the step started with
+			upgraded := gs.FreeSpins
+			gs.FreeSpins = freeSpinsBefore
+			changed, met := ApplyTargetForCascade(gs, newPositions, r, target)
+			result.TargetWin, result.TargetMet = target, met
+			if changed {
+				allConnections = FindAllConnections(gs.Grid, gs.level())
+				cloverConnections, birdConnections, totalWinnings = payConnections(gs, allConnections)
+				result.frame("target", gs.Grid)
+			} else {
+				log.Printf("⚠️  RNG BYPASS: Target win %.2f unreachable by redrawing new positions - preserving natural outcome", target)
+				gs.FreeSpins = upgraded
+				result.RNGBypassed = true
+			}
+		}
+	}
+
+	// Update game state with connection results
+	gs.TotalWin = totalWinnings
+	gs.LastConnections = allConnections // Store all connections for cascade processing
+	gs.Cascading = len(allConnections) > 0
+
+	// Reset cascade count since this is after stage-cleared processing
+	// if there is a win then cascade count to be 1 else 0
+	if len(allConnections) > 0 {
+		gs.CascadeCount = 1
+	} else {
+		gs.CascadeCount = 0
+	}
+
+	result.Connections = allConnections
+	result.CloverConnections = cloverConnections
+	result.BirdConnections = birdConnections
+	result.StageClearedCount = stageClearedCount
+	result.LevelAdvanced = levelAdvanced
+	result.OldLevel = oldLevel
+	result.NewLevel = newLevel
+	return result, nil
+}
+
+// playCascade removes the last paying connections, drops the columns and pays the new connections
+func playCascade(gs *GameState, r random.Source, decide stepDecision) (StepResult, error) {
+	result := StepResult{Action: ActionCascade}
+
+	// Increment cascade count
+	gs.CascadeCount++
+
+	// PRESERVE ORIGINAL GRID before processing for surgical loss capability
+	originalGrid := make([][]string, len(gs.Grid))
+	for i := range gs.Grid {
+		originalGrid[i] = make([]string, len(gs.Grid[i]))
+		copy(originalGrid[i], gs.Grid[i])
+	}
+
+	var allConnections []Connection
+	var cloverConnections []Connection
+	var birdConnections []Connection
+	var totalWinnings float64
+	var affectedPositions []Position
+	var newPositions []Position
+
+	// Process cascade surgically
+	if gs.CascadeCount >= 1 && len(gs.LastConnections) > 0 {
+		// SURGICAL: Remove previous connections and apply gravity surgically
+		affectedPositions = RemoveConnectionsSurgical(gs.Grid, gs.LastConnections)
+		result.Removed = affectedPositions
+		result.frame("removed", gs.Grid)
+		result.Moves = GravityMoves(gs.Grid, affectedPositions)
+		newPositions = ApplyGravitySurgicalForCascade(gs.Grid, affectedPositions, gs.level(), r, gs.GameMode)
+		result.Filled = newPositions
+		result.frame("gravity", gs.Grid)
+	} else {
+		// First cascade call - find existing connections
+		allConnections = FindAllConnections(gs.Grid, gs.level())
+		if len(allConnections) > 0 {
+			// Extract positions that will be affected for surgical processing
+			for _, connection := range allConnections {
+				affectedPositions = append(affectedPositions, connection.Positions...)
+			}
+			result.Moves = GravityMoves(gs.Grid, affectedPositions)
+			newPositions = ApplyGravitySurgicalForCascade(gs.Grid, affectedPositions, gs.level(), r, gs.GameMode)
+			result.Filled = newPositions
+			result.frame("gravity", gs.Grid)
+		}
+	}
+
+	// Find connection-forming symbol connections after cascade processing
+	if gs.CascadeCount >= 1 || len(allConnections) == 0 {
+		allConnections = FindAllConnections(gs.Grid, gs.level())
+	}
+
+	// DELUXE: Separate clover and bird connections
+	cloverConnections, birdConnections = SeparateConnections(allConnections)
+	freeSpinsBefore := gs.FreeSpins
+
+	// DELUXE: Process clover connections first - upgrade multiplier but pay base value only
+	totalWinnings = 0.0
+	for i, cloverConnection := range cloverConnections {
+		// Upgrade multiplier first
+		UpgradeBoomingReels(gs)
+		log.Printf("Clover connection found in cascade (%d symbols), multiplier upgraded to %.1fx",
+			cloverConnection.Count, gs.FreeSpins.CurrentMultiplier)
+
+		// Calculate clover payout - BASE VALUE ONLY, NO MULTIPLIER
+		payout := calculatePayout(cloverConnection.Symbol, cloverConnection.Count, gs.level(), gs.Bet.Multiplier)
+		// Clovers pay base value only - no booming reels multiplier applied
+		cloverConnections[i].Payout = payout
+		totalWinnings += payout
+	}
+
+	// Calculate total winnings from bird connections using current multiplier
+	for i, connection := range birdConnections {
+		payout := calculatePayout(connection.Symbol, connection.Count, gs.level(), gs.Bet.Multiplier)
+		// Apply booming reels multiplier to bird connections
+		payout *= gs.FreeSpins.CurrentMultiplier
+		birdConnections[i].Payout = payout
+		totalWinnings += payout
+	}
+	totalWinnings = round(totalWinnings)
+
+	// Handle RNG for ALL paying connections (birds + clovers) with surgical loss approach
+	allPayingConnections := append(cloverConnections, birdConnections...)
+	if len(allPayingConnections) > 0 {
+		decision, err := decide(gs.Bet.Amount, totalWinnings)
+		if err != nil {
+			return result, err
+		}
+		result.Outcome = decision
+		rngResp := decision.Response
+
+		// SURGICAL LOSS: Adjust outcome based on RNG while preserving grid structure
+		if rngResp.PrefOutcome == "loss" {
+			log.Printf("RNG determined a loss outcome for cascade")
+
+			// Try surgical loss approach first (only new positions)
+			success := ApplySurgicalLossForCascade(gs, originalGrid, newPositions, gs.level(), r)
+
+			if !success {
+				// If surgical loss is impossible, bypass RNG and allow the win
+				log.Printf("⚠️  RNG BYPASS: Surgical loss impossible after cascade processing - preserving natural outcome")
+				log.Printf("⚠️  GRID PRESERVATION: Maintaining grid structure as surgical loss would break game mechanics")
+				log.Printf("⚠️  REASON: Cascade processing at %d positions made loss impossible", len(affectedPositions))
+				result.RNGBypassed = true
+
+				// Keep the original connections and winnings
+				// Grid remains as it is after cascade processing
+			} else {
+				// Surgical loss successful - remove ALL paying connections but keep multiplier upgrades
+				cloverConnections = nil
+				birdConnections = nil
+				allConnections = nil // IMPORTANT: Clear allConnections when surgical loss is successful
+				totalWinnings = 0
+				log.Printf("Surgical loss applied successfully after cascade processing (all paying connections)")
+				result.frame("loss", gs.Grid)
+
+				// IMPORTANT: Re-find connections in the modified grid to ensure consistency
+				allConnections = FindAllConnections(gs.Grid, gs.level())
+			}
+		} else if target, ok := targetWin(gs, rngResp, totalWinnings); ok {
+			log.Printf("RNG asked for a win of %.2f, cascade pays %.2f", target, totalWinnings)
+			// Redraw only the refilled cells, paying from the booming reels the step started with
+			upgraded := gs.FreeSpins
+			gs.FreeSpins = freeSpinsBefore
+			changed, met := ApplyTargetForCascade(gs, newPositions, r, target)
+			result.TargetWin, result.TargetMet = target, met
+			if changed {
+				allConnections = FindAllConnections(gs.Grid, gs.level())
+				cloverConnections, birdConnections, totalWinnings = payConnections(gs, allConnections)
+				result.frame("target", gs.Grid)
+			} else {
+				log.Printf("⚠️  RNG BYPASS: Target win %.2f unreachable by redrawing new positions - preserving natural outcome", target)
+				gs.FreeSpins = upgraded
+				result.RNGBypassed = true
+			}
+		}
+	}
+
+	// IMPORTANT: Recombine processed clover and bird connections for lastConnections and response
+	// (Only if not already done by surgical loss)
+	if len(allConnections) == 0 && len(cloverConnections) > 0 || len(birdConnections) > 0 {
+		allConnections = append(cloverConnections, birdConnections...)
+	}
+
+	// IMPORTANT: After all processing, check for stage-cleared symbols that may have appeared
+	stageClearedSymbols := FindStageClearedSymbols(gs.Grid, gs.level())
+	hasStageCleared := len(stageClearedSymbols) > 0
+
+	// Store stage-cleared symbols in game state for potential next call to process-stage-cleared
+	gs.StageClearedSymbols = stageClearedSymbols
+
+	// DELUXE: Check for free game symbols (rainbow eggs) only if no connections and no RNG bypass
+	if len(allConnections) == 0 && !result.RNGBypassed {
+		freeGameCount := CountFreeGameSymbols(gs.Grid)
+		if gs.GameMode == "base" && freeGameCount > 0 {
+			gs.GameMode = "freeSpins"
+			gs.FreeSpins.Remaining = gs.math().FreeSpinsAwarded
+			gs.FreeSpins.TotalAwarded = gs.math().FreeSpinsAwarded
+			log.Printf("Free Spins triggered during cascade by rainbow egg, current booming reels multiplier: %.1fx", gs.FreeSpins.CurrentMultiplier)
+		}
+	}
+
+	// DELUXE: If no more connections, reset booming reels (cascade sequence ends)
+	if len(allConnections) == 0 {
+		log.Printf("Cascade sequence ended, resetting booming reels from %.1fx to 1.0x", gs.FreeSpins.CurrentMultiplier)
+		ResetBoomingReels(gs)
+	}
+
+	// Update game state
+	gs.TotalWin = totalWinnings
+	gs.LastConnections = allConnections
+	gs.Cascading = len(allConnections) > 0
+
+	result.Connections = allConnections
+	result.CloverConnections = cloverConnections
+	result.BirdConnections = birdConnections
+	result.StageClearedSymbols = stageClearedSymbols
+	result.HasStageCleared = hasStageCleared
+	return result, nil
+}
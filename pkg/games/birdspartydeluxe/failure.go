@@ -0,0 +1,98 @@
+package birdspartydeluxe
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/gofiber/fiber/v2"
+)
+
+// FailurePolicy decides what a step does when settings or RNG cannot decide its outcome
+type FailurePolicy string
+
+const (
+	FailClosed   FailurePolicy = "fail-closed" // Void the step with a 503; the round stays where it was and the call can be retried
+	FailDegrade  FailurePolicy = "degrade"     // Decide with the local outcome provider and flag the step in the audit trail
+	FailAutoLoss FailurePolicy = "auto-loss"   // Complete the round as a loss
+)
+
+// ParseFailurePolicy validates a configured failure policy
+func ParseFailurePolicy(value string) (FailurePolicy, error) {
+	switch policy := FailurePolicy(value); policy {
+	case FailClosed, FailDegrade, FailAutoLoss:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown failure policy: %s", value)
+	}
+}
+
+// playStep plays a step with the environment's outcome services. When they cannot decide it
+// under the auto-loss policy, the step is played again from its first draw as an auto-completed
+// loss. It also returns the policy that decided the step, if any, for the audit record.
+func (rg *RouteGroup) playStep(c *fiber.Ctx, clients clientSet, ref roundRef, action RoundAction, gameState *GameState, r random.Source) (StepResult, FailurePolicy, error) {
+	started := cloneGameState(*gameState)
+	sources := rg.stepSources(gameState.Seed)
+	result, err := PlayStep(action, gameState, r, decideWith(c, clients, ref, gameState.RTP), sources)
+	switch {
+	case err == nil && result.Outcome != nil && result.Outcome.Degraded:
+		return result, FailDegrade, nil
+	case err == nil:
+		return result, "", nil
+	case !serviceUnavailable(err) || clients.Failure != FailAutoLoss:
+		return result, "", err
+	}
+
+	log.Printf("⚠️  Outcome unavailable for bet %s, completing the round as a loss: %v", ref.BetID, err)
+	*gameState = started
+	r, err = rg.seededSource(gameState.Seed, gameState.Step)
+	if err != nil {
+		return StepResult{}, "", err
+	}
+	result, err = autoCompleteStep(action, gameState, r, sources)
+	return result, FailAutoLoss, err
+}
+
+// autoCompleteStep ends the round as a loss without asking for an outcome. A spin still draws
+// a losing grid for the player to see; stage-cleared and cascade steps leave the grid as it was.
+// Wins already added to the round are still paid.
+func autoCompleteStep(action RoundAction, gs *GameState, r random.Source, sources StepSources) (StepResult, error) {
+	result := StepResult{Action: action, OldLevel: gs.CurrentLevel, NewLevel: gs.CurrentLevel}
+	if action == ActionSpin {
+		// Nothing was asked of the player's budget
+		budget := gs.Budget
+		var err error
+		result, err = PlayStep(ActionSpin, gs, r, forcedLoss, sources)
+		if err != nil {
+			return result, err
+		}
+		gs.Budget = budget
+	}
+
+	gs.TotalWin = 0
+	gs.Cascading = false
+	gs.LastConnections = []Connection{}
+	gs.StageClearedSymbols = []StageClearedSymbol{}
+
+	result.Outcome = nil
+	result.Connections = []Connection{}
+	result.CloverConnections = nil
+	result.BirdConnections = nil
+	result.StageClearedSymbols = []StageClearedSymbol{}
+	result.HasStageCleared = false
+	return result, nil
+}
+
+// forcedLoss answers every decision of an auto-completed spin with a loss
+func forcedLoss(betAmount, totalWinnings, share float64) (*Outcome, error) {
+	return &Outcome{Response: rng.Response{PrefOutcome: "loss"}}, nil
+}
+
+// failureMessage tells the client when a step was not decided by the RNG service
+func failureMessage(policy FailurePolicy) string {
+	if policy == FailAutoLoss {
+		return "Round completed as a loss: outcome service unavailable"
+	}
+	return ""
+}
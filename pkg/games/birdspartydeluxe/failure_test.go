@@ -0,0 +1,95 @@
+package birdspartydeluxe
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    FailurePolicy
+		wantErr bool
+	}{
+		{"fail-closed", FailClosed, false},
+		{"degrade", FailDegrade, false},
+		{"auto-loss", FailAutoLoss, false},
+		{"", "", true},
+		{"fail-open", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFailurePolicy(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseFailurePolicy(%q) = %q, %v, want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFailurePolicies(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       FailurePolicy
+		settingsDown bool
+		wantStatus   int
+		wantPolicy   string // failure_policy of the spin's audit record
+		wantMessage  string
+	}{
+		{"fail-closed, RNG down", FailClosed, false, http.StatusServiceUnavailable, "", "RNG service unavailable"},
+		{"degrade, RNG down", FailDegrade, false, http.StatusOK, "degrade", ""},
+		{"auto-loss, RNG down", FailAutoLoss, false, http.StatusOK, "auto-loss", "Round completed as a loss: outcome service unavailable"},
+		// A spin cannot check its bet without settings, whatever the policy
+		{"degrade, settings down", FailDegrade, true, http.StatusServiceUnavailable, "", "Failed to retrieve game settings"},
+		{"auto-loss, settings down", FailAutoLoss, true, http.StatusServiceUnavailable, "", "Failed to retrieve game settings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := audit.NewFileStore(filepath.Join(t.TempDir(), "audit.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			var provider *unavailableProvider
+			s := newTestServer(t, func(rg *RouteGroup) {
+				provider = &unavailableProvider{OutcomeProvider: rg.RNGProd}
+				rg.RNGProd = provider
+				rg.FailurePolicyProd = tt.policy
+				rg.Audit = store
+			})
+			provider.down.Store(!tt.settingsDown)
+			if tt.settingsDown {
+				s.settings.status.Store(http.StatusInternalServerError)
+			}
+
+			body := stepBody(testPlayer, betID(1), nil)
+			body["bet_amount"] = 1.0
+			status, reply := s.post("/spin/birdspartydeluxe", body)
+			if status != tt.wantStatus {
+				t.Fatalf("spin = %d %v, want %d", status, reply["message"], tt.wantStatus)
+			}
+			if reply["message"] != tt.wantMessage {
+				t.Errorf("message = %q, want %q", reply["message"], tt.wantMessage)
+			}
+			if status != http.StatusOK {
+				if tt.wantStatus == http.StatusServiceUnavailable && reply["retryable"] != true {
+					t.Errorf("503 reply is not marked retryable: %v", reply)
+				}
+				return
+			}
+
+			state := reply["gameState"].(map[string]any)
+			records, err := store.Round(state["roundId"].(string))
+			if err != nil || len(records) != 1 {
+				t.Fatalf("audit records = %d, %v, want the spin's", len(records), err)
+			}
+			if records[0].FailurePolicy != tt.wantPolicy {
+				t.Errorf("audited failure_policy = %q, want %q", records[0].FailurePolicy, tt.wantPolicy)
+			}
+			if tt.policy == FailAutoLoss && (!roundEnded(RoundPhase(state["phase"].(string))) || state["roundWin"] != 0.0) {
+				t.Errorf("auto-loss spin left phase %v and roundWin %v, want an ended round without win", state["phase"], state["roundWin"])
+			}
+		})
+	}
+}
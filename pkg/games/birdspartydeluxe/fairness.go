@@ -0,0 +1,109 @@
+package birdspartydeluxe
+
+import (
+	"errors"
+	"log"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/fairness"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+	"github.com/gofiber/fiber/v2"
+)
+
+// FairnessSeedHandler handles the /fairness/birdspartydeluxe/seed endpoint.
+// It returns the hash of the player's server seed, their client seed and the next nonce.
+func (rg *RouteGroup) FairnessSeedHandler(c *fiber.Ctx) error {
+	req, err := parseFairnessRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	commitment, err := rg.Fairness.Commitment(session.Key(req.ClientID, req.PlayerID))
+	if err != nil {
+		log.Printf("Failed to load seeds of player %s: %v", req.PlayerID, err)
+		return fairnessError(c)
+	}
+	return c.JSON(FairnessResponse{Status: "success", Commitment: &commitment})
+}
+
+// FairnessRotateHandler handles the /fairness/birdspartydeluxe/rotate endpoint.
+// It reveals the player's server seed and commits to a new one with the supplied client seed.
+func (rg *RouteGroup) FairnessRotateHandler(c *fiber.Ctx) error {
+	req, err := parseFairnessRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	unlock := rg.lockPlayer(req.ClientID, req.PlayerID)
+	defer unlock()
+
+	revealed, commitment, err := rg.Fairness.Rotate(session.Key(req.ClientID, req.PlayerID), req.ClientSeed)
+	if err != nil {
+		log.Printf("Failed to rotate seeds of player %s: %v", req.PlayerID, err)
+		return fairnessError(c)
+	}
+	log.Printf("Rotated server seed of player %s: revealed %s, committed %s", req.PlayerID, revealed.ServerSeedHash, commitment.ServerSeedHash)
+	return c.JSON(FairnessResponse{Status: "success", Commitment: &commitment, Revealed: &revealed})
+}
+
+// FairnessRevealHandler handles the /fairness/birdspartydeluxe/reveal endpoint.
+// It returns a server seed of the player once it has been rotated out.
+func (rg *RouteGroup) FairnessRevealHandler(c *fiber.Ctx) error {
+	req, err := parseFairnessRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if req.ServerSeedHash == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "server_seed_hash is required",
+		})
+	}
+
+	revealed, err := rg.Fairness.Reveal(session.Key(req.ClientID, req.PlayerID), req.ServerSeedHash)
+	switch {
+	case errors.Is(err, fairness.ErrUnknownSeed):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	case errors.Is(err, fairness.ErrSeedActive):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	case err != nil:
+		log.Printf("Failed to reveal seed %s: %v", req.ServerSeedHash, err)
+		return fairnessError(c)
+	}
+	return c.JSON(FairnessResponse{Status: "success", Revealed: &revealed})
+}
+
+// parseFairnessRequest parses and validates the body of a fairness request
+func parseFairnessRequest(c *fiber.Ctx) (FairnessRequest, error) {
+	var req FairnessRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Printf("Failed to parse request body: %v", err)
+		return req, errors.New("Invalid request body")
+	}
+	if req.ClientID == "" || req.PlayerID == "" {
+		return req, errors.New("client_id and player_id are required")
+	}
+	return req, nil
+}
+
+// fairnessError reports a failure of the seed store
+func fairnessError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Failed to access provably-fair seeds",
+	})
+}
@@ -1,10 +1,11 @@
 package birdspartydeluxe
 
 import (
-	"fmt"
 	"log"
 	"math"
-	"math/rand"
+	"sort"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
 )
 
 // round rounds a float64 to two decimal places
@@ -13,17 +14,24 @@ func round(val float64) float64 {
 }
 
 // WeightedRandomSymbol selects a symbol based on level-specific weights
-func WeightedRandomSymbol(level Level, r *rand.Rand) Symbol {
-	weights := GetLevelSpecificWeights(level)
+func WeightedRandomSymbol(level *LevelModel, r random.Source) Symbol {
+	return pickWeightedSymbol(level.Weights, r)
+}
 
+// pickWeightedSymbol rolls one symbol from the weights, walking them in SymbolOrder so a seed always picks the same symbol
+func pickWeightedSymbol(weights map[Symbol]float64, r random.Source) Symbol {
 	totalWeight := 0.0
-	for _, weight := range weights {
-		totalWeight += weight
+	for _, symbol := range SymbolOrder {
+		totalWeight += weights[symbol]
 	}
 
 	roll := r.Float64() * totalWeight
 	currentWeight := 0.0
-	for symbol, weight := range weights {
+	for _, symbol := range SymbolOrder {
+		weight, ok := weights[symbol]
+		if !ok {
+			continue
+		}
 		currentWeight += weight
 		if roll <= currentWeight {
 			return symbol
@@ -57,32 +65,24 @@ func hasFreeGameSymbol(grid [][]string) bool {
 }
 
 // WeightedRandomSymbolWithControl controls special symbol generation
-func WeightedRandomSymbolWithControl(level Level, r *rand.Rand, forbidSpecialSymbols bool) Symbol {
-	weights := GetLevelSpecificWeights(level)
+func WeightedRandomSymbolWithControl(level *LevelModel, r random.Source, forbidSpecialSymbols bool) Symbol {
+	weights := level.Weights
 	if forbidSpecialSymbols {
-		delete(weights, SymbolFreeGame)
-		delete(weights, SymbolClover)
-	}
-
-	totalWeight := 0.0
-	for _, weight := range weights {
-		totalWeight += weight
-	}
-
-	roll := r.Float64() * totalWeight
-	currentWeight := 0.0
-	for symbol, weight := range weights {
-		currentWeight += weight
-		if roll <= currentWeight {
-			return symbol
+		// The model's weights are shared, so leave them untouched
+		weights = make(map[Symbol]float64, len(level.Weights))
+		for symbol, weight := range level.Weights {
+			if symbol != SymbolFreeGame && symbol != SymbolClover {
+				weights[symbol] = weight
+			}
 		}
 	}
-	return SymbolPurpleOwl // Fallback
+
+	return pickWeightedSymbol(weights, r)
 }
 
 // DELUXE: GenerateGrid - Modified to allow multiple clovers but limit free game symbols
-func GenerateGrid(level Level, r *rand.Rand, gameMode string) [][]string {
-	gridSize := level.GetGridSize()
+func GenerateGrid(level *LevelModel, r random.Source, gameMode string) [][]string {
+	gridSize := level.GridSize
 	grid := make([][]string, gridSize)
 	freeGameSymbolPlaced := false
 
@@ -102,9 +102,9 @@ func GenerateGrid(level Level, r *rand.Rand, gameMode string) [][]string {
 }
 
 // DELUXE: GenerateGridWithWin - Modified to allow connection-forming symbols (birds + clovers)
-func GenerateGridWithWin(level Level, r *rand.Rand, gameMode string) [][]string {
-	gridSize := level.GetGridSize()
-	log.Printf("Generating grid with win for level %d with grid size %dx%d", level, gridSize, gridSize)
+func GenerateGridWithWin(level *LevelModel, r random.Source, gameMode string) [][]string {
+	gridSize := level.GridSize
+	log.Printf("Generating grid with win for level %d with grid size %dx%d", level.Level, gridSize, gridSize)
 	maxAttempts := 100
 
 	for attempts := 0; attempts < maxAttempts; attempts++ {
@@ -121,9 +121,9 @@ func GenerateGridWithWin(level Level, r *rand.Rand, gameMode string) [][]string
 }
 
 // DELUXE: GenerateLossGrid - Modified to prevent connection-forming symbol connections
-func GenerateLossGrid(level Level, r *rand.Rand, gameMode string) [][]string {
-	gridSize := level.GetGridSize()
-	log.Printf("Generating loss grid for level %d with grid size %dx%d", level, gridSize, gridSize)
+func GenerateLossGrid(level *LevelModel, r random.Source, gameMode string) [][]string {
+	gridSize := level.GridSize
+	log.Printf("Generating loss grid for level %d with grid size %dx%d", level.Level, gridSize, gridSize)
 	maxAttempts := 100
 
 	for attempts := 0; attempts < maxAttempts; attempts++ {
@@ -140,13 +140,13 @@ func GenerateLossGrid(level Level, r *rand.Rand, gameMode string) [][]string {
 }
 
 // DELUXE: ForceWinGrid - Creates grid with guaranteed connections
-func ForceWinGrid(level Level, r *rand.Rand, gameMode string) [][]string {
-	gridSize := level.GetGridSize()
+func ForceWinGrid(level *LevelModel, r random.Source, gameMode string) [][]string {
+	gridSize := level.GridSize
 	grid := GenerateGrid(level, r, gameMode)
-	minConnection := level.GetMinConnection()
+	minConnection := level.MinConnection
 
-	// INCREASED CLOVER APPEARANCE: 40% chance to force clover connections for Booming Reels
-	forceClover := r.Float64() < 0.4
+	// INCREASED CLOVER APPEARANCE: chance to force clover connections for Booming Reels
+	forceClover := r.Float64() < level.ForcedClover.WinGrid
 	var targetSymbol Symbol
 
 	if forceClover {
@@ -170,8 +170,8 @@ func ForceWinGrid(level Level, r *rand.Rand, gameMode string) [][]string {
 }
 
 // DELUXE: ForceLossGrid - Creates grid with no connection-forming symbol connections
-func ForceLossGrid(level Level, r *rand.Rand, gameMode string) [][]string {
-	gridSize := level.GetGridSize()
+func ForceLossGrid(level *LevelModel, r random.Source, gameMode string) [][]string {
+	gridSize := level.GridSize
 	grid := make([][]string, gridSize)
 	connectionSymbols := []Symbol{SymbolPurpleOwl, SymbolGreenOwl, SymbolYellowOwl, SymbolBlueOwl, SymbolRedOwl, SymbolClover}
 
@@ -216,7 +216,7 @@ func ForceLossGrid(level Level, r *rand.Rand, gameMode string) [][]string {
 
 // ProcessStageClearedSymbolsSurgical processes stage-cleared symbols with surgical precision
 // This preserves the grid structure and only affects the stage-cleared symbol positions
-func ProcessStageClearedSymbolsSurgical(gameState *GameState, stageClearedSymbols []StageClearedSymbol, level Level, r *rand.Rand) (bool, Level, Level) {
+func ProcessStageClearedSymbolsSurgical(gameState *GameState, stageClearedSymbols []StageClearedSymbol, level *LevelModel, r random.Source) (bool, Level, Level) {
 	if len(stageClearedSymbols) == 0 {
 		return false, gameState.CurrentLevel, gameState.CurrentLevel
 	}
@@ -231,16 +231,17 @@ func ProcessStageClearedSymbolsSurgical(gameState *GameState, stageClearedSymbol
 
 	// Update stage progress
 	gameState.StageProgress += len(stageClearedSymbols)
-	log.Printf("Added %d stage-cleared symbols to progress, total: %d/15",
-		len(stageClearedSymbols), gameState.StageProgress)
+	stageProgressTarget := gameState.math().StageProgressTarget
+	log.Printf("Added %d stage-cleared symbols to progress, total: %d/%d",
+		len(stageClearedSymbols), gameState.StageProgress, stageProgressTarget)
 
 	// Check for level advancement
 	levelAdvanced := false
-	if gameState.StageProgress >= StageProgressTarget {
+	if gameState.StageProgress >= stageProgressTarget {
 		newLevel := AdvanceLevel(oldLevel)
 
 		// Handle overflow progress
-		excessProgress := gameState.StageProgress - StageProgressTarget
+		excessProgress := gameState.StageProgress - stageProgressTarget
 
 		UpdateGameStateForLevel(gameState, newLevel)
 
@@ -251,7 +252,7 @@ func ProcessStageClearedSymbolsSurgical(gameState *GameState, stageClearedSymbol
 		}
 
 		// Regenerate grid with new level's size and symbols
-		gameState.Grid = GenerateGrid(newLevel, r, gameState.GameMode)
+		gameState.Grid = GenerateGrid(gameState.level(), r, gameState.GameMode)
 
 		levelAdvanced = true
 		log.Printf("Level advanced from %d to %d, excess progress: %d", oldLevel, newLevel, excessProgress)
@@ -275,7 +276,7 @@ func RemoveStageClearedSymbolsSurgical(grid [][]string, stageClearedSymbols []St
 }
 
 // DELUXE: ApplyGravitySurgical - Modified to accept game mode
-func ApplyGravitySurgical(grid [][]string, stageClearedSymbols []StageClearedSymbol, level Level, r *rand.Rand, gameMode string) []Position {
+func ApplyGravitySurgical(grid [][]string, stageClearedSymbols []StageClearedSymbol, level *LevelModel, r random.Source, gameMode string) []Position {
 	gridSize := len(grid)
 	var newPositions []Position
 
@@ -287,8 +288,8 @@ func ApplyGravitySurgical(grid [][]string, stageClearedSymbols []StageClearedSym
 
 	log.Printf("Applying surgical gravity to columns: %v", getKeys(affectedColumns))
 
-	// Apply gravity only to affected columns
-	for x := range affectedColumns {
+	// Apply gravity only to affected columns, left to right
+	for _, x := range getKeys(affectedColumns) {
 		if x >= 0 && x < gridSize {
 			// Move existing symbols down
 			writePos := gridSize - 1
@@ -306,8 +307,8 @@ func ApplyGravitySurgical(grid [][]string, stageClearedSymbols []StageClearedSym
 			for y := 0; y <= writePos; y++ {
 				allowFreeGameSymbols := !hasFreeGameSymbol(grid)
 
-				// INCREASED CLOVER APPEARANCE: 30% chance to force clover during gravity for Booming Reels
-				forceClover := r.Float64() < 0.3
+				// INCREASED CLOVER APPEARANCE: chance to force clover during gravity for Booming Reels
+				forceClover := r.Float64() < level.ForcedClover.Gravity
 				var newSymbol Symbol
 
 				if forceClover && allowFreeGameSymbols {
@@ -329,13 +330,9 @@ func ApplyGravitySurgical(grid [][]string, stageClearedSymbols []StageClearedSym
 // ApplySurgicalLoss attempts to remove connections while preserving the grid structure
 // Only modifies newly generated positions
 // Returns true if surgical loss was successful, false if impossible
-func ApplySurgicalLoss(gameState *GameState, originalGrid [][]string, stageClearedSymbols []StageClearedSymbol, level Level, r *rand.Rand, newPositions []Position) bool {
-	// Build a set of allowed positions for modification
-	allowed := make(map[string]bool)
-	for _, pos := range newPositions {
-		key := fmt.Sprintf("%d,%d", pos.X, pos.Y)
-		allowed[key] = true
-	}
+func ApplySurgicalLoss(gameState *GameState, originalGrid [][]string, stageClearedSymbols []StageClearedSymbol, level *LevelModel, r random.Source, newPositions []Position) bool {
+	// Build the list of allowed positions for modification
+	allowed := uniquePositions(newPositions)
 
 	connections := FindAllConnections(gameState.Grid, level)
 	if len(connections) == 0 {
@@ -358,14 +355,14 @@ func ApplySurgicalLoss(gameState *GameState, originalGrid [][]string, stageClear
 		modificationsCount := min(3, len(allowed))
 		modified := 0
 
-		for posKey := range allowed {
+		// Each attempt tries a different random subset of the allowed positions
+		shufflePositions(allowed, r)
+		for _, pos := range allowed {
 			if modified >= modificationsCount {
 				break
 			}
 
-			// Parse position
-			var x, y int
-			fmt.Sscanf(posKey, "%d,%d", &x, &y)
+			x, y := pos.X, pos.Y
 
 			if x >= 0 && x < len(testGrid) && y >= 0 && y < len(testGrid[0]) {
 				originalSymbol := testGrid[y][x]
@@ -390,15 +387,65 @@ func ApplySurgicalLoss(gameState *GameState, originalGrid [][]string, stageClear
 	return false
 }
 
-// Helper function to get keys from map
+// Helper function to get the sorted keys of a map
 func getKeys(m map[int]bool) []int {
 	keys := make([]int, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
 	}
+	sort.Ints(keys)
 	return keys
 }
 
+// uniquePositions drops repeated positions, keeping the first occurrence of each
+func uniquePositions(positions []Position) []Position {
+	seen := make(map[Position]bool, len(positions))
+	unique := make([]Position, 0, len(positions))
+	for _, pos := range positions {
+		if !seen[pos] {
+			seen[pos] = true
+			unique = append(unique, pos)
+		}
+	}
+	return unique
+}
+
+// shufflePositions reorders positions in place (Fisher-Yates) using the round's source
+func shufflePositions(positions []Position, r random.Source) {
+	for i := len(positions) - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		positions[i], positions[j] = positions[j], positions[i]
+	}
+}
+
+// GravityMoves lists the drops gravity will make in the columns of the emptied positions,
+// without changing the grid. ApplyGravitySurgical and ApplyGravitySurgicalForCascade move
+// symbols exactly this way before filling the top of each column.
+func GravityMoves(grid [][]string, emptied []Position) []Move {
+	gridSize := len(grid)
+	columns := make(map[int]bool)
+	for _, pos := range emptied {
+		columns[pos.X] = true
+	}
+
+	var moves []Move
+	for _, x := range getKeys(columns) {
+		if x < 0 || x >= gridSize {
+			continue
+		}
+		writePos := gridSize - 1
+		for y := gridSize - 1; y >= 0; y-- {
+			if grid[y][x] != "" {
+				if y != writePos {
+					moves = append(moves, Move{Symbol: grid[y][x], From: Position{X: x, Y: y}, To: Position{X: x, Y: writePos}})
+				}
+				writePos--
+			}
+		}
+	}
+	return moves
+}
+
 // RemoveConnectionsSurgical removes connected symbols from the grid and returns affected positions
 // This tracks which positions were removed for surgical gravity application
 func RemoveConnectionsSurgical(grid [][]string, connections []Connection) []Position {
@@ -419,7 +466,7 @@ func RemoveConnectionsSurgical(grid [][]string, connections []Connection) []Posi
 }
 
 // DELUXE: ApplyGravitySurgicalForCascade - Modified to accept game mode and increase clover appearance
-func ApplyGravitySurgicalForCascade(grid [][]string, affectedPositions []Position, level Level, r *rand.Rand, gameMode string) []Position {
+func ApplyGravitySurgicalForCascade(grid [][]string, affectedPositions []Position, level *LevelModel, r random.Source, gameMode string) []Position {
 	gridSize := len(grid)
 	var newPositions []Position
 
@@ -431,8 +478,8 @@ func ApplyGravitySurgicalForCascade(grid [][]string, affectedPositions []Positio
 
 	log.Printf("Applying surgical cascade gravity to columns: %v", getKeys(affectedColumns))
 
-	// Apply gravity only to affected columns
-	for x := range affectedColumns {
+	// Apply gravity only to affected columns, left to right
+	for _, x := range getKeys(affectedColumns) {
 		if x >= 0 && x < gridSize {
 			// Move existing symbols down
 			writePos := gridSize - 1
@@ -451,8 +498,8 @@ func ApplyGravitySurgicalForCascade(grid [][]string, affectedPositions []Positio
 			for y := 0; y <= writePos; y++ {
 				allowFreeGameSymbols := !hasFreeGameSymbol(grid)
 
-				// INCREASED CLOVER APPEARANCE: 50% chance to force clover during cascades for Booming Reels
-				forceClover := r.Float64() < 0.5
+				// INCREASED CLOVER APPEARANCE: chance to force clover during cascades for Booming Reels
+				forceClover := r.Float64() < level.ForcedClover.Cascade
 				var newSymbol Symbol
 
 				if forceClover && allowFreeGameSymbols {
@@ -474,13 +521,9 @@ func ApplyGravitySurgicalForCascade(grid [][]string, affectedPositions []Positio
 // ApplySurgicalLossForCascade attempts to remove connections while preserving the grid structure for cascades
 // Only modifies newly generated positions
 // Returns true if surgical loss was successful, false if impossible
-func ApplySurgicalLossForCascade(gameState *GameState, originalGrid [][]string, newPositions []Position, level Level, r *rand.Rand) bool {
-	// Build a set of allowed positions for modification
-	allowed := make(map[string]bool)
-	for _, pos := range newPositions {
-		key := fmt.Sprintf("%d,%d", pos.X, pos.Y)
-		allowed[key] = true
-	}
+func ApplySurgicalLossForCascade(gameState *GameState, originalGrid [][]string, newPositions []Position, level *LevelModel, r random.Source) bool {
+	// Build the list of allowed positions for modification
+	allowed := uniquePositions(newPositions)
 
 	connections := FindAllConnections(gameState.Grid, level)
 	if len(connections) == 0 {
@@ -503,14 +546,14 @@ func ApplySurgicalLossForCascade(gameState *GameState, originalGrid [][]string,
 		modificationsCount := min(4, len(allowed))
 		modified := 0
 
-		for posKey := range allowed {
+		// Each attempt tries a different random subset of the allowed positions
+		shufflePositions(allowed, r)
+		for _, pos := range allowed {
 			if modified >= modificationsCount {
 				break
 			}
 
-			// Parse position
-			var x, y int
-			fmt.Sscanf(posKey, "%d,%d", &x, &y)
+			x, y := pos.X, pos.Y
 
 			if x >= 0 && x < len(testGrid) && y >= 0 && y < len(testGrid[0]) {
 				originalSymbol := testGrid[y][x]
@@ -544,10 +587,10 @@ func min(a, b int) int {
 }
 
 // FindStageClearedSymbols finds all stage-cleared symbols for the current level
-func FindStageClearedSymbols(grid [][]string, level Level) []StageClearedSymbol {
+func FindStageClearedSymbols(grid [][]string, level *LevelModel) []StageClearedSymbol {
 	var stageClearedSymbols []StageClearedSymbol
 	gridSize := len(grid)
-	expectedSymbol := level.GetStageClearedSymbol()
+	expectedSymbol := level.Level.GetStageClearedSymbol()
 
 	for y := 0; y < gridSize; y++ {
 		for x := 0; x < gridSize; x++ {
@@ -561,13 +604,13 @@ func FindStageClearedSymbols(grid [][]string, level Level) []StageClearedSymbol
 	}
 
 	log.Printf("Found %d stage-cleared symbols (%s) for level %d",
-		len(stageClearedSymbols), expectedSymbol, level)
+		len(stageClearedSymbols), expectedSymbol, level.Level)
 
 	return stageClearedSymbols
 }
 
 // DELUXE: FindAllConnections finds all connection-forming symbol connections (birds + clovers)
-func FindAllConnections(grid [][]string, level Level) []Connection {
+func FindAllConnections(grid [][]string, level *LevelModel) []Connection {
 	var connections []Connection
 	gridSize := len(grid)
 	visited := make([][]bool, gridSize)
@@ -575,7 +618,7 @@ func FindAllConnections(grid [][]string, level Level) []Connection {
 		visited[i] = make([]bool, gridSize)
 	}
 
-	minConnection := level.GetMinConnection()
+	minConnection := level.MinConnection
 
 	for y := 0; y < gridSize; y++ {
 		for x := 0; x < gridSize; x++ {
@@ -651,12 +694,12 @@ func findConnectedPositions(grid [][]string, startX, startY int, symbol Symbol,
 }
 
 // calculatePayout calculates the payout for a connection
-func calculatePayout(symbol Symbol, count int, level Level, betMultiplier int) float64 {
-	paytable := GetPaytable(level)
+func calculatePayout(symbol Symbol, count int, level *LevelModel, betMultiplier float64) float64 {
+	paytable := level.Paytable
 
 	if payoutMap, exists := paytable[symbol]; exists {
 		if payout, found := payoutMap[count]; found {
-			result := payout * 0.01 * float64(betMultiplier) // Denomination is 0.01
+			result := payout * 0.01 * betMultiplier // Denomination is 0.01
 			return round(result)
 		}
 	}
@@ -696,7 +739,7 @@ func AdvanceLevel(currentLevel Level) Level {
 func InitializeGameState() GameState {
 	return GameState{
 		CurrentLevel:  Level1,
-		GridSize:      Level1GridSize,
+		GridSize:      defaultMathModel.Level(Level1).GridSize,
 		Grid:          [][]string{},
 		StageProgress: 0,
 		GameMode:      "base",
@@ -718,21 +761,22 @@ func InitializeGameState() GameState {
 		LastConnections:     []Connection{},
 		CascadeCount:        0,
 		StageClearedSymbols: []StageClearedSymbol{},
+		Phase:               PhaseIdle,
 	}
 }
 
 // UpdateGameStateForLevel updates the game state when advancing to a new level
 func UpdateGameStateForLevel(gameState *GameState, newLevel Level) {
 	gameState.CurrentLevel = newLevel
-	gameState.GridSize = newLevel.GetGridSize()
+	gameState.GridSize = gameState.level().GridSize
 	gameState.StageProgress = 0 // Reset progress for new level
 
 	log.Printf("Advanced to Level %d with %dx%d grid", newLevel, gameState.GridSize, gameState.GridSize)
 }
 
 // ValidateGridDimensions ensures grid matches expected size for level
-func ValidateGridDimensions(grid [][]string, level Level) bool {
-	expectedSize := level.GetGridSize()
+func ValidateGridDimensions(grid [][]string, level *LevelModel) bool {
+	expectedSize := level.GridSize
 	if len(grid) != expectedSize {
 		return false
 	}
@@ -745,9 +789,9 @@ func ValidateGridDimensions(grid [][]string, level Level) bool {
 }
 
 // DELUXE: CleanupInvalidSymbols - Modified to support game mode awareness
-func CleanupInvalidSymbols(grid [][]string, level Level, r *rand.Rand, gameMode string) {
+func CleanupInvalidSymbols(grid [][]string, level *LevelModel, r random.Source, gameMode string) {
 	gridSize := len(grid)
-	levelStageClearedSymbol := level.GetStageClearedSymbol()
+	levelStageClearedSymbol := level.Level.GetStageClearedSymbol()
 
 	for y := 0; y < gridSize; y++ {
 		for x := 0; x < gridSize; x++ {
@@ -765,13 +809,13 @@ func CleanupInvalidSymbols(grid [][]string, level Level, r *rand.Rand, gameMode
 }
 
 // HasPotentialConnections checks if grid has any potential connection-forming symbol connections
-func HasPotentialConnections(grid [][]string, level Level) bool {
+func HasPotentialConnections(grid [][]string, level *LevelModel) bool {
 	connections := FindAllConnections(grid, level)
 	return len(connections) > 0
 }
 
 // CountStageClearedSymbolsInGrid counts how many stage-cleared symbols are currently in the grid
-func CountStageClearedSymbolsInGrid(grid [][]string, level Level) int {
+func CountStageClearedSymbolsInGrid(grid [][]string, level *LevelModel) int {
 	stageClearedSymbols := FindStageClearedSymbols(grid, level)
 	return len(stageClearedSymbols)
 }
@@ -1,18 +1,22 @@
 package birdspartydeluxe
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
-	"time"
+	"strconv"
+	"strings"
 
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/wallet"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // SpinHandler handles the /spin/birdspartydeluxe endpoint
 // DELUXE: Modified to support connection-based clover mechanics and separate handling of special symbols
 func (rg *RouteGroup) SpinHandler(c *fiber.Ctx) error {
-	rngClient, settingsClient := rg.getClientsForRequest(c)
+	clients := rg.getClientsForRequest(c)
 
 	var req SpinRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -24,179 +28,159 @@ func (rg *RouteGroup) SpinHandler(c *fiber.Ctx) error {
 	}
 
 	// Validate request
-	if err := validateRequest(req.ClientID, req.GameID, req.PlayerID, req.BetID, req.GameState.Bet.Amount); err != nil {
+	if err := validateRequest(req.ClientID, req.GameID, req.PlayerID, req.BetID); err != nil {
 		log.Printf("Request validation failed: %v", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": err.Error(),
 		})
 	}
-
-	// Initialize game state if needed
-	if req.GameState.CurrentLevel == 0 {
-		req.GameState = InitializeGameState()
-	}
-	if req.GameState.GameMode == "" {
-		req.GameState.GameMode = "base"
+	if req.Step > 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A spin is always step 1 of its round",
+		})
 	}
 
-	// Ensure grid size matches current level
-	expectedGridSize := req.GameState.CurrentLevel.GetGridSize()
-	if req.GameState.GridSize != expectedGridSize {
-		req.GameState.GridSize = expectedGridSize
-		log.Printf("Corrected grid size to %d for level %d", expectedGridSize, req.GameState.CurrentLevel)
+	// The operator's settings decide the bet ladder and the maximum win
+	gameSettings, err := clients.Settings.GetGameSettings(req.ClientID, req.GameID, req.PlayerID)
+	if err != nil {
+		log.Printf("Failed to get game settings: %v", err)
+		return outcomeError(c, settingsError(err))
+	}
+	if err := validateBetAmount(req.BetAmount, gameSettings); err != nil {
+		log.Printf("Request validation failed: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
 	}
 
-	// Create rand instance
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// Set bet multiplier
-	req.GameState.Bet.Multiplier = BetAmountToMultiplier[req.GameState.Bet.Amount]
-
-	// DELUXE: Reset booming reels multiplier for new spin (cascade sequence resets)
-	ResetBoomingReels(&req.GameState)
-
-	// DELUXE: Generate grid with potential connection-forming symbol connections (birds + clovers)
-	req.GameState.Grid = GenerateGridWithWin(req.GameState.CurrentLevel, r, req.GameState.GameMode)
-
-	// Find stage-cleared symbols (do NOT remove them yet)
-	stageClearedSymbols := FindStageClearedSymbols(req.GameState.Grid, req.GameState.CurrentLevel)
-	req.GameState.StageClearedSymbols = stageClearedSymbols
-
-	// DELUXE: Find all connections and separate clover vs bird connections
-	allConnections := FindAllConnections(req.GameState.Grid, req.GameState.CurrentLevel)
-	cloverConnections, birdConnections := SeparateConnections(allConnections)
-
-	// DELUXE: Process clover connections first - they upgrade multiplier but pay base value only
-	totalWinnings := 0.0
-	for i, cloverConnection := range cloverConnections {
-		// Upgrade multiplier first
-		UpgradeBoomingReels(&req.GameState)
-		log.Printf("Clover connection found (%d symbols), multiplier upgraded to %.1fx",
-			cloverConnection.Count, req.GameState.FreeSpins.CurrentMultiplier)
+	unlock := rg.lockPlayer(req.ClientID, req.PlayerID)
+	defer unlock()
 
-		// Calculate clover payout - BASE VALUE ONLY, NO MULTIPLIER
-		payout := calculatePayout(cloverConnection.Symbol, cloverConnection.Count, req.GameState.CurrentLevel, req.GameState.Bet.Multiplier)
-		// Clovers pay base value only - no booming reels multiplier applied
-		cloverConnections[i].Payout = payout
-		totalWinnings += payout
+	// Load the game state for this player
+	gameState, err := rg.loadGameState(req.ref())
+	if err != nil {
+		log.Printf("Failed to load game state: %v", err)
+		return loadStateError(c, err)
 	}
 
-	// Calculate total winnings from bird connections using current multiplier
-	for i, connection := range birdConnections {
-		payout := calculatePayout(connection.Symbol, connection.Count, req.GameState.CurrentLevel, req.GameState.Bet.Multiplier)
-		// Apply booming reels multiplier to bird connections
-		payout *= req.GameState.FreeSpins.CurrentMultiplier
-		birdConnections[i].Payout = payout
-		totalWinnings += payout
+	// A new spin can only start once the previous round has finished
+	if err := CheckTransition(gameState.Phase, ActionSpin); err != nil {
+		log.Printf("Rejected spin for bet %s: %v", req.BetID, err)
+		return transitionError(c, err)
+	}
+	// A bet is played once; a spin that failed was not saved, so it can be retried
+	if gameState.BetID == req.BetID {
+		log.Printf("Rejected spin for bet %s: already played", req.BetID)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This bet_id was already played",
+		})
 	}
-	totalWinnings = round(totalWinnings)
 
-	// Get RTP and call RNG for ALL paying connections (birds + clovers)
-	allPayingConnections := append(cloverConnections, birdConnections...)
-	if len(allPayingConnections) > 0 {
-		rtp, err := settingsClient.GetRTP(req.ClientID, req.GameID, req.PlayerID)
-		if err != nil {
-			log.Printf("Failed to get RTP: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Failed to retrieve game settings",
-			})
-		}
+	before := cloneGameState(gameState)
 
-		// Call RNG
-		payoutMultiplier := totalWinnings / req.GameState.Bet.Amount
-		ip := c.IP()
-		userAgent := c.Get("User-Agent")
-
-		log.Printf("✅IP: %v", ip)
-		log.Printf("✅User-Agent: %v", userAgent)
-		rngResp, err := rngClient.GetOutcome(req.ClientID, req.GameID, req.PlayerID, req.BetID, rtp, payoutMultiplier, req.GameState.Bet.Amount, ip, userAgent, false)
-		if err != nil {
-			log.Printf("Failed to call RNG API: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	// Start a new round for this bet
+	beginRound(&gameState, req.BetID, req.BetAmount, gameSettings, rg.SpinFlow, rg.mathModel(req.ClientID), clients.WeightProfile)
+	gameState.RoundID = uuid.New().String()
+
+	// Charge the bet before the grid is generated; free spins are not charged
+	if err := debitBet(c, clients.Wallet, req.ref(), &gameState); err != nil {
+		log.Printf("Failed to debit bet %s: %v", req.BetID, err)
+		if errors.Is(err, wallet.ErrInsufficientFunds) {
+			return c.Status(fiber.StatusPaymentRequired).JSON(fiber.Map{
 				"status":  "error",
-				"message": "Failed to determine outcome",
+				"message": "Insufficient funds",
 			})
 		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to debit wallet",
+		})
+	}
+	// Any failure from here on refunds the bet
+	defer func() {
+		if c.Response().StatusCode() >= fiber.StatusInternalServerError {
+			rollbackRound(c, clients.Wallet, req.ref(), gameState)
+		}
+	}()
 
-		// Adjust outcome based on RNG
-		if rngResp.PrefOutcome == "loss" {
-			log.Printf("RNG determined a loss outcome")
-			req.GameState.Grid = GenerateLossGrid(req.GameState.CurrentLevel, r, req.GameState.GameMode)
+	// Create the step's random source from the round seed
+	r, err := rg.stepSource(req.ref(), &gameState)
+	if err != nil {
+		log.Printf("Failed to create random source for bet %s: %v", req.BetID, err)
+		return randomSourceError(c)
+	}
 
-			// Re-find stage-cleared symbols in loss grid
-			stageClearedSymbols = FindStageClearedSymbols(req.GameState.Grid, req.GameState.CurrentLevel)
-			req.GameState.StageClearedSymbols = stageClearedSymbols
+	// Play the spin
+	result, failure, err := rg.playStep(c, clients, req.ref(), ActionSpin, &gameState, r)
+	if err != nil {
+		return outcomeError(c, err)
+	}
+	stageClearedSymbols := result.StageClearedSymbols
+	hasStageCleared := result.HasStageCleared
 
-			// Reset connections and winnings
-			allConnections = nil
-			cloverConnections = nil
-			birdConnections = nil
-			totalWinnings = 0
+	// Total cost is what was charged when the spin started
+	totalCost := gameState.RoundCost
 
-			// Reset booming reels since we regenerated the grid
-			ResetBoomingReels(&req.GameState)
-		}
-	}
+	gameState.RoundWin = gameState.TotalWin
+	gameState.Phase = NextPhase(&gameState, hasStageCleared)
 
-	// Reset cascade count for new spin
-	req.GameState.CascadeCount = 0
-	req.GameState.TotalWin = totalWinnings
-	req.GameState.LastConnections = allConnections // Store all connections for cascade processing
-	req.GameState.Cascading = len(allConnections) > 0
-
-	// DELUXE: Check for free game symbols (rainbow eggs) - separate from clovers
-	freeGameCount := CountFreeGameSymbols(req.GameState.Grid)
-	if req.GameState.GameMode == "base" && freeGameCount > 0 {
-		// Trigger free spins with rainbow egg
-		req.GameState.GameMode = "freeSpins"
-		req.GameState.FreeSpins.Remaining = FreeSpinsAwarded
-		req.GameState.FreeSpins.TotalAwarded = FreeSpinsAwarded
-		log.Printf("Free Spins triggered by rainbow egg, current booming reels multiplier: %.1fx", req.GameState.FreeSpins.CurrentMultiplier)
-	}
-
-	// Update free spins count (DELUXE: no re-triggering during free spins)
-	if req.GameState.GameMode == "freeSpins" {
-		req.GameState.FreeSpins.Remaining--
-		if req.GameState.FreeSpins.Remaining <= 0 {
-			req.GameState.GameMode = "base"
-			// Reset free spins data but keep booming reels for this cascade sequence
-			req.GameState.FreeSpins.Remaining = 0
-			req.GameState.FreeSpins.TotalAwarded = 0
-			log.Printf("Free Spins ended, booming reels multiplier continues: %.1fx", req.GameState.FreeSpins.CurrentMultiplier)
-		}
+	// Pay the round win once the cascade chain has ended
+	if err := settleRound(c, clients.Wallet, req.ref(), &gameState); err != nil {
+		log.Printf("Failed to credit win for bet %s: %v", req.BetID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to credit win",
+		})
 	}
 
-	// Calculate total cost
-	var totalCost float64
-	if req.GameState.GameMode == "freeSpins" {
-		totalCost = 0
-	} else {
-		totalCost = req.GameState.Bet.Amount
+	// Keep an immutable record of the step for dispute resolution
+	if err := rg.recordStep(req.ref(), stepTrace{
+		Action:      ActionSpin,
+		Before:      before,
+		Failure:     failure,
+		Connections: result.PayingConnections(),
+		Outcome:     result.Outcome,
+	}, &gameState); err != nil {
+		log.Printf("Failed to write audit record for bet %s: %v", req.BetID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to write audit record",
+		})
 	}
 
-	// Determine if we have stage-cleared symbols
-	hasStageCleared := len(stageClearedSymbols) > 0
-
 	log.Printf("Spin completed: level=%d, gridSize=%dx%d, stageClearedSymbols=%d, hasStageCleared=%v, cascading=%v, boomingReels=%.1fx, cloverConnections=%d, birdConnections=%d",
-		req.GameState.CurrentLevel, req.GameState.GridSize, req.GameState.GridSize,
-		len(stageClearedSymbols), hasStageCleared, req.GameState.Cascading, req.GameState.FreeSpins.CurrentMultiplier, len(cloverConnections), len(birdConnections))
+		gameState.CurrentLevel, gameState.GridSize, gameState.GridSize,
+		len(stageClearedSymbols), hasStageCleared, gameState.Cascading, gameState.FreeSpins.CurrentMultiplier, len(result.CloverConnections), len(result.BirdConnections))
+
+	stateToken, err := rg.saveGameState(req.ref(), gameState)
+	if err != nil {
+		log.Printf("Failed to save game state: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to save game state",
+		})
+	}
 
 	return c.JSON(SpinResponse{
 		Status:              "success",
-		Message:             "",
-		GameState:           req.GameState,
+		Message:             failureMessage(failure),
+		GameState:           gameState,
 		StageClearedSymbols: stageClearedSymbols,
 		HasStageCleared:     hasStageCleared,
 		TotalCost:           totalCost,
+		StateToken:          stateToken,
+		RoundSeed:           revealedSeed(gameState),
 	})
 }
 
 // ProcessStageClearedHandler handles the /process-stage-cleared/birdspartydeluxe endpoint
 // DELUXE: Modified to support booming reels continuity and connection-based clover mechanics
 func (rg *RouteGroup) ProcessStageClearedHandler(c *fiber.Ctx) error {
-	rngClient, settingsClient := rg.getClientsForRequest(c)
+	clients := rg.getClientsForRequest(c)
 
 	var req ProcessStageClearedRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -208,7 +192,7 @@ func (rg *RouteGroup) ProcessStageClearedHandler(c *fiber.Ctx) error {
 	}
 
 	// Validate request
-	if err := validateRequest(req.ClientID, req.GameID, req.PlayerID, req.BetID, req.GameState.Bet.Amount); err != nil {
+	if err := validateRequest(req.ClientID, req.GameID, req.PlayerID, req.BetID); err != nil {
 		log.Printf("Request validation failed: %v", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
@@ -216,252 +200,118 @@ func (rg *RouteGroup) ProcessStageClearedHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	unlock := rg.lockPlayer(req.ClientID, req.PlayerID)
+	defer unlock()
+
+	// Load the round this step belongs to
+	gameState, err := rg.loadActiveRound(req.ref())
+	if err != nil {
+		log.Printf("Failed to load round for bet %s: %v", req.BetID, err)
+		return loadStateError(c, err)
+	}
+	if err := CheckTransition(gameState.Phase, ActionStageCleared); err != nil {
+		log.Printf("Rejected step for bet %s: %v", req.BetID, err)
+		return transitionError(c, err)
+	}
+	if req.Step != 0 && req.Step != gameState.Step+1 {
+		log.Printf("Step %d out of sequence for bet %s, expected %d", req.Step, req.BetID, gameState.Step+1)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Step out of sequence for this bet",
+		})
+	}
+	before := cloneGameState(gameState)
+	defer rg.compensateFailedStep(c, clients.Wallet, req.ref(), before)
+	gameState.Step++
+
 	// Validate grid dimensions
-	if !ValidateGridDimensions(req.GameState.Grid, req.GameState.CurrentLevel) {
-		log.Printf("Invalid grid dimensions for level %d", req.GameState.CurrentLevel)
+	if !ValidateGridDimensions(gameState.Grid, gameState.level()) {
+		log.Printf("Invalid grid dimensions for level %d", gameState.CurrentLevel)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Invalid grid dimensions",
 		})
 	}
 
-	// Create rand instance
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// Get stage-cleared symbols from the current grid
-	stageClearedSymbols := req.GameState.StageClearedSymbols
-	if len(stageClearedSymbols) == 0 {
-		// If none provided, find them from the grid
-		stageClearedSymbols = FindStageClearedSymbols(req.GameState.Grid, req.GameState.CurrentLevel)
-	}
-
-	stageClearedCount := len(stageClearedSymbols)
-
-	// PRESERVE ORIGINAL GRID before processing
-	originalGrid := make([][]string, len(req.GameState.Grid))
-	for i := range req.GameState.Grid {
-		originalGrid[i] = make([]string, len(req.GameState.Grid[i]))
-		copy(originalGrid[i], req.GameState.Grid[i])
-	}
-
-	// Prepare variables for level advancement
-	var (
-		levelAdvanced bool
-		oldLevel      = req.GameState.CurrentLevel
-		newLevel      = req.GameState.CurrentLevel
-	)
-
-	// Process stage-cleared symbols (remove, apply gravity, check level advancement)
-	var newPositions []Position
-	{
-		// Remove stage-cleared symbols from grid surgically
-		RemoveStageClearedSymbolsSurgical(req.GameState.Grid, stageClearedSymbols)
-		// Apply gravity surgically and get new positions
-		newPositions = ApplyGravitySurgical(req.GameState.Grid, stageClearedSymbols, req.GameState.CurrentLevel, r, req.GameState.GameMode)
-		// Update stage progress
-		req.GameState.StageProgress += len(stageClearedSymbols)
-		log.Printf("Added %d stage-cleared symbols to progress, total: %d/15", len(stageClearedSymbols), req.GameState.StageProgress)
-		// Check for level advancement
-		if req.GameState.StageProgress >= StageProgressTarget {
-			newLevel = AdvanceLevel(oldLevel)
-			excessProgress := req.GameState.StageProgress - StageProgressTarget
-			UpdateGameStateForLevel(&req.GameState, newLevel)
-
-			if oldLevel == Level3 {
-				req.GameState.StageProgress = 0 // Reset progress when looping from level 3 to 1
-			} else {
-				req.GameState.StageProgress = excessProgress // Carry over excess progress otherwise
-			}
-
-			// Generate new grid for the new level
-			req.GameState.Grid = GenerateGrid(newLevel, r, req.GameState.GameMode)
-			levelAdvanced = true
-			log.Printf("Level advanced from %d to %d, excess progress: %d", oldLevel, newLevel, excessProgress)
-
-			// --- NEW: Analyze the brand new grid for wins and special symbols ---
-			allConnections := FindAllConnections(req.GameState.Grid, req.GameState.CurrentLevel)
-			cloverConnections, birdConnections := SeparateConnections(allConnections)
-			stageClearedSymbolsAfterLevelUp := FindStageClearedSymbols(req.GameState.Grid, req.GameState.CurrentLevel)
-			req.GameState.StageClearedSymbols = stageClearedSymbolsAfterLevelUp
-
-			// DELUXE: Process clover connections first - upgrade multiplier but pay base value only
-			totalWinnings := 0.0
-			for i, cloverConnection := range cloverConnections {
-				// Upgrade multiplier first
-				UpgradeBoomingReels(&req.GameState)
-				log.Printf("Clover connection found on new level (%d symbols), multiplier upgraded to %.1fx",
-					cloverConnection.Count, req.GameState.FreeSpins.CurrentMultiplier)
-
-				// Calculate clover payout - BASE VALUE ONLY, NO MULTIPLIER
-				payout := calculatePayout(cloverConnection.Symbol, cloverConnection.Count, req.GameState.CurrentLevel, req.GameState.Bet.Multiplier)
-				// Clovers pay base value only - no booming reels multiplier applied
-				cloverConnections[i].Payout = payout
-				totalWinnings += payout
-			}
-
-			// Calculate winnings from bird connections using current multiplier
-			for i, connection := range birdConnections {
-				payout := calculatePayout(connection.Symbol, connection.Count, req.GameState.CurrentLevel, req.GameState.Bet.Multiplier)
-				payout *= req.GameState.FreeSpins.CurrentMultiplier
-				birdConnections[i].Payout = payout
-				totalWinnings += payout
-			}
-
-			// DELUXE: Check for and trigger free spins on the new grid (rainbow eggs)
-			freeGameCount := CountFreeGameSymbols(req.GameState.Grid)
-			if req.GameState.GameMode == "base" && freeGameCount > 0 {
-				req.GameState.GameMode = "freeSpins"
-				req.GameState.FreeSpins.Remaining = FreeSpinsAwarded
-				req.GameState.FreeSpins.TotalAwarded = FreeSpinsAwarded
-				log.Printf("Free Spins triggered on new level by rainbow egg, current booming reels multiplier: %.1fx", req.GameState.FreeSpins.CurrentMultiplier)
-			}
-
-			// Update game state for the response
-			req.GameState.TotalWin = round(totalWinnings)
-			req.GameState.LastConnections = allConnections
-			req.GameState.Cascading = len(allConnections) > 0
-			req.GameState.CascadeCount = 0 // Reset for new level
-
-			return c.JSON(ProcessStageClearedResponse{
-				Status:            "success",
-				Message:           "",
-				GameState:         req.GameState,
-				StageClearedCount: stageClearedCount,
-				LevelAdvanced:     levelAdvanced,
-				OldLevel:          oldLevel,
-				NewLevel:          newLevel,
-				Connections:       allConnections, // New connections from the new grid
-				TotalCost:         0,
-			})
-		}
+	// Create the step's random source from the round seed
+	r, err := rg.stepSource(req.ref(), &gameState)
+	if err != nil {
+		log.Printf("Failed to create random source for bet %s: %v", req.BetID, err)
+		return randomSourceError(c)
 	}
-	// Clear the stage-cleared symbols from game state since they've been processed
-	req.GameState.StageClearedSymbols = []StageClearedSymbol{}
-
-	// NOW check for connection-forming symbol connections in the new grid after gravity
-	allConnections := FindAllConnections(req.GameState.Grid, req.GameState.CurrentLevel)
-	cloverConnections, birdConnections := SeparateConnections(allConnections)
-
-	// DELUXE: Process clover connections first - upgrade multiplier but pay base value only
-	totalWinnings := 0.0
-	for i, cloverConnection := range cloverConnections {
-		// Upgrade multiplier first
-		UpgradeBoomingReels(&req.GameState)
-		log.Printf("Clover connection found after stage-cleared processing (%d symbols), multiplier upgraded to %.1fx",
-			cloverConnection.Count, req.GameState.FreeSpins.CurrentMultiplier)
-
-		// Calculate clover payout - BASE VALUE ONLY, NO MULTIPLIER
-		payout := calculatePayout(cloverConnection.Symbol, cloverConnection.Count, req.GameState.CurrentLevel, req.GameState.Bet.Multiplier)
-		// Clovers pay base value only - no booming reels multiplier applied
-		cloverConnections[i].Payout = payout
-		totalWinnings += payout
-	}
-
-	// Calculate total winnings from bird connections using current multiplier
-	for i, connection := range birdConnections {
-		payout := calculatePayout(connection.Symbol, connection.Count, req.GameState.CurrentLevel, req.GameState.Bet.Multiplier)
-		// Apply booming reels multiplier to bird connections
-		payout *= req.GameState.FreeSpins.CurrentMultiplier
-		birdConnections[i].Payout = payout
-		totalWinnings += payout
-	}
-	totalWinnings = round(totalWinnings)
-
-	// Handle RNG for ALL paying connections (birds + clovers) with surgical loss approach
-	rngBypassed := false
-	allPayingConnections := append(cloverConnections, birdConnections...)
-	if len(allPayingConnections) > 0 {
-		rtp, err := settingsClient.GetRTP(req.ClientID, req.GameID, req.PlayerID)
-		if err != nil {
-			log.Printf("Failed to get RTP: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Failed to retrieve game settings",
-			})
-		}
-
-		// Call RNG
-		payoutMultiplier := totalWinnings / req.GameState.Bet.Amount
-		ip := c.IP()
-		userAgent := c.Get("User-Agent")
-
-		log.Printf("✅IP: %v", ip)
-		log.Printf("✅User-Agent: %v", userAgent)
-		rngResp, err := rngClient.GetOutcome(req.ClientID, req.GameID, req.PlayerID, req.BetID, rtp, payoutMultiplier, req.GameState.Bet.Amount, ip, userAgent, false)
-		if err != nil {
-			log.Printf("Failed to call RNG API: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Failed to determine outcome",
-			})
-		}
 
-		// SURGICAL LOSS: Adjust outcome based on RNG while preserving grid structure
-		if rngResp.PrefOutcome == "loss" {
-			log.Printf("RNG determined a loss outcome for stage-cleared processing")
-			// Try surgical loss approach first (only new positions)
-			success := ApplySurgicalLoss(&req.GameState, originalGrid, stageClearedSymbols, req.GameState.CurrentLevel, r, newPositions)
-			if !success {
-				// If surgical loss is impossible, bypass RNG and allow the win
-				log.Printf("⚠️  RNG BYPASS: Surgical loss impossible after stage-cleared processing - preserving natural outcome")
-				log.Printf("⚠️  GRID PRESERVATION: Maintaining grid structure as surgical loss would break game mechanics")
-				log.Printf("⚠️  REASON: Stage-cleared symbol removal at positions %+v made loss impossible", stageClearedSymbols)
-				rngBypassed = true
-				// Keep the original connections and winnings
-				// Grid remains as it is after stage-cleared processing
-			} else {
-				// Surgical loss successful - remove ALL paying connections but keep multiplier upgrades
-				cloverConnections = nil
-				birdConnections = nil
-				allConnections = nil // IMPORTANT: Clear allConnections when surgical loss is successful
-				totalWinnings = 0
-				log.Printf("Surgical loss applied successfully after stage-cleared processing (all paying connections)")
-
-				// IMPORTANT: Re-find connections in the modified grid to ensure consistency
-				allConnections = FindAllConnections(req.GameState.Grid, req.GameState.CurrentLevel)
-			}
-		}
+	// Play the stage-cleared step
+	result, failure, err := rg.playStep(c, clients, req.ref(), ActionStageCleared, &gameState, r)
+	if err != nil {
+		return outcomeError(c, err)
 	}
 
-	// Update game state with connection results
-	req.GameState.TotalWin = totalWinnings
-	req.GameState.LastConnections = allConnections // Store all connections for cascade processing
-	req.GameState.Cascading = len(allConnections) > 0
+	// Add the step's win to the round and move the round forward
+	gameState.RoundWin = round(gameState.RoundWin + gameState.TotalWin)
+	gameState.Phase = NextPhase(&gameState, result.HasStageCleared)
+
+	// Pay the round win once the cascade chain has ended
+	if err := settleRound(c, clients.Wallet, req.ref(), &gameState); err != nil {
+		log.Printf("Failed to credit win for bet %s: %v", req.BetID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to credit win",
+		})
+	}
 
-	// Reset cascade count since this is after stage-cleared processing
-	// if there is a win then cascade count to be 1 else 0
-	if len(allConnections) > 0 {
-		req.GameState.CascadeCount = 1
-	} else {
-		req.GameState.CascadeCount = 0
+	// Keep an immutable record of the step for dispute resolution
+	if err := rg.recordStep(req.ref(), stepTrace{
+		Action:      ActionStageCleared,
+		Before:      before,
+		Failure:     failure,
+		Connections: result.PayingConnections(),
+		Outcome:     result.Outcome,
+		RNGBypassed: result.RNGBypassed,
+	}, &gameState); err != nil {
+		log.Printf("Failed to write audit record for bet %s: %v", req.BetID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to write audit record",
+		})
 	}
 
 	logMessage := fmt.Sprintf("ProcessStageCleared completed: stageClearedCount=%d, levelAdvanced=%v, oldLevel=%d, newLevel=%d, progress=%d, cascading=%v, boomingReels=%.1fx, cloverConnections=%d, birdConnections=%d",
-		stageClearedCount, levelAdvanced, oldLevel, newLevel, req.GameState.StageProgress, req.GameState.Cascading, req.GameState.FreeSpins.CurrentMultiplier, len(cloverConnections), len(birdConnections))
+		result.StageClearedCount, result.LevelAdvanced, result.OldLevel, result.NewLevel, gameState.StageProgress, gameState.Cascading, gameState.FreeSpins.CurrentMultiplier, len(result.CloverConnections), len(result.BirdConnections))
 
-	if rngBypassed {
+	if result.RNGBypassed {
 		logMessage += " [RNG BYPASSED - Surgical loss impossible]"
 	}
 
-	log.Printf(logMessage)
+	log.Printf("%s", logMessage)
+
+	stateToken, err := rg.saveGameState(req.ref(), gameState)
+	if err != nil {
+		log.Printf("Failed to save game state: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to save game state",
+		})
+	}
 
 	return c.JSON(ProcessStageClearedResponse{
 		Status:            "success",
-		Message:           "",
-		GameState:         req.GameState,
-		StageClearedCount: stageClearedCount,
-		LevelAdvanced:     levelAdvanced,
-		OldLevel:          oldLevel,
-		NewLevel:          newLevel,
-		Connections:       allConnections,
+		Message:           failureMessage(failure),
+		GameState:         gameState,
+		StageClearedCount: result.StageClearedCount,
+		LevelAdvanced:     result.LevelAdvanced,
+		OldLevel:          result.OldLevel,
+		NewLevel:          result.NewLevel,
+		Connections:       result.Connections,
 		TotalCost:         0,
+		StateToken:        stateToken,
+		RoundSeed:         revealedSeed(gameState),
 	})
 }
 
 // CascadeHandler handles the /cascade/birdspartydeluxe endpoint
 // DELUXE: Modified to support connection-based clover mechanics and booming reels progression
 func (rg *RouteGroup) CascadeHandler(c *fiber.Ctx) error {
-	rngClient, settingsClient := rg.getClientsForRequest(c)
+	clients := rg.getClientsForRequest(c)
 
 	var req CascadeRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -473,7 +323,7 @@ func (rg *RouteGroup) CascadeHandler(c *fiber.Ctx) error {
 	}
 
 	// Validate request
-	if err := validateRequest(req.ClientID, req.GameID, req.PlayerID, req.BetID, req.GameState.Bet.Amount); err != nil {
+	if err := validateRequest(req.ClientID, req.GameID, req.PlayerID, req.BetID); err != nil {
 		log.Printf("Request validation failed: %v", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
@@ -481,202 +331,164 @@ func (rg *RouteGroup) CascadeHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate grid dimensions
-	if !ValidateGridDimensions(req.GameState.Grid, req.GameState.CurrentLevel) {
-		log.Printf("Invalid grid dimensions for level %d", req.GameState.CurrentLevel)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid grid dimensions",
-		})
-	}
+	unlock := rg.lockPlayer(req.ClientID, req.PlayerID)
+	defer unlock()
 
-	// Increment cascade count
-	req.GameState.CascadeCount++
-
-	// Create rand instance
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// PRESERVE ORIGINAL GRID before processing for surgical loss capability
-	originalGrid := make([][]string, len(req.GameState.Grid))
-	for i := range req.GameState.Grid {
-		originalGrid[i] = make([]string, len(req.GameState.Grid[i]))
-		copy(originalGrid[i], req.GameState.Grid[i])
-	}
-
-	var allConnections []Connection
-	var cloverConnections []Connection
-	var birdConnections []Connection
-	var totalWinnings float64
-	var affectedPositions []Position
-	var newPositions []Position
-
-	// Process cascade surgically
-	if req.GameState.CascadeCount >= 1 && len(req.GameState.LastConnections) > 0 {
-		// SURGICAL: Remove previous connections and apply gravity surgically
-		affectedPositions = RemoveConnectionsSurgical(req.GameState.Grid, req.GameState.LastConnections)
-		newPositions = ApplyGravitySurgicalForCascade(req.GameState.Grid, affectedPositions, req.GameState.CurrentLevel, r, req.GameState.GameMode)
-	} else {
-		// First cascade call - find existing connections
-		allConnections = FindAllConnections(req.GameState.Grid, req.GameState.CurrentLevel)
-		if len(allConnections) > 0 {
-			// Extract positions that will be affected for surgical processing
-			for _, connection := range allConnections {
-				affectedPositions = append(affectedPositions, connection.Positions...)
-			}
-			newPositions = ApplyGravitySurgicalForCascade(req.GameState.Grid, affectedPositions, req.GameState.CurrentLevel, r, req.GameState.GameMode)
-		}
+	// Load the round this step belongs to
+	gameState, err := rg.loadActiveRound(req.ref())
+	if err != nil {
+		log.Printf("Failed to load round for bet %s: %v", req.BetID, err)
+		return loadStateError(c, err)
 	}
-
-	// Find connection-forming symbol connections after cascade processing
-	if req.GameState.CascadeCount >= 1 || len(allConnections) == 0 {
-		allConnections = FindAllConnections(req.GameState.Grid, req.GameState.CurrentLevel)
+	if err := CheckTransition(gameState.Phase, ActionCascade); err != nil {
+		log.Printf("Rejected step for bet %s: %v", req.BetID, err)
+		return transitionError(c, err)
 	}
-
-	// DELUXE: Separate clover and bird connections
-	cloverConnections, birdConnections = SeparateConnections(allConnections)
-
-	// DELUXE: Process clover connections first - upgrade multiplier but pay base value only
-	totalWinnings = 0.0
-	for i, cloverConnection := range cloverConnections {
-		// Upgrade multiplier first
-		UpgradeBoomingReels(&req.GameState)
-		log.Printf("Clover connection found in cascade (%d symbols), multiplier upgraded to %.1fx",
-			cloverConnection.Count, req.GameState.FreeSpins.CurrentMultiplier)
-
-		// Calculate clover payout - BASE VALUE ONLY, NO MULTIPLIER
-		payout := calculatePayout(cloverConnection.Symbol, cloverConnection.Count, req.GameState.CurrentLevel, req.GameState.Bet.Multiplier)
-		// Clovers pay base value only - no booming reels multiplier applied
-		cloverConnections[i].Payout = payout
-		totalWinnings += payout
+	if req.Step != 0 && req.Step != gameState.Step+1 {
+		log.Printf("Step %d out of sequence for bet %s, expected %d", req.Step, req.BetID, gameState.Step+1)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Step out of sequence for this bet",
+		})
 	}
+	before := cloneGameState(gameState)
+	defer rg.compensateFailedStep(c, clients.Wallet, req.ref(), before)
+	gameState.Step++
 
-	// Calculate total winnings from bird connections using current multiplier
-	for i, connection := range birdConnections {
-		payout := calculatePayout(connection.Symbol, connection.Count, req.GameState.CurrentLevel, req.GameState.Bet.Multiplier)
-		// Apply booming reels multiplier to bird connections
-		payout *= req.GameState.FreeSpins.CurrentMultiplier
-		birdConnections[i].Payout = payout
-		totalWinnings += payout
+	// Validate grid dimensions
+	if !ValidateGridDimensions(gameState.Grid, gameState.level()) {
+		log.Printf("Invalid grid dimensions for level %d", gameState.CurrentLevel)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid grid dimensions",
+		})
 	}
-	totalWinnings = round(totalWinnings)
-
-	// Handle RNG for ALL paying connections (birds + clovers) with surgical loss approach
-	rngBypassed := false
-	allPayingConnections := append(cloverConnections, birdConnections...)
-	if len(allPayingConnections) > 0 {
-		rtp, err := settingsClient.GetRTP(req.ClientID, req.GameID, req.PlayerID)
-		if err != nil {
-			log.Printf("Failed to get RTP: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Failed to retrieve game settings",
-			})
-		}
-
-		// Call RNG
-		payoutMultiplier := totalWinnings / req.GameState.Bet.Amount
-		ip := c.IP()
-		userAgent := c.Get("User-Agent")
-
-		log.Printf("✅IP: %v", ip)
-		log.Printf("✅User-Agent: %v", userAgent)
-		rngResp, err := rngClient.GetOutcome(req.ClientID, req.GameID, req.PlayerID, req.BetID, rtp, payoutMultiplier, req.GameState.Bet.Amount, ip, userAgent, false)
-		if err != nil {
-			log.Printf("Failed to call RNG API: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Failed to determine outcome",
-			})
-		}
 
-		// SURGICAL LOSS: Adjust outcome based on RNG while preserving grid structure
-		if rngResp.PrefOutcome == "loss" {
-			log.Printf("RNG determined a loss outcome for cascade")
-
-			// Try surgical loss approach first (only new positions)
-			success := ApplySurgicalLossForCascade(&req.GameState, originalGrid, newPositions, req.GameState.CurrentLevel, r)
-
-			if !success {
-				// If surgical loss is impossible, bypass RNG and allow the win
-				log.Printf("⚠️  RNG BYPASS: Surgical loss impossible after cascade processing - preserving natural outcome")
-				log.Printf("⚠️  GRID PRESERVATION: Maintaining grid structure as surgical loss would break game mechanics")
-				log.Printf("⚠️  REASON: Cascade processing at %d positions made loss impossible", len(affectedPositions))
-				rngBypassed = true
-
-				// Keep the original connections and winnings
-				// Grid remains as it is after cascade processing
-			} else {
-				// Surgical loss successful - remove ALL paying connections but keep multiplier upgrades
-				cloverConnections = nil
-				birdConnections = nil
-				allConnections = nil // IMPORTANT: Clear allConnections when surgical loss is successful
-				totalWinnings = 0
-				log.Printf("Surgical loss applied successfully after cascade processing (all paying connections)")
-
-				// IMPORTANT: Re-find connections in the modified grid to ensure consistency
-				allConnections = FindAllConnections(req.GameState.Grid, req.GameState.CurrentLevel)
-			}
-		}
+	// Create the step's random source from the round seed
+	r, err := rg.stepSource(req.ref(), &gameState)
+	if err != nil {
+		log.Printf("Failed to create random source for bet %s: %v", req.BetID, err)
+		return randomSourceError(c)
 	}
 
-	// IMPORTANT: Recombine processed clover and bird connections for lastConnections and response
-	// (Only if not already done by surgical loss)
-	if len(allConnections) == 0 && len(cloverConnections) > 0 || len(birdConnections) > 0 {
-		allConnections = append(cloverConnections, birdConnections...)
+	// Play the cascade step
+	result, failure, err := rg.playStep(c, clients, req.ref(), ActionCascade, &gameState, r)
+	if err != nil {
+		return outcomeError(c, err)
 	}
+	stageClearedSymbols := result.StageClearedSymbols
+	hasStageCleared := result.HasStageCleared
 
-	// IMPORTANT: After all processing, check for stage-cleared symbols that may have appeared
-	stageClearedSymbols := FindStageClearedSymbols(req.GameState.Grid, req.GameState.CurrentLevel)
-	hasStageCleared := len(stageClearedSymbols) > 0
+	// Add the step's win to the round and move the round forward
+	gameState.RoundWin = round(gameState.RoundWin + gameState.TotalWin)
+	gameState.Phase = NextPhase(&gameState, hasStageCleared)
 
-	// Store stage-cleared symbols in game state for potential next call to process-stage-cleared
-	req.GameState.StageClearedSymbols = stageClearedSymbols
-
-	// DELUXE: Check for free game symbols (rainbow eggs) only if no connections and no RNG bypass
-	if len(allConnections) == 0 && !rngBypassed {
-		freeGameCount := CountFreeGameSymbols(req.GameState.Grid)
-		if req.GameState.GameMode == "base" && freeGameCount > 0 {
-			req.GameState.GameMode = "freeSpins"
-			req.GameState.FreeSpins.Remaining = FreeSpinsAwarded
-			req.GameState.FreeSpins.TotalAwarded = FreeSpinsAwarded
-			log.Printf("Free Spins triggered during cascade by rainbow egg, current booming reels multiplier: %.1fx", req.GameState.FreeSpins.CurrentMultiplier)
-		}
+	// Pay the round win once the cascade chain has ended
+	if err := settleRound(c, clients.Wallet, req.ref(), &gameState); err != nil {
+		log.Printf("Failed to credit win for bet %s: %v", req.BetID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to credit win",
+		})
 	}
 
-	// DELUXE: If no more connections, reset booming reels (cascade sequence ends)
-	if len(allConnections) == 0 {
-		log.Printf("Cascade sequence ended, resetting booming reels from %.1fx to 1.0x", req.GameState.FreeSpins.CurrentMultiplier)
-		ResetBoomingReels(&req.GameState)
+	// Keep an immutable record of the step for dispute resolution
+	if err := rg.recordStep(req.ref(), stepTrace{
+		Action:      ActionCascade,
+		Before:      before,
+		Failure:     failure,
+		Connections: result.PayingConnections(),
+		Outcome:     result.Outcome,
+		RNGBypassed: result.RNGBypassed,
+	}, &gameState); err != nil {
+		log.Printf("Failed to write audit record for bet %s: %v", req.BetID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to write audit record",
+		})
 	}
 
-	// Update game state
-	req.GameState.TotalWin = totalWinnings
-	req.GameState.LastConnections = allConnections
-	req.GameState.Cascading = len(allConnections) > 0
-
 	logMessage := fmt.Sprintf("Cascade completed: level=%d, gridSize=%dx%d, totalWin=%.2f, cascading=%v, cascadeCount=%d, stageClearedDetected=%v, boomingReels=%.1fx, cloverConnections=%d, birdConnections=%d",
-		req.GameState.CurrentLevel, req.GameState.GridSize, req.GameState.GridSize,
-		totalWinnings, req.GameState.Cascading, req.GameState.CascadeCount, hasStageCleared, req.GameState.FreeSpins.CurrentMultiplier, len(cloverConnections), len(birdConnections))
+		gameState.CurrentLevel, gameState.GridSize, gameState.GridSize,
+		gameState.TotalWin, gameState.Cascading, gameState.CascadeCount, hasStageCleared, gameState.FreeSpins.CurrentMultiplier, len(result.CloverConnections), len(result.BirdConnections))
 
-	if rngBypassed {
+	if result.RNGBypassed {
 		logMessage += " [RNG BYPASSED - Surgical loss impossible]"
 	}
 
 	log.Printf("%s", logMessage)
 
+	stateToken, err := rg.saveGameState(req.ref(), gameState)
+	if err != nil {
+		log.Printf("Failed to save game state: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to save game state",
+		})
+	}
+
 	return c.JSON(CascadeResponse{
 		Status:              "success",
-		Message:             "",
-		GameState:           req.GameState,
-		Connections:         allConnections,
+		Message:             failureMessage(failure),
+		GameState:           gameState,
+		Connections:         result.Connections,
 		StageClearedSymbols: stageClearedSymbols, // Include detected stage-cleared symbols
 		HasStageCleared:     hasStageCleared,     // Flag to indicate stage-cleared symbols found
 		TotalCost:           0,
+		StateToken:          stateToken,
+		RoundSeed:           revealedSeed(gameState),
+	})
+}
+
+// loadStateError maps a failure to load the round state to an error response
+func loadStateError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrNoActiveRound):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No active round for this bet",
+		})
+	case errors.Is(err, ErrStateUnknown):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "State token unknown to this server; every instance must share the session store",
+		})
+	case errors.Is(err, ErrStateRejected):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Game state signature verification failed",
+		})
+	case errors.Is(err, ErrUnknownMathModel):
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Math model of this round is not loaded",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to load game state",
+		})
+	}
+}
+
+// transitionError maps an illegal round transition to a 409 response that tells the client what the round expects
+func transitionError(c *fiber.Ctx, err error) error {
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"status":  "error",
+		"message": transitionErr.Error(),
+		"phase":   transitionErr.Phase,
 	})
 }
 
 // validateRequest validates the request fields
-func validateRequest(clientID, gameID, playerID, betID string, betAmount float64) error {
+func validateRequest(clientID, gameID, playerID, betID string) error {
 	if clientID == "" {
 		return fmt.Errorf("client_id is required")
 	}
@@ -689,19 +501,17 @@ func validateRequest(clientID, gameID, playerID, betID string, betAmount float64
 	if betID == "" {
 		return fmt.Errorf("bet_id is required")
 	}
-	if !isValidBetAmount(betAmount) {
-		return fmt.Errorf("invalid bet amount, allowed values are 0.1, 0.2, 0.3, 0.5, 1.0, 2.0, 2.5")
-	}
 	return nil
 }
 
-// isValidBetAmount checks if the bet amount is valid
-func isValidBetAmount(amount float64) bool {
-	validAmounts := []float64{0.1, 0.2, 0.3, 0.5, 1.0, 2.0, 2.5}
-	for _, valid := range validAmounts {
-		if amount == valid {
-			return true
+// validateBetAmount checks the bet against the operator's bet ladder
+func validateBetAmount(amount float64, gameSettings settings.GameSettings) error {
+	if !gameSettings.AllowsBet(amount) {
+		allowed := make([]string, len(gameSettings.Bets))
+		for i, bet := range gameSettings.Bets {
+			allowed[i] = strconv.FormatFloat(bet, 'f', -1, 64)
 		}
+		return fmt.Errorf("invalid bet amount, allowed values are %s", strings.Join(allowed, ", "))
 	}
-	return false
+	return nil
 }
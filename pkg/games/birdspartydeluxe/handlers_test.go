@@ -0,0 +1,52 @@
+package birdspartydeluxe
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+)
+
+// countingStore counts the loads of the session store it wraps
+type countingStore struct {
+	session.Store
+	loads atomic.Int32
+}
+
+func (s *countingStore) Load(key string) ([]byte, error) {
+	s.loads.Add(1)
+	return s.Store.Load(key)
+}
+
+// Every step rejects a request with a missing ID before the player's state is locked or loaded
+func TestStepHandlersValidateBeforeLoading(t *testing.T) {
+	store := &countingStore{Store: session.NewMemoryStore()}
+	s := newTestServer(t, func(rg *RouteGroup) { rg.Sessions = store })
+
+	tests := []struct {
+		name    string
+		missing string
+		want    string
+	}{
+		{"no client_id", "client_id", "client_id is required"},
+		{"no game_id", "game_id", "game_id is required"},
+		{"no player_id", "player_id", "player_id is required"},
+		{"no bet_id", "bet_id", "bet_id is required"},
+	}
+	for _, path := range []string{"/spin/birdspartydeluxe", "/process-stage-cleared/birdspartydeluxe", "/cascade/birdspartydeluxe"} {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				body := stepBody(testPlayer, betID(1), map[string]any{"bet_amount": 1.0})
+				body[tt.missing] = ""
+				status, reply := s.post(path, body)
+				if status != http.StatusBadRequest || reply["message"] != tt.want {
+					t.Errorf("%s = %d %v, want %d %q", path, status, reply["message"], http.StatusBadRequest, tt.want)
+				}
+				if loads := store.loads.Load(); loads != 0 {
+					t.Errorf("the session store was read %d times", loads)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package birdspartydeluxe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMain(m *testing.M) {
+	// The engine logs every step
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testServer runs the handlers against a settings stub and a seeded local RNG
+type testServer struct {
+	t        *testing.T
+	app      *fiber.App
+	rg       *RouteGroup
+	settings *settingsStub
+}
+
+// settingsStub answers settings calls, failing with status when it is set
+type settingsStub struct {
+	status atomic.Int32
+	rtp    string
+	wins   string
+}
+
+func (s *settingsStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := s.status.Load(); status != 0 {
+		w.WriteHeader(int(status))
+		return
+	}
+	var resp settings.Response
+	resp.Data.GameBets = "0.1,1,2"
+	resp.Data.GameRTP = s.rtp
+	resp.Data.GameWins = s.wins
+	json.NewEncoder(w).Encode(resp)
+}
+
+// seededFloat64 is a reproducible, concurrency-safe draw for the local RNG
+func seededFloat64(seed uint64) func() float64 {
+	var mu sync.Mutex
+	r := rand.New(rand.NewPCG(seed, seed))
+	return func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return r.Float64()
+	}
+}
+
+func newTestServer(t *testing.T, configure func(rg *RouteGroup)) *testServer {
+	t.Helper()
+	stub := &settingsStub{rtp: "0.96", wins: "5000"}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	settingsClient := settings.NewClient(server.URL)
+	settingsClient.Backoff.MaxRetries = 0
+	provider := &rng.LocalProvider{Float64: seededFloat64(1)}
+	rg := NewRouteGroup(provider, settingsClient, provider, settingsClient, session.NewMemoryStore())
+	rg.Random = random.MathFactory{}
+	if configure != nil {
+		configure(rg)
+	}
+
+	app := fiber.New()
+	rg.Register(app)
+	rg.RegisterAdmin(app)
+	return &testServer{t: t, app: app, rg: rg, settings: stub}
+}
+
+// post sends a JSON body and decodes the JSON reply into a map
+func (s *testServer) post(path string, body any) (int, map[string]any) {
+	s.t.Helper()
+	status, data := s.do(http.MethodPost, path, body, nil)
+	var reply map[string]any
+	if err := json.Unmarshal(data, &reply); err != nil {
+		s.t.Fatalf("%s: invalid JSON reply %q: %v", path, data, err)
+	}
+	return status, reply
+}
+
+func (s *testServer) do(method, path string, body any, header http.Header) (int, []byte) {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// player is the identity of the test requests
+type player struct {
+	ClientID string `json:"client_id"`
+	GameID   string `json:"game_id"`
+	PlayerID string `json:"player_id"`
+}
+
+var testPlayer = player{ClientID: "c1", GameID: "birdspartydeluxe", PlayerID: "p1"}
+
+// stepBody builds a request body; state and token are only sent when set
+func stepBody(p player, betID string, extra map[string]any) map[string]any {
+	body := map[string]any{"client_id": p.ClientID, "game_id": p.GameID, "player_id": p.PlayerID, "bet_id": betID}
+	for k, v := range extra {
+		body[k] = v
+	}
+	return body
+}
+
+// nextStep returns the endpoint of the round's next step, or "" once the round ended.
+// The round's phase decides it, as a stage-cleared reply does not say whether more stage-cleared symbols follow.
+func nextStep(reply map[string]any) string {
+	state, _ := reply["gameState"].(map[string]any)
+	if state == nil {
+		return ""
+	}
+	switch RoundPhase(fmt.Sprint(state["phase"])) {
+	case PhaseAwaitingStageCleared:
+		return "/process-stage-cleared/birdspartydeluxe"
+	case PhaseCascading:
+		return "/cascade/birdspartydeluxe"
+	default:
+		return ""
+	}
+}
+
+// playRound spins betID and plays the round to its end, returning the last reply.
+// extra is sent with every request, and in stateless mode the state and token are carried over.
+func (s *testServer) playRound(p player, betID string, extra map[string]any) map[string]any {
+	s.t.Helper()
+	body := stepBody(p, betID, extra)
+	body["bet_amount"] = 1.0
+	status, reply := s.post("/spin/birdspartydeluxe", body)
+	if status != http.StatusOK {
+		s.t.Fatalf("spin %s: %d %v", betID, status, reply["message"])
+	}
+	for i := 0; ; i++ {
+		path := nextStep(reply)
+		if path == "" {
+			return reply
+		}
+		if i > 500 {
+			s.t.Fatalf("round %s did not end", betID)
+		}
+		body := stepBody(p, betID, extra)
+		if token, ok := reply["stateToken"].(string); ok && token != "" {
+			body["gameState"], body["stateToken"] = reply["gameState"], token
+		}
+		if status, reply = s.post(path, body); status != http.StatusOK {
+			s.t.Fatalf("%s of %s: %d %v", path, betID, status, reply["message"])
+		}
+	}
+}
+
+func betID(n int) string {
+	return fmt.Sprintf("bet-%d", n)
+}
+
+func mustJSON(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+	var reply map[string]any
+	if err := json.Unmarshal(data, &reply); err != nil {
+		t.Fatalf("invalid JSON %q: %v", data, err)
+	}
+	return reply
+}
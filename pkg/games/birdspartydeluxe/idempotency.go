@@ -0,0 +1,69 @@
+package birdspartydeluxe
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/idempotency"
+	"github.com/gofiber/fiber/v2"
+)
+
+// idempotent returns the idempotency middleware for a route, or a pass-through when it is disabled.
+// defaultStep is used when the client does not send a step number: a spin is always step 1 of
+// its round. Stage-cleared and cascade calls have no default and must send the step they expect
+// to produce, otherwise a retry of a step that succeeded would be played as the next step.
+func (rg *RouteGroup) idempotent(defaultStep int) fiber.Handler {
+	if rg.Idempotency == nil {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	middleware := idempotency.New(idempotency.Config{
+		Store: rg.Idempotency,
+		Key:   idempotencyKey(defaultStep),
+	})
+	if defaultStep > 0 {
+		return middleware
+	}
+	return func(c *fiber.Ctx) error {
+		var fields struct {
+			Step int `json:"step"`
+		}
+		// A body that does not parse is rejected by the handler
+		if err := json.Unmarshal(c.Body(), &fields); err == nil && fields.Step <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "step is required: send the previous response's gameState.step + 1",
+			})
+		}
+		return middleware(c)
+	}
+}
+
+// idempotencyKey keys a request by (client_id, player_id, bet_id, step)
+func idempotencyKey(defaultStep int) func(c *fiber.Ctx) (string, bool) {
+	return func(c *fiber.Ctx) (string, bool) {
+		var fields struct {
+			ClientID string `json:"client_id"`
+			PlayerID string `json:"player_id"`
+			BetID    string `json:"bet_id"`
+			Step     int    `json:"step"`
+		}
+		if err := json.Unmarshal(c.Body(), &fields); err != nil {
+			return "", false
+		}
+		if fields.Step == 0 {
+			fields.Step = defaultStep
+		}
+		if fields.ClientID == "" || fields.PlayerID == "" || fields.BetID == "" || fields.Step <= 0 {
+			return "", false
+		}
+		return strings.Join([]string{
+			strconv.Quote(fields.ClientID),
+			strconv.Quote(fields.PlayerID),
+			strconv.Quote(fields.BetID),
+			strconv.Itoa(fields.Step),
+		}, "/"), true
+	}
+}
@@ -0,0 +1,88 @@
+package birdspartydeluxe
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/idempotency"
+)
+
+// spinUntilMoreSteps spins new rounds until one has a step after its spin
+func (s *testServer) spinUntilMoreSteps(p player) (string, map[string]any) {
+	s.t.Helper()
+	for n := 1; n <= 200; n++ {
+		bet := betID(n)
+		reply := s.spin(p, bet)
+		if nextStep(reply) != "" {
+			return bet, reply
+		}
+	}
+	s.t.Fatal("no round with more than one step")
+	return "", nil
+}
+
+// spin starts the round of bet and returns the spin reply
+func (s *testServer) spin(p player, bet string) map[string]any {
+	s.t.Helper()
+	body := stepBody(p, bet, nil)
+	body["bet_amount"] = 1.0
+	status, reply := s.post("/spin/birdspartydeluxe", body)
+	if status != http.StatusOK {
+		s.t.Fatalf("spin %s: %d %v", bet, status, reply["message"])
+	}
+	return reply
+}
+
+func TestIdempotentRoundSteps(t *testing.T) {
+	s := newTestServer(t, func(rg *RouteGroup) {
+		rg.Idempotency = idempotency.NewMemoryStore(time.Hour)
+	})
+	bet, spin := s.spinUntilMoreSteps(testPlayer)
+	path := nextStep(spin)
+
+	if status, reply := s.post(path, stepBody(testPlayer, bet, nil)); status != http.StatusBadRequest {
+		t.Errorf("step without a step number = %d %v, want 400", status, reply["message"])
+	}
+	if status, reply := s.post(path, stepBody(testPlayer, bet, map[string]any{"step": 5})); status != http.StatusConflict {
+		t.Errorf("step out of sequence = %d %v, want 409", status, reply["message"])
+	}
+
+	body := stepBody(testPlayer, bet, map[string]any{"step": 2})
+	status, first := s.do(http.MethodPost, path, body, nil)
+	if status != http.StatusOK {
+		t.Fatalf("step 2 = %d %s", status, first)
+	}
+	var third []byte
+	if next := nextStep(mustJSON(t, first)); next != "" {
+		if status, third = s.do(http.MethodPost, next, stepBody(testPlayer, bet, map[string]any{"step": 3}), nil); status != http.StatusOK {
+			t.Fatalf("step 3 = %d %s", status, third)
+		}
+	}
+
+	// A late retry of step 2 gets step 2's response, even once step 3 was played
+	status, retry := s.do(http.MethodPost, path, body, nil)
+	if status != http.StatusOK || string(retry) != string(first) {
+		t.Errorf("retry of step 2 = %d %s, want the original response %s", status, retry, first)
+	}
+	if third != nil && string(retry) == string(third) {
+		t.Error("retry of step 2 replayed step 3")
+	}
+}
+
+func TestIdempotentSpin(t *testing.T) {
+	s := newTestServer(t, func(rg *RouteGroup) {
+		rg.Idempotency = idempotency.NewMemoryStore(time.Hour)
+	})
+	body := stepBody(testPlayer, "bet-1", map[string]any{"bet_amount": 1.0})
+	_, first := s.do(http.MethodPost, "/spin/birdspartydeluxe", body, nil)
+	status, retry := s.do(http.MethodPost, "/spin/birdspartydeluxe", body, nil)
+	if status != http.StatusOK || string(retry) != string(first) {
+		t.Errorf("retried spin = %d %s, want the original response", status, retry)
+	}
+
+	body["bet_amount"] = 2.0
+	if status, _ := s.do(http.MethodPost, "/spin/birdspartydeluxe", body, nil); status != http.StatusConflict {
+		t.Errorf("spin reusing bet_id with another amount = %d, want 409", status)
+	}
+}
@@ -0,0 +1,675 @@
+package birdspartydeluxe
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownMathModel is returned when a round was played with a math model that is not loaded
+var ErrUnknownMathModel = errors.New("math model of the round is not loaded")
+
+// MathModel is the game math: grid sizes, connection rules, symbol weights, paytables and
+// the feature constants. Models are versioned JSON or YAML files, validated when they are loaded.
+type MathModel struct {
+	Version                 string                `json:"version"`
+	Levels                  map[Level]*LevelModel `json:"levels"`
+	ForcedClover            *ForcedClover         `json:"forcedClover"`
+	BoomingReelsMultipliers []float64             `json:"boomingReelsMultipliers"` // By booming reels level, from 1x
+	StageProgressTarget     int                   `json:"stageProgressTarget"`     // Stage-cleared symbols that advance the level
+	FreeSpinsAwarded        int                   `json:"freeSpinsAwarded"`        // Free spins triggered by a rainbow egg
+
+	// Profiles are RTP variants of the model, chosen by the settings RTP (see ForRTP)
+	Profiles []*MathProfile `json:"profiles,omitempty"`
+
+	// WeightProfiles override symbol weights per environment, chosen with the environment's clients (see WithWeights)
+	WeightProfiles []*WeightProfile `json:"weightProfiles,omitempty"`
+
+	id            string
+	profile       string                // Name of the profile this model plays, empty for the model itself
+	weightProfile string                // Name of the weight profile this model plays, empty for none
+	variants      map[string]*MathModel // By profile name
+	weighted      map[string]*MathModel // This model with each weight profile applied, by weight profile name
+}
+
+// LevelModel is the math of one level
+type LevelModel struct {
+	Level         Level                      `json:"-"`
+	GridSize      int                        `json:"gridSize"`
+	MinConnection int                        `json:"minConnection"`
+	Weights       map[Symbol]float64         `json:"weights"`  // Relative draw weights of the level's symbols
+	Paytable      map[Symbol]map[int]float64 `json:"paytable"` // Credits by connection size, for every size from minConnection to gridSize²
+	ForcedClover  ForcedClover               `json:"-"`        // The model's or profile's
+}
+
+// ForcedClover holds the probabilities of placing a clover on purpose, so booming reels trigger more often
+type ForcedClover struct {
+	WinGrid float64 `json:"winGrid"` // A forced win is a clover connection
+	Gravity float64 `json:"gravity"` // A cell refilled after stage-cleared symbols is a clover
+	Cascade float64 `json:"cascade"` // A cell refilled after a cascade is a clover
+}
+
+// MathProfile is an RTP variant of a math model with its own symbol weights and forced-clover probabilities
+type MathProfile struct {
+	Name         string                       `json:"name"`
+	RTP          float64                      `json:"rtp"`
+	Weights      map[Level]map[Symbol]float64 `json:"weights"` // By level, as LevelModel.Weights
+	ForcedClover *ForcedClover                `json:"forcedClover"`
+}
+
+// WeightProfile replaces some symbol weights of every RTP profile in one environment, such as
+// the stage-cleared weights, which are raised for testers to reach the next levels quickly
+type WeightProfile struct {
+	Name     string                       `json:"name"`
+	TestOnly bool                         `json:"testOnly,omitempty"` // Refused by the production environment
+	Weights  map[Level]map[Symbol]float64 `json:"weights"`            // By level; symbols left out keep their weight
+}
+
+//go:embed models/default.json
+var defaultMathModelJSON []byte
+
+var (
+	defaultMathModel = mustParseMathModel(defaultMathModelJSON)
+	mathModels       sync.Map // ID -> *MathModel
+)
+
+func init() {
+	mathModels.Store(defaultMathModel.ID(), defaultMathModel)
+}
+
+// DefaultMathModel returns the built-in math model (models/default.json)
+func DefaultMathModel() *MathModel {
+	return defaultMathModel
+}
+
+// LoadMathModel reads and validates a JSON or YAML math model file, by its extension.
+// Loaded models can resume and replay the rounds recorded with their ID.
+func LoadMathModel(path string) (*MathModel, error) {
+	var parse func([]byte) (*MathModel, error)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		parse = ParseMathModel
+	case ".yaml", ".yml":
+		parse = ParseMathModelYAML
+	default:
+		return nil, fmt.Errorf("math model %s: unsupported format %q, expected .json, .yaml or .yml", path, ext)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	model, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("math model %s: %w", path, err)
+	}
+	if loaded, ok := mathModels.LoadOrStore(model.ID(), model); ok {
+		return loaded.(*MathModel), nil
+	}
+	return model, nil
+}
+
+// LoadOperatorMathModels loads the math models chosen per operator, given as "client_id:path,client_id:path"
+func LoadOperatorMathModels(spec string) (map[string]*MathModel, error) {
+	models := make(map[string]*MathModel)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		clientID, path, ok := strings.Cut(entry, ":")
+		if !ok || clientID == "" || path == "" {
+			return nil, fmt.Errorf("invalid operator math model %q, expected client_id:path", entry)
+		}
+		if _, exists := models[clientID]; exists {
+			return nil, fmt.Errorf("duplicate math model for operator %s", clientID)
+		}
+		model, err := LoadMathModel(path)
+		if err != nil {
+			return nil, err
+		}
+		models[clientID] = model
+	}
+	return models, nil
+}
+
+// ParseMathModel decodes a JSON math model, rejecting unknown fields, and validates it
+func ParseMathModel(data []byte) (*MathModel, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var model MathModel
+	if err := decoder.Decode(&model); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the model")
+	}
+	for level, levelModel := range model.Levels {
+		if levelModel == nil {
+			return nil, fmt.Errorf("level %d: missing", level)
+		}
+		levelModel.Level = level
+	}
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+
+	// The ID changes with any change to the math, whatever the version says
+	canonical, err := json.Marshal(&model)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+	model.id = model.Version + "@" + hex.EncodeToString(sum[:])[:16]
+
+	for _, levelModel := range model.Levels {
+		levelModel.ForcedClover = *model.ForcedClover
+	}
+	sort.Slice(model.Profiles, func(i, j int) bool { return model.Profiles[i].RTP < model.Profiles[j].RTP })
+	model.variants = make(map[string]*MathModel, len(model.Profiles))
+	for _, profile := range model.Profiles {
+		model.variants[profile.Name] = model.variant(profile)
+	}
+	model.weigh()
+	for _, variant := range model.variants {
+		variant.weigh()
+	}
+	return &model, nil
+}
+
+// ParseMathModelYAML decodes a YAML math model. It holds the same fields as a JSON model and is
+// converted to JSON first, so it is validated the same way and a model gets the same ID in either format.
+func ParseMathModelYAML(data []byte) (*MathModel, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(new(any)); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after the model")
+	}
+	converted, err := jsonValue(document)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(converted)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMathModel(jsonData)
+}
+
+// jsonValue converts a decoded YAML value to one encoding/json can marshal: YAML mapping keys
+// may be numbers, such as levels and connection sizes, where JSON object keys are strings
+func jsonValue(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			converted, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = converted
+		}
+		return v, nil
+	case map[any]any:
+		object := make(map[string]any, len(v))
+		for key, item := range v {
+			switch key.(type) {
+			case string, int, uint64, float64, bool:
+			default:
+				return nil, fmt.Errorf("unsupported mapping key %v", key)
+			}
+			converted, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			object[fmt.Sprint(key)] = converted
+		}
+		return object, nil
+	case []any:
+		for i, item := range v {
+			converted, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// variant returns the model played by a profile: the model's math with the profile's weights and forced clovers
+func (m *MathModel) variant(profile *MathProfile) *MathModel {
+	variant := &MathModel{
+		Version:                 m.Version,
+		Levels:                  make(map[Level]*LevelModel, len(m.Levels)),
+		ForcedClover:            profile.ForcedClover,
+		BoomingReelsMultipliers: m.BoomingReelsMultipliers,
+		StageProgressTarget:     m.StageProgressTarget,
+		FreeSpinsAwarded:        m.FreeSpinsAwarded,
+		WeightProfiles:          m.WeightProfiles,
+		id:                      m.id,
+		profile:                 profile.Name,
+	}
+	for level, levelModel := range m.Levels {
+		levelVariant := *levelModel
+		levelVariant.Weights = profile.Weights[level]
+		levelVariant.ForcedClover = *profile.ForcedClover
+		variant.Levels[level] = &levelVariant
+	}
+	return variant
+}
+
+// weigh builds the model played under each weight profile of the model
+func (m *MathModel) weigh() {
+	m.weighted = make(map[string]*MathModel, len(m.WeightProfiles))
+	for _, weightProfile := range m.WeightProfiles {
+		weighted := *m
+		weighted.Levels = make(map[Level]*LevelModel, len(m.Levels))
+		weighted.weightProfile = weightProfile.Name
+		weighted.weighted = nil
+		for level, levelModel := range m.Levels {
+			levelWeighted := *levelModel
+			levelWeighted.Weights = make(map[Symbol]float64, len(levelModel.Weights))
+			for symbol, weight := range levelModel.Weights {
+				levelWeighted.Weights[symbol] = weight
+			}
+			for symbol, weight := range weightProfile.Weights[level] {
+				levelWeighted.Weights[symbol] = weight
+			}
+			weighted.Levels[level] = &levelWeighted
+		}
+		m.weighted[weightProfile.Name] = &weighted
+	}
+}
+
+func mustParseMathModel(data []byte) *MathModel {
+	model, err := ParseMathModel(data)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in math model: %v", err))
+	}
+	return model
+}
+
+// Validate checks that the model can be played: every level is defined, weights are positive,
+// paytables cover every connection size from minConnection to gridSize², the booming reels
+// multipliers rise from 1x, every profile is complete and weight profiles only weigh symbols the
+// levels draw. All problems are reported together.
+func (m *MathModel) Validate() error {
+	var problems []error
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if strings.TrimSpace(m.Version) == "" {
+		fail("version is required")
+	}
+	for level := range m.Levels {
+		if err := level.ValidateLevel(); err != nil {
+			fail("%v", err)
+		}
+	}
+	for _, level := range []Level{Level1, Level2, Level3} {
+		if m.Levels[level] == nil {
+			fail("level %d: missing", level)
+			continue
+		}
+		problems = append(problems, m.Levels[level].validate()...)
+	}
+	problems = append(problems, m.ForcedClover.validate("forcedClover")...)
+
+	if len(m.BoomingReelsMultipliers) == 0 {
+		fail("boomingReelsMultipliers: at least one multiplier is required")
+	} else if m.BoomingReelsMultipliers[0] != 1 {
+		fail("boomingReelsMultipliers: must start at 1, got %g", m.BoomingReelsMultipliers[0])
+	}
+	for i := 1; i < len(m.BoomingReelsMultipliers); i++ {
+		if m.BoomingReelsMultipliers[i] <= m.BoomingReelsMultipliers[i-1] {
+			fail("boomingReelsMultipliers: must be increasing, %g follows %g", m.BoomingReelsMultipliers[i], m.BoomingReelsMultipliers[i-1])
+		}
+	}
+	if m.StageProgressTarget < 1 {
+		fail("stageProgressTarget: must be at least 1, got %d", m.StageProgressTarget)
+	}
+	if m.FreeSpinsAwarded < 1 {
+		fail("freeSpinsAwarded: must be at least 1, got %d", m.FreeSpinsAwarded)
+	}
+
+	names := make(map[string]bool, len(m.Profiles))
+	rtps := make(map[float64]string, len(m.Profiles))
+	for i, profile := range m.Profiles {
+		if profile == nil || profile.Name == "" {
+			fail("profiles: profile %d has no name", i+1)
+			continue
+		}
+		if names[profile.Name] {
+			fail("profile %s: duplicate name", profile.Name)
+		}
+		names[profile.Name] = true
+		if profile.RTP < settings.MinRTP || profile.RTP > settings.MaxRTP {
+			fail("profile %s: rtp must be between %.2f and %.2f, got %g", profile.Name, settings.MinRTP, settings.MaxRTP, profile.RTP)
+		}
+		if other, ok := rtps[profile.RTP]; ok {
+			fail("profile %s: rtp %g is already the rtp of %s", profile.Name, profile.RTP, other)
+		}
+		rtps[profile.RTP] = profile.Name
+		for level := range profile.Weights {
+			if err := level.ValidateLevel(); err != nil {
+				fail("profile %s: %v", profile.Name, err)
+			}
+		}
+		for _, level := range []Level{Level1, Level2, Level3} {
+			weights, ok := profile.Weights[level]
+			if !ok {
+				fail("profile %s: level %d: weights: missing", profile.Name, level)
+				continue
+			}
+			problems = append(problems, validateWeights(fmt.Sprintf("profile %s: level %d: weights", profile.Name, level), level, weights)...)
+		}
+		problems = append(problems, profile.ForcedClover.validate(fmt.Sprintf("profile %s: forcedClover", profile.Name))...)
+	}
+
+	weightNames := make(map[string]bool, len(m.WeightProfiles))
+	for i, weightProfile := range m.WeightProfiles {
+		if weightProfile == nil || weightProfile.Name == "" {
+			fail("weightProfiles: weight profile %d has no name", i+1)
+			continue
+		}
+		if weightNames[weightProfile.Name] {
+			fail("weight profile %s: duplicate name", weightProfile.Name)
+		}
+		weightNames[weightProfile.Name] = true
+		for _, level := range sortedLevels(weightProfile.Weights) {
+			if err := level.ValidateLevel(); err != nil {
+				fail("weight profile %s: %v", weightProfile.Name, err)
+				continue
+			}
+			weights := weightProfile.Weights[level]
+			for _, symbol := range sortedSymbols(weights) {
+				weight := weights[symbol]
+				if !containsSymbol(levelSymbols(level), symbol) {
+					fail("weight profile %s: level %d: %s cannot appear on this level", weightProfile.Name, level, symbol)
+				} else if !(weight > 0) || math.IsInf(weight, 0) {
+					fail("weight profile %s: level %d: %s must be positive, got %g", weightProfile.Name, level, symbol, weight)
+				}
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// validate checks that every forced-clover probability is between 0 and 1
+func (fc *ForcedClover) validate(path string) []error {
+	if fc == nil {
+		return []error{fmt.Errorf("%s: missing", path)}
+	}
+	var problems []error
+	for _, p := range []struct {
+		name  string
+		value float64
+	}{{"winGrid", fc.WinGrid}, {"gravity", fc.Gravity}, {"cascade", fc.Cascade}} {
+		if !(p.value >= 0 && p.value <= 1) {
+			problems = append(problems, fmt.Errorf("%s: %s must be between 0 and 1, got %g", path, p.name, p.value))
+		}
+	}
+	return problems
+}
+
+// validateWeights checks that weights hold every symbol the level draws, and only those, with positive weights
+func validateWeights(path string, level Level, weights map[Symbol]float64) []error {
+	var problems []error
+	expected := levelSymbols(level)
+	for _, symbol := range expected {
+		if _, ok := weights[symbol]; !ok {
+			problems = append(problems, fmt.Errorf("%s: missing %s", path, symbol))
+		}
+	}
+	for _, symbol := range sortedSymbols(weights) {
+		weight := weights[symbol]
+		if !containsSymbol(expected, symbol) {
+			problems = append(problems, fmt.Errorf("%s: %s cannot appear on this level", path, symbol))
+		} else if !(weight > 0) || math.IsInf(weight, 0) {
+			problems = append(problems, fmt.Errorf("%s: %s must be positive, got %g", path, symbol, weight))
+		}
+	}
+	return problems
+}
+
+// levelSymbols returns the symbols a level draws; no other level's stage-cleared symbol may appear
+func levelSymbols(level Level) []Symbol {
+	return []Symbol{SymbolPurpleOwl, SymbolGreenOwl, SymbolYellowOwl, SymbolBlueOwl, SymbolRedOwl, SymbolClover, level.GetStageClearedSymbol(), SymbolFreeGame}
+}
+
+// validate checks the math of one level
+func (lm *LevelModel) validate() []error {
+	var problems []error
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf("level %d: "+format, append([]any{lm.Level}, args...)...))
+	}
+
+	if lm.GridSize < 2 {
+		fail("gridSize: must be at least 2, got %d", lm.GridSize)
+	}
+	// Forced wins lay the connection out along one row
+	if lm.MinConnection < 2 || lm.MinConnection > lm.GridSize {
+		fail("minConnection: must be between 2 and the grid size %d, got %d", lm.GridSize, lm.MinConnection)
+	}
+
+	problems = append(problems, validateWeights(fmt.Sprintf("level %d: weights", lm.Level), lm.Level, lm.Weights)...)
+
+	connectionSymbols := []Symbol{SymbolPurpleOwl, SymbolGreenOwl, SymbolYellowOwl, SymbolBlueOwl, SymbolRedOwl, SymbolClover}
+	for _, symbol := range sortedSymbols(lm.Paytable) {
+		if !containsSymbol(connectionSymbols, symbol) {
+			fail("paytable: %s does not form connections", symbol)
+		}
+	}
+	if lm.MinConnection < 2 || lm.GridSize < 2 {
+		return problems
+	}
+	cells := lm.GridSize * lm.GridSize
+	for _, symbol := range connectionSymbols {
+		payouts, ok := lm.Paytable[symbol]
+		if !ok {
+			fail("paytable: missing %s", symbol)
+			continue
+		}
+		for count := lm.MinConnection; count <= cells; count++ {
+			payout, ok := payouts[count]
+			switch {
+			case !ok:
+				fail("paytable: %s has no payout for %d connected symbols", symbol, count)
+			case !(payout > 0) || math.IsInf(payout, 0):
+				fail("paytable: %s pays %g for %d connected symbols, must be positive", symbol, payout, count)
+			}
+		}
+		counts := make([]int, 0, len(payouts))
+		for count := range payouts {
+			counts = append(counts, count)
+		}
+		sort.Ints(counts)
+		for _, count := range counts {
+			if count < lm.MinConnection || count > cells {
+				fail("paytable: %s pays %d connected symbols, outside %d-%d", symbol, count, lm.MinConnection, cells)
+			}
+		}
+	}
+	return problems
+}
+
+// sortedSymbols returns the keys of a symbol map in a stable order, for readable errors
+func sortedSymbols[V any](m map[Symbol]V) []Symbol {
+	symbols := make([]Symbol, 0, len(m))
+	for symbol := range m {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i] < symbols[j] })
+	return symbols
+}
+
+// sortedLevels returns the keys of a level map in order
+func sortedLevels[V any](m map[Level]V) []Level {
+	levels := make([]Level, 0, len(m))
+	for level := range m {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	return levels
+}
+
+func containsSymbol(symbols []Symbol, symbol Symbol) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// ID identifies the model on every round: its version and a hash of its content, profiles included
+func (m *MathModel) ID() string {
+	return m.id
+}
+
+// Profile returns the name of the profile the model plays, empty for the model's own math
+func (m *MathModel) Profile() string {
+	return m.profile
+}
+
+// WeightProfile returns the name of the weight profile the model plays, empty for none
+func (m *MathModel) WeightProfile() string {
+	return m.weightProfile
+}
+
+// ForRTP returns the profile played at a settings RTP: the profile of that RTP or, failing
+// that, of the nearest lower RTP. An RTP below every profile plays the lowest one, so the
+// choice never depends on whether an operator's RTP happens to be listed. A model without
+// profiles plays its own math at any RTP.
+func (m *MathModel) ForRTP(rtp float64) *MathModel {
+	if len(m.Profiles) == 0 {
+		return m
+	}
+	chosen := m.Profiles[0]
+	for _, profile := range m.Profiles {
+		// RTPs come as decimals from the settings service; compare them to the fourth digit
+		if math.Round(profile.RTP*10000) <= math.Round(rtp*10000) {
+			chosen = profile
+		}
+	}
+	return m.variants[chosen.Name]
+}
+
+// WithWeights returns the model with a weight profile applied. A model without that weight
+// profile plays its own weights; CheckWeightProfile refuses such a setup at startup.
+func (m *MathModel) WithWeights(weightProfile string) *MathModel {
+	if weighted, ok := m.weighted[weightProfile]; ok {
+		return weighted
+	}
+	return m
+}
+
+// CheckWeightProfile reports whether an environment can play the model with a weight profile:
+// the model must define it, and production must not use a profile marked test-only.
+// A model without weight profiles plays its own weights in every environment.
+func (m *MathModel) CheckWeightProfile(name string, production bool) error {
+	if len(m.WeightProfiles) == 0 {
+		return nil
+	}
+	for _, weightProfile := range m.WeightProfiles {
+		if weightProfile.Name != name {
+			continue
+		}
+		if production && weightProfile.TestOnly {
+			return fmt.Errorf("math model %s: weight profile %q is test-only and cannot be used in production", m.ID(), name)
+		}
+		return nil
+	}
+	return fmt.Errorf("math model %s has no weight profile %q", m.ID(), name)
+}
+
+// Level returns the math of a level, or of level 1 for an unknown level
+func (m *MathModel) Level(level Level) *LevelModel {
+	if levelModel, ok := m.Levels[level]; ok {
+		return levelModel
+	}
+	return m.Levels[Level1]
+}
+
+// BoomingReelsMultiplier returns the multiplier of a booming reels level
+func (m *MathModel) BoomingReelsMultiplier(level int) float64 {
+	if level < 0 || level >= len(m.BoomingReelsMultipliers) {
+		return 1.0 // Default multiplier
+	}
+	return m.BoomingReelsMultipliers[level]
+}
+
+// lookupMathModel finds a loaded model by ID. Rounds recorded before models had IDs use the built-in one.
+func lookupMathModel(id string) (*MathModel, bool) {
+	if id == "" {
+		return defaultMathModel, true
+	}
+	model, ok := mathModels.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return model.(*MathModel), true
+}
+
+// useMathModel plays gs with model from now on and records its ID and profiles on the round
+func (gs *GameState) useMathModel(model *MathModel) {
+	gs.MathModel = model.ID()
+	gs.MathProfile = model.Profile()
+	gs.WeightProfile = model.WeightProfile()
+	gs.model = model
+}
+
+// bindMathModel resolves the math model and profiles recorded on gs, which must still be loaded
+func (gs *GameState) bindMathModel() error {
+	if gs.model != nil && gs.model.ID() == gs.MathModel && gs.model.Profile() == gs.MathProfile && gs.model.WeightProfile() == gs.WeightProfile {
+		return nil
+	}
+	model, ok := lookupMathModel(gs.MathModel)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownMathModel, gs.MathModel)
+	}
+	if gs.MathProfile != "" {
+		if model, ok = model.variants[gs.MathProfile]; !ok {
+			return fmt.Errorf("%w: %s has no profile %s", ErrUnknownMathModel, gs.MathModel, gs.MathProfile)
+		}
+	}
+	if gs.WeightProfile != "" {
+		if model, ok = model.weighted[gs.WeightProfile]; !ok {
+			return fmt.Errorf("%w: %s has no weight profile %s", ErrUnknownMathModel, gs.MathModel, gs.WeightProfile)
+		}
+	}
+	gs.model = model
+	return nil
+}
+
+// math returns the math model gs is played with
+func (gs *GameState) math() *MathModel {
+	if gs.bindMathModel() != nil {
+		return defaultMathModel
+	}
+	return gs.model
+}
+
+// level returns the math of the level gs is on
+func (gs *GameState) level() *LevelModel {
+	return gs.math().Level(gs.CurrentLevel)
+}
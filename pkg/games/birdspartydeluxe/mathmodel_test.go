@@ -0,0 +1,239 @@
+package birdspartydeluxe
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// editedModel returns the built-in model JSON after edit changed its decoded form
+func editedModel(t *testing.T, edit func(m map[string]any)) []byte {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal(defaultMathModelJSON, &m); err != nil {
+		t.Fatal(err)
+	}
+	edit(m)
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// object walks nested JSON objects by key
+func object(m map[string]any, keys ...string) map[string]any {
+	for _, key := range keys {
+		m = m[key].(map[string]any)
+	}
+	return m
+}
+
+func TestParseMathModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(m map[string]any)
+		wantErr string // empty for a valid model
+	}{
+		{"built-in model", func(m map[string]any) {}, ""},
+		{"no version", func(m map[string]any) { m["version"] = " " }, "version is required"},
+		{"unknown field", func(m map[string]any) { m["bonus"] = 1 }, "unknown field"},
+		{"missing level", func(m map[string]any) { delete(object(m, "levels"), "3") }, "level 3: missing"},
+		{"unknown level", func(m map[string]any) { object(m, "levels")["4"] = object(m, "levels", "3") }, "invalid level: 4"},
+		{"grid too small", func(m map[string]any) { object(m, "levels", "1")["gridSize"] = 1 }, "level 1: gridSize"},
+		{"connection longer than the grid", func(m map[string]any) { object(m, "levels", "1")["minConnection"] = 5 }, "level 1: minConnection"},
+		{"zero weight", func(m map[string]any) { object(m, "levels", "2", "weights")["clover"] = 0 }, "level 2: weights"},
+		{"missing payout", func(m map[string]any) { delete(object(m, "levels", "1", "paytable", "clover"), "16") }, "clover has no payout for 16"},
+		{"payout beyond the grid", func(m map[string]any) { object(m, "levels", "1", "paytable", "clover")["17"] = 1 }, "outside 4-16"},
+		{"negative payout", func(m map[string]any) { object(m, "levels", "1", "paytable", "red_owl")["4"] = -1 }, "must be positive"},
+		{"paying symbol that does not connect", func(m map[string]any) { object(m, "levels", "1", "paytable")["free_game"] = map[string]any{"4": 1} }, "free_game does not form connections"},
+		{"forced clover above 1", func(m map[string]any) { object(m, "forcedClover")["cascade"] = 1.5 }, "forcedClover"},
+		{"multipliers not from 1x", func(m map[string]any) { m["boomingReelsMultipliers"] = []any{2, 3} }, "must start at 1"},
+		{"multipliers not increasing", func(m map[string]any) { m["boomingReelsMultipliers"] = []any{1, 3, 2} }, "must be increasing"},
+		{"no stage progress target", func(m map[string]any) { m["stageProgressTarget"] = 0 }, "stageProgressTarget"},
+		{"no free spins", func(m map[string]any) { m["freeSpinsAwarded"] = 0 }, "freeSpinsAwarded"},
+		{"duplicate profile rtp", func(m map[string]any) { m["profiles"].([]any)[1].(map[string]any)["rtp"] = 0.94 }, "is already the rtp of"},
+		{"profile rtp out of range", func(m map[string]any) { m["profiles"].([]any)[0].(map[string]any)["rtp"] = 1.2 }, "rtp must be between"},
+		{"profile without a level", func(m map[string]any) {
+			delete(m["profiles"].([]any)[0].(map[string]any)["weights"].(map[string]any), "2")
+		}, "level 2: weights: missing"},
+		{"weight profile of a symbol the level does not draw", func(m map[string]any) {
+			m["weightProfiles"].([]any)[1].(map[string]any)["weights"] = map[string]any{"1": map[string]any{"cherry": 1}}
+		}, "cannot appear on this level"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := ParseMathModel(editedModel(t, tt.edit))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("ParseMathModel: %v", err)
+			case tt.wantErr == "" && model.ID() != DefaultMathModel().ID():
+				t.Errorf("ID = %s, want the built-in model's %s", model.ID(), DefaultMathModel().ID())
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// yamlModel returns the model JSON as YAML, with levels and connection sizes as the numeric keys a person would write
+func yamlModel(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var m any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	var numericKeys func(v any) any
+	numericKeys = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			out := make(map[any]any, len(v))
+			for key, item := range v {
+				if n, err := strconv.Atoi(key); err == nil {
+					out[n] = numericKeys(item)
+				} else {
+					out[key] = numericKeys(item)
+				}
+			}
+			return out
+		case []any:
+			for i, item := range v {
+				v[i] = numericKeys(item)
+			}
+		}
+		return v
+	}
+	out, err := yaml.Marshal(numericKeys(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestParseMathModelYAML(t *testing.T) {
+	builtIn := yamlModel(t, defaultMathModelJSON)
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string // empty for a valid model
+	}{
+		{"built-in model", builtIn, ""},
+		{"unknown field", yamlModel(t, editedModel(t, func(m map[string]any) { m["bonus"] = 1 })), "unknown field"},
+		{"invalid model", yamlModel(t, editedModel(t, func(m map[string]any) { object(m, "levels", "1")["gridSize"] = 1 })), "level 1: gridSize"},
+		{"wrong type", []byte("version: 1.0.0\nlevels: [1, 2]\n"), "cannot unmarshal"},
+		{"second document", append(append([]byte{}, builtIn...), "---\nversion: 2.0.0\n"...), "unexpected data after the model"},
+		{"not YAML", []byte("version: [1.0.0"), "yaml"},
+		{"empty", nil, "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := ParseMathModelYAML(tt.data)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("ParseMathModelYAML: %v", err)
+			case tt.wantErr == "" && model.ID() != DefaultMathModel().ID():
+				// The same math has the same ID in either format
+				t.Errorf("ID = %s, want the built-in model's %s", model.ID(), DefaultMathModel().ID())
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadMathModelFormats(t *testing.T) {
+	dir := t.TempDir()
+	yamlData := yamlModel(t, defaultMathModelJSON)
+	tests := []struct {
+		file    string
+		data    []byte
+		wantErr string
+	}{
+		{"model.json", defaultMathModelJSON, ""},
+		{"model.yaml", yamlData, ""},
+		{"MODEL.YML", yamlData, ""},
+		{"yaml-in.json", yamlData, "invalid character"},
+		{"model.toml", defaultMathModelJSON, `unsupported format ".toml"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			model, err := LoadMathModel(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if model != DefaultMathModel() {
+				t.Errorf("loaded %s, want the built-in model already registered under its ID", model.ID())
+			}
+		})
+	}
+}
+
+func TestMathModelIDFollowsContent(t *testing.T) {
+	model, err := ParseMathModel(editedModel(t, func(m map[string]any) {
+		object(m, "levels", "1", "weights")["clover"] = 0.21
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.ID() == DefaultMathModel().ID() {
+		t.Errorf("a model with other weights has the built-in model's ID %s", model.ID())
+	}
+	if !strings.HasPrefix(model.ID(), model.Version+"@") {
+		t.Errorf("ID %s does not start with the version %s", model.ID(), model.Version)
+	}
+}
+
+func TestMathModelForRTP(t *testing.T) {
+	tests := []struct {
+		rtp  float64
+		want string
+	}{
+		{0.90, "rtp94"},
+		{0.94, "rtp94"},
+		{0.9499, "rtp94"},
+		{0.96, "rtp96"},
+		{0.965, "rtp96"},
+		{0.99, "rtp97"},
+	}
+	for _, tt := range tests {
+		if got := DefaultMathModel().ForRTP(tt.rtp).Profile(); got != tt.want {
+			t.Errorf("ForRTP(%g) = %s, want %s", tt.rtp, got, tt.want)
+		}
+	}
+}
+
+func TestMathModelCheckWeightProfile(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    string
+		production bool
+		wantErr    bool
+	}{
+		{"production weights in production", "production", true, false},
+		{"test weights in test", "test", false, false},
+		{"test-only weights in production", "test", true, true},
+		{"unknown weights", "tuned", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := DefaultMathModel().CheckWeightProfile(tt.profile, tt.production); (err != nil) != tt.wantErr {
+				t.Errorf("CheckWeightProfile(%q, %v) = %v, want error %v", tt.profile, tt.production, err, tt.wantErr)
+			}
+		})
+	}
+}
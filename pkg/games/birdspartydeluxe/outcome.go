@@ -0,0 +1,105 @@
+package birdspartydeluxe
+
+import (
+	"errors"
+	"log"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	errSettingsUnavailable = errors.New("failed to retrieve game settings")
+	errSettingsInvalid     = errors.New("game settings rejected")
+	errOutcomeUnavailable  = errors.New("failed to determine outcome")
+)
+
+// requestOutcome fetches the player's RTP and asks the RNG service whether the step's payout may stand,
+// at the share of that RTP the player's budget gives the step. Under the degrade policy the round's RTP stands in for unavailable settings and the fallback
+// provider for an unavailable RNG service, and the outcome is marked as degraded.
+func requestOutcome(c *fiber.Ctx, clients clientSet, ref roundRef, roundRTP, betAmount, totalWinnings, share float64) (*Outcome, error) {
+	degraded := false
+	rtp, err := clients.Settings.GetRTP(ref.ClientID, ref.GameID, ref.PlayerID)
+	if err != nil {
+		log.Printf("Failed to get RTP: %v", err)
+		if clients.Failure != FailDegrade || roundRTP <= 0 || settings.Permanent(err) {
+			return nil, settingsError(err)
+		}
+		log.Printf("⚠️  Settings unavailable for bet %s, degrading to the round's RTP %.4f", ref.BetID, roundRTP)
+		rtp, degraded = roundRTP, true
+	}
+
+	// Call RNG
+	payoutMultiplier := totalWinnings / betAmount
+	ip := c.IP()
+	userAgent := c.Get("User-Agent")
+
+	log.Printf("✅IP: %v", ip)
+	log.Printf("✅User-Agent: %v", userAgent)
+	rngReq := rng.NewRequest(ref.ClientID, ref.GameID, ref.PlayerID, ref.BetID, rtp*share, payoutMultiplier, betAmount, ip, userAgent, false)
+	rngResp, err := clients.RNG.Send(c.UserContext(), rngReq)
+	if err != nil {
+		log.Printf("Failed to call RNG API: %v", err)
+		if clients.Failure != FailDegrade || !errors.Is(err, rng.ErrRNGUnavailable) {
+			return nil, errors.Join(errOutcomeUnavailable, err)
+		}
+		log.Printf("⚠️  RNG service unavailable for bet %s, degrading to the local outcome provider", ref.BetID)
+		rngResp, err = clients.Fallback.Send(c.UserContext(), rngReq)
+		if err != nil {
+			return nil, errors.Join(errOutcomeUnavailable, err)
+		}
+		degraded = true
+	}
+
+	return &Outcome{RTP: rtp, Request: rngReq, Response: rngResp, Degraded: degraded}, nil
+}
+
+// decideWith backs the engine's outcome decisions with the environment's settings and RNG services
+func decideWith(c *fiber.Ctx, clients clientSet, ref roundRef, roundRTP float64) DecideFunc {
+	return func(betAmount, totalWinnings, share float64) (*Outcome, error) {
+		return requestOutcome(c, clients, ref, roundRTP, betAmount, totalWinnings, share)
+	}
+}
+
+// settingsError classifies a failed settings call: a 4xx reply or an invalid payload
+// will fail the same way again, anything else leaves the settings unavailable for now
+func settingsError(err error) error {
+	if settings.Permanent(err) {
+		return errors.Join(errSettingsInvalid, err)
+	}
+	return errors.Join(errSettingsUnavailable, err)
+}
+
+// serviceUnavailable reports whether a step failed because settings or RNG could not be reached
+func serviceUnavailable(err error) bool {
+	return errors.Is(err, errSettingsUnavailable) || errors.Is(err, rng.ErrRNGUnavailable)
+}
+
+// outcomeError maps a failed outcome request to an error response. Unavailable services
+// answer 503: the step was voided and the same call can be retried.
+func outcomeError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errSettingsUnavailable):
+		return retryableError(c, "Failed to retrieve game settings")
+	case errors.Is(err, rng.ErrRNGUnavailable):
+		return retryableError(c, "RNG service unavailable")
+	case errors.Is(err, errSettingsInvalid):
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid game settings",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Failed to determine outcome",
+	})
+}
+
+func retryableError(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"status":    "error",
+		"message":   message,
+		"retryable": true,
+	})
+}
@@ -0,0 +1,70 @@
+package birdspartydeluxe
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+	"github.com/gofiber/fiber/v2"
+)
+
+// stepSource returns the random source of the round's current step, drawing the
+// round seed first when the round does not have one yet
+func (rg *RouteGroup) stepSource(ref roundRef, gameState *GameState) (random.Source, error) {
+	// In provably-fair mode every step takes the player's next nonce instead
+	if rg.Fairness != nil {
+		seed, err := rg.Fairness.NextSeed(session.Key(ref.ClientID, ref.PlayerID))
+		if err != nil {
+			return nil, err
+		}
+		gameState.Seed = seed
+		return rg.Fairness.Source(seed)
+	}
+
+	if gameState.Seed == "" {
+		seed, err := rg.Random.NewSeed()
+		if err != nil {
+			return nil, err
+		}
+		gameState.Seed = seed
+	}
+	return rg.Random.New(gameState.Seed, gameState.Step)
+}
+
+// seededSource rebuilds the random source of a step from its recorded seed,
+// so the step can be played again from its first draw
+func (rg *RouteGroup) seededSource(seed string, step int) (random.Source, error) {
+	// Provably-fair seeds carry the server seed hash, client seed and nonce
+	if strings.Contains(seed, ":") {
+		if rg.Fairness == nil {
+			return nil, errors.New("step was played in provably-fair mode, which is disabled")
+		}
+		return rg.Fairness.Source(seed)
+	}
+	return rg.Random.New(seed, step)
+}
+
+// stepSources rebuilds the random sources of the later steps of a round from its seed
+func (rg *RouteGroup) stepSources(seed string) StepSources {
+	return func(step int) (random.Source, error) {
+		return rg.seededSource(seed, step)
+	}
+}
+
+// revealedSeed returns the round seed once the round has ended. While it is being played the
+// seed stays on the server, because it determines every grid of the round's later steps.
+func revealedSeed(gameState GameState) string {
+	if !roundEnded(gameState.Phase) {
+		return ""
+	}
+	return gameState.Seed
+}
+
+// randomSourceError reports a step that could not get its random source
+func randomSourceError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Failed to initialize random source",
+	})
+}
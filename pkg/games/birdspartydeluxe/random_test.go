@@ -0,0 +1,71 @@
+package birdspartydeluxe
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
+)
+
+func TestRoundSeedIsRevealedWhenRoundEnds(t *testing.T) {
+	tests := []struct {
+		name   string
+		server func(t *testing.T) *testServer
+	}{
+		{"session", func(t *testing.T) *testServer { return newTestServer(t, nil) }},
+		{"stateless", newStatelessServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.server(t)
+			store, err := audit.NewFileStore(filepath.Join(t.TempDir(), "audit.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			s.rg.Audit = store
+
+			var carried map[string]any
+			for n := 1; n <= 20; n++ {
+				body := stepBody(testPlayer, betID(n), carried)
+				body["bet_amount"] = 1.0
+				path := "/spin/birdspartydeluxe"
+				for {
+					status, reply := s.post(path, body)
+					if status != http.StatusOK {
+						t.Fatalf("%s: %d %v", path, status, reply["message"])
+					}
+					state := reply["gameState"].(map[string]any)
+					for _, hidden := range []string{"seed", "budget"} {
+						if _, ok := state[hidden]; ok {
+							t.Fatalf("%s of %s sent the %s in gameState", path, betID(n), hidden)
+						}
+					}
+					if reply["stateToken"] != nil {
+						carried = carry(reply)
+					}
+					if path = nextStep(reply); path != "" {
+						if reply["roundSeed"] != nil {
+							t.Fatalf("%s of %s revealed the seed before the round ended", path, betID(n))
+						}
+						body = stepBody(testPlayer, betID(n), carried)
+						continue
+					}
+
+					records, err := store.Round(state["roundId"].(string))
+					if err != nil || len(records) == 0 {
+						t.Fatalf("audit records of %s: %v", betID(n), err)
+					}
+					// Every step of the round is played from the seed its spin drew
+					for _, record := range records {
+						if record.Seed == "" || reply["roundSeed"] != record.Seed {
+							t.Fatalf("roundSeed of %s = %v, want the audited seed %q of step %d", betID(n), reply["roundSeed"], record.Seed, record.Step)
+						}
+					}
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package birdspartydeluxe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrUnrecordedDecision is returned when a replayed step needs an RNG decision the original step never made
+var ErrUnrecordedDecision = errors.New("replayed step needs an RNG decision that was never recorded")
+
+// ErrUnusedDecision is returned when a replayed step never asks for the RNG decision the original step recorded
+var ErrUnusedDecision = errors.New("replayed step never asked for the recorded RNG decision")
+
+// ReplayStep re-executes an audited step: it rebuilds the state the engine started
+// from, then runs the engine with r and the recorded RNG response. sources rebuilds
+// the later steps a cycle spin plays ahead.
+func ReplayStep(record audit.Record, r random.Source, sources StepSources) (GameState, StepResult, error) {
+	var before, after GameState
+	if err := json.Unmarshal(record.StateBefore, &before); err != nil {
+		return GameState{}, StepResult{}, fmt.Errorf("invalid state before step %d: %w", record.Step, err)
+	}
+	if err := json.Unmarshal(record.StateAfter, &after); err != nil {
+		return GameState{}, StepResult{}, fmt.Errorf("invalid state after step %d: %w", record.Step, err)
+	}
+
+	gameState := cloneGameState(before)
+	action := RoundAction(record.Action)
+	if action == ActionSpin {
+		model, ok := lookupMathModel(after.MathModel)
+		if !ok {
+			return GameState{}, StepResult{}, fmt.Errorf("%w: %s", ErrUnknownMathModel, after.MathModel)
+		}
+		beginRound(&gameState, record.BetID, after.Bet.Amount, settings.GameSettings{MaxWin: after.MaxWin, RTP: after.RTP}, after.SpinFlow, model, after.WeightProfile)
+		if gameState.WeightProfile != after.WeightProfile {
+			return GameState{}, StepResult{}, fmt.Errorf("%w: %s has no weight profile %s", ErrUnknownMathModel, after.MathModel, after.WeightProfile)
+		}
+		gameState.RoundID = record.RoundID
+	} else {
+		gameState.Step++
+	}
+	gameState.Seed = record.Seed
+
+	// Auto-completed steps never asked for an outcome
+	if FailurePolicy(record.FailurePolicy) == FailAutoLoss {
+		result, err := autoCompleteStep(action, &gameState, r, sources)
+		return gameState, result, err
+	}
+	decisions := &recordedDecisions{record: record}
+	result, err := PlayStep(action, &gameState, r, decisions.decide, sources)
+	if err == nil {
+		err = decisions.check()
+	}
+	return gameState, result, err
+}
+
+// recordedDecisions answers the engine with the RNG response stored in the record.
+// A record holds the one decision its step made, so a replay that asks for another
+// one, or never asks for it, did not play the step that was recorded.
+type recordedDecisions struct {
+	record audit.Record
+	asked  int
+}
+
+func (d *recordedDecisions) decide(betAmount, totalWinnings, share float64) (*Outcome, error) {
+	d.asked++
+	if d.record.RNGRequest == nil || d.record.RNGResponse == nil {
+		return nil, ErrUnrecordedDecision
+	}
+	if d.asked > 1 {
+		return nil, fmt.Errorf("%w: decision %d of the step, only one was recorded", ErrUnrecordedDecision, d.asked)
+	}
+	return &Outcome{RTP: d.record.RTP, Request: *d.record.RNGRequest, Response: *d.record.RNGResponse}, nil
+}
+
+// check fails a replay that left the recorded decision unused
+func (d *recordedDecisions) check() error {
+	if d.record.RNGResponse != nil && d.asked == 0 {
+		return ErrUnusedDecision
+	}
+	return nil
+}
+
+// ReplayHandler handles the /rounds/{id}/replay endpoint. It re-executes every recorded
+// step of the round with its seed and RNG response and flags any divergence from what was paid.
+func (rg *RouteGroup) ReplayHandler(c *fiber.Ctx) error {
+	if rg.Audit == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Audit log is disabled",
+		})
+	}
+
+	roundID := c.Params("id")
+	records, err := rg.Audit.Round(roundID)
+	if err != nil {
+		log.Printf("Failed to read audit records of round %s: %v", roundID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to read audit log",
+		})
+	}
+	if len(records) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Round not found",
+		})
+	}
+
+	response := ReplayResponse{Status: "success", RoundID: roundID}
+	for _, record := range records {
+		step := rg.replayRecord(record)
+		response.Steps = append(response.Steps, step)
+		response.PaidWin = round(response.PaidWin + step.PaidWin)
+		response.ReplayedWin = round(response.ReplayedWin + step.Win)
+		if len(step.Divergences) > 0 {
+			response.Diverged = true
+		}
+	}
+	if response.Diverged {
+		log.Printf("Replay of round %s diverged: paid %.2f, replayed %.2f", roundID, response.PaidWin, response.ReplayedWin)
+	}
+	return c.JSON(response)
+}
+
+// replayRecord re-executes one audited step and compares it with the record
+func (rg *RouteGroup) replayRecord(record audit.Record) ReplayedStep {
+	step := ReplayedStep{
+		Step:      record.Step,
+		Action:    RoundAction(record.Action),
+		Seed:      record.Seed,
+		InputGrid: record.InputGrid,
+		PaidWin:   record.Win,
+	}
+
+	r, err := rg.seededSource(record.Seed, record.Step)
+	if err != nil {
+		step.Divergences = append(step.Divergences, err.Error())
+		return step
+	}
+	gameState, result, err := ReplayStep(record, r, rg.stepSources(record.Seed))
+	if err != nil {
+		step.Divergences = append(step.Divergences, err.Error())
+		return step
+	}
+
+	step.Frames = result.Frames
+	step.Removed = result.Removed
+	step.Moves = result.Moves
+	step.Filled = result.Filled
+	step.Connections = result.PayingConnections()
+	step.RNGBypassed = result.RNGBypassed
+	if result.Outcome != nil {
+		step.PrefOutcome = result.Outcome.Response.PrefOutcome
+	}
+	step.OutputGrid = gameState.Grid
+	step.Win = gameState.TotalWin
+
+	if !reflect.DeepEqual(gameState.Grid, record.OutputGrid) {
+		step.Divergences = append(step.Divergences, "replayed grid differs from the recorded grid")
+	}
+	if gameState.TotalWin != record.Win {
+		step.Divergences = append(step.Divergences, fmt.Sprintf("replayed win %.2f differs from paid win %.2f", gameState.TotalWin, record.Win))
+	}
+	if result.RNGBypassed != record.RNGBypassed {
+		step.Divergences = append(step.Divergences, "RNG bypass differs from the recorded step")
+	}
+	return step
+}
@@ -0,0 +1,192 @@
+package birdspartydeluxe
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+)
+
+// editedAudit changes the records read back from a store, as an edited audit log would
+type editedAudit struct {
+	audit.Store
+	edit func(records []audit.Record)
+}
+
+func (s editedAudit) Round(roundID string) ([]audit.Record, error) {
+	records, err := s.Store.Round(roundID)
+	if err == nil {
+		s.edit(records)
+	}
+	return records, err
+}
+
+// replayServer plays rounds into an audit log and replays them through the operator endpoint
+func replayServer(t *testing.T, flow SpinFlow) (*testServer, *audit.FileStore) {
+	t.Helper()
+	store, err := audit.NewFileStore(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	s := newTestServer(t, func(rg *RouteGroup) {
+		rg.SpinFlow = flow
+		rg.Audit = store
+		rg.AdminToken = "secret"
+	})
+	return s, store
+}
+
+func (s *testServer) replay(roundID string) ReplayResponse {
+	s.t.Helper()
+	status, data := s.do(http.MethodGet, "/rounds/"+roundID+"/replay", nil, http.Header{"Authorization": {"Bearer secret"}})
+	if status != http.StatusOK {
+		s.t.Fatalf("replay of %s = %d %s", roundID, status, data)
+	}
+	var reply ReplayResponse
+	if err := json.Unmarshal(data, &reply); err != nil {
+		s.t.Fatal(err)
+	}
+	return reply
+}
+
+func TestReplayReproducesRounds(t *testing.T) {
+	for _, flow := range []SpinFlow{SpinFlowRNGFirst, SpinFlowLegacy, SpinFlowCycle} {
+		t.Run(string(flow), func(t *testing.T) {
+			s, _ := replayServer(t, flow)
+			steps := 0
+			for n := 1; n <= 30; n++ {
+				last := s.playRound(testPlayer, betID(n), nil)
+				state := last["gameState"].(map[string]any)
+				reply := s.replay(state["roundId"].(string))
+				if reply.Diverged {
+					t.Fatalf("replay of %s diverged: %+v", betID(n), reply.Steps)
+				}
+				if reply.ReplayedWin != reply.PaidWin || reply.PaidWin != state["roundWin"] {
+					t.Errorf("%s: replayed %v, paid %v, round win %v", betID(n), reply.ReplayedWin, reply.PaidWin, state["roundWin"])
+				}
+				if len(reply.Steps) != int(state["step"].(float64)) {
+					t.Errorf("%s: %d replayed steps, want %v", betID(n), len(reply.Steps), state["step"])
+				}
+				steps += len(reply.Steps)
+			}
+			if steps <= 30 {
+				t.Errorf("only %d steps in 30 rounds; no round had a stage-cleared or cascade step", steps)
+			}
+		})
+	}
+}
+
+func TestReplayFlagsEditedRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(records []audit.Record)
+	}{
+		{"edited win", func(records []audit.Record) { records[0].Win += 10 }},
+		{"edited seed", func(records []audit.Record) {
+			records[0].Seed = "0000000000000000000000000000000000000000000000000000000000000000"
+		}},
+		{"edited output grid", func(records []audit.Record) {
+			grid := records[0].OutputGrid
+			grid[0][0], grid[len(grid)-1][len(grid)-1] = grid[len(grid)-1][len(grid)-1]+"x", grid[0][0]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := replayServer(t, SpinFlowRNGFirst)
+			s.rg.Audit = editedAudit{Store: store, edit: tt.edit}
+			last := s.playRound(testPlayer, betID(1), nil)
+			if reply := s.replay(last["gameState"].(map[string]any)["roundId"].(string)); !reply.Diverged {
+				t.Errorf("replay of an edited record did not diverge: %+v", reply.Steps[0])
+			}
+		})
+	}
+}
+
+func TestRecordedDecisions(t *testing.T) {
+	request, response := &rng.Request{BetID: "bet-1"}, &rng.Response{PrefOutcome: "win", WinAmount: 2}
+	tests := []struct {
+		name       string
+		recorded   bool
+		asks       int
+		wantAskErr error // Of the last ask
+		wantCheck  error
+	}{
+		{"no decision made or recorded", false, 0, nil, nil},
+		{"decision never recorded", false, 1, ErrUnrecordedDecision, nil},
+		{"recorded decision", true, 1, nil, nil},
+		{"second decision", true, 2, ErrUnrecordedDecision, nil},
+		{"recorded decision never asked", true, 0, nil, ErrUnusedDecision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := audit.Record{RTP: 0.96}
+			if tt.recorded {
+				record.RNGRequest, record.RNGResponse = request, response
+			}
+			decisions := &recordedDecisions{record: record}
+			var outcome *Outcome
+			var err error
+			for range tt.asks {
+				outcome, err = decisions.decide(1, 2, 1)
+			}
+			if !errors.Is(err, tt.wantAskErr) || (tt.wantAskErr == nil) != (err == nil) {
+				t.Errorf("decide error = %v, want %v", err, tt.wantAskErr)
+			}
+			if err == nil && tt.asks > 0 && (outcome.RTP != 0.96 || outcome.Response != *response || outcome.Request.BetID != "bet-1") {
+				t.Errorf("decide = %+v, want the recorded decision", outcome)
+			}
+			if err := decisions.check(); err != tt.wantCheck {
+				t.Errorf("check = %v, want %v", err, tt.wantCheck)
+			}
+		})
+	}
+}
+
+// A replay fails when the step it plays does not ask for the decisions that were recorded
+func TestReplayFlagsDecisionCount(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(records []audit.Record) int // Returns the edited step's index, -1 when the round has no step to edit
+		want error
+	}{
+		{"recorded decision removed", func(records []audit.Record) int {
+			records[0].RNGRequest, records[0].RNGResponse = nil, nil
+			return 0
+		}, ErrUnrecordedDecision},
+		{"decision added to a step that made none", func(records []audit.Record) int {
+			for i, record := range records {
+				if record.RNGResponse == nil {
+					records[i].RNGRequest, records[i].RNGResponse = records[0].RNGRequest, records[0].RNGResponse
+					return i
+				}
+			}
+			return -1
+		}, ErrUnusedDecision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := replayServer(t, SpinFlowRNGFirst)
+			edited := -1
+			s.rg.Audit = editedAudit{Store: store, edit: func(records []audit.Record) { edited = tt.edit(records) }}
+			for n := 1; n <= 100; n++ {
+				last := s.playRound(testPlayer, betID(n), nil)
+				state := last["gameState"].(map[string]any)
+				reply := s.replay(state["roundId"].(string))
+				if edited < 0 {
+					continue
+				}
+				if !reply.Diverged || !slices.Contains(reply.Steps[edited].Divergences, tt.want.Error()) {
+					t.Errorf("step %d of the replay = %+v, want a divergence %q", edited, reply.Steps[edited], tt.want)
+				}
+				return
+			}
+			t.Fatal("no round had a step to edit")
+		})
+	}
+}
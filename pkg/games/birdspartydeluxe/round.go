@@ -0,0 +1,104 @@
+package birdspartydeluxe
+
+import (
+	"fmt"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+)
+
+// RoundPhase is the position of the player's round in the spin → stage-cleared → cascade flow
+type RoundPhase string
+
+const (
+	PhaseIdle                 RoundPhase = "idle"                 // No round played yet
+	PhaseAwaitingStageCleared RoundPhase = "awaitingStageCleared" // Stage-cleared symbols must be processed next
+	PhaseCascading            RoundPhase = "cascading"            // Connections must be cascaded next
+	PhaseFreeSpinPending      RoundPhase = "freeSpinPending"      // Round finished, free spins remain for the next spin
+	PhaseCompleted            RoundPhase = "completed"            // Round finished, next spin is paid
+)
+
+// RoundAction is a client call that advances the round
+type RoundAction string
+
+const (
+	ActionSpin         RoundAction = "spin"
+	ActionStageCleared RoundAction = "processStageCleared"
+	ActionCascade      RoundAction = "cascade"
+)
+
+// allowedActions lists the actions each phase accepts
+var allowedActions = map[RoundPhase][]RoundAction{
+	PhaseIdle:                 {ActionSpin},
+	PhaseAwaitingStageCleared: {ActionStageCleared},
+	PhaseCascading:            {ActionCascade},
+	PhaseFreeSpinPending:      {ActionSpin},
+	PhaseCompleted:            {ActionSpin},
+}
+
+// TransitionError is returned when an action is not allowed in the round's current phase
+type TransitionError struct {
+	Phase  RoundPhase
+	Action RoundAction
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s is not allowed while the round is %s", e.Action, e.Phase)
+}
+
+// CheckTransition validates that the action may be taken in the phase
+func CheckTransition(phase RoundPhase, action RoundAction) error {
+	if phase == "" {
+		phase = PhaseIdle
+	}
+	for _, allowed := range allowedActions[phase] {
+		if allowed == action {
+			return nil
+		}
+	}
+	return &TransitionError{Phase: phase, Action: action}
+}
+
+// NextPhase returns the phase a round enters after a step.
+// Stage-cleared symbols take priority over connections, as they are removed first.
+func NextPhase(gameState *GameState, hasStageCleared bool) RoundPhase {
+	switch {
+	case hasStageCleared:
+		return PhaseAwaitingStageCleared
+	case gameState.Cascading:
+		return PhaseCascading
+	case gameState.GameMode == "freeSpins" && gameState.FreeSpins.Remaining > 0:
+		return PhaseFreeSpinPending
+	default:
+		return PhaseCompleted
+	}
+}
+
+// beginRound resets the round fields of the player's state for a new spin played with model
+// and the weight profile of the environment
+func beginRound(gameState *GameState, betID string, betAmount float64, gameSettings settings.GameSettings, spinFlow SpinFlow, model *MathModel, weightProfile string) {
+	// Initialize game state if needed
+	if gameState.CurrentLevel == 0 {
+		*gameState = InitializeGameState()
+	}
+	if gameState.GameMode == "" {
+		gameState.GameMode = "base"
+	}
+
+	gameState.Seed = ""
+	gameState.BetID = betID
+	gameState.Step = 1
+	gameState.Bet.Amount = betAmount
+
+	// Free spins are not charged
+	gameState.RoundCost = betAmount
+	if gameState.GameMode == "freeSpins" {
+		gameState.RoundCost = 0
+	}
+	gameState.RoundWin = 0
+	gameState.MaxWin = gameSettings.MaxWin
+	gameState.RTP = gameSettings.RTP
+	gameState.SpinFlow = spinFlow
+	// The settings RTP picks the model's profile for the whole round, the environment its weight profile
+	gameState.useMathModel(model.ForRTP(gameSettings.RTP).WithWeights(weightProfile))
+	depositBudget(gameState)
+}
@@ -0,0 +1,148 @@
+package birdspartydeluxe
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+var (
+	allPhases  = []RoundPhase{"", PhaseIdle, PhaseAwaitingStageCleared, PhaseCascading, PhaseFreeSpinPending, PhaseCompleted}
+	allActions = []RoundAction{ActionSpin, ActionStageCleared, ActionCascade}
+)
+
+func TestCheckTransition(t *testing.T) {
+	// The one action each phase accepts
+	allowed := map[RoundPhase]RoundAction{
+		"":                        ActionSpin, // A state saved before phases existed
+		PhaseIdle:                 ActionSpin,
+		PhaseAwaitingStageCleared: ActionStageCleared,
+		PhaseCascading:            ActionCascade,
+		PhaseFreeSpinPending:      ActionSpin,
+		PhaseCompleted:            ActionSpin,
+	}
+	for _, phase := range allPhases {
+		for _, action := range allActions {
+			err := CheckTransition(phase, action)
+			if action == allowed[phase] {
+				if err != nil {
+					t.Errorf("%s while %q: %v, want allowed", action, phase, err)
+				}
+				continue
+			}
+			var transitionErr *TransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Errorf("%s while %q = %v, want a TransitionError", action, phase, err)
+				continue
+			}
+			wantPhase := phase
+			if phase == "" {
+				wantPhase = PhaseIdle
+			}
+			if want := fmt.Sprintf("%s is not allowed while the round is %s", action, wantPhase); err.Error() != want {
+				t.Errorf("%s while %q = %q, want %q", action, phase, err, want)
+			}
+		}
+	}
+}
+
+func TestNextPhase(t *testing.T) {
+	tests := []struct {
+		name            string
+		gameMode        string
+		remaining       int
+		cascading       bool
+		hasStageCleared bool
+		want            RoundPhase
+	}{
+		{"spin with stage-cleared symbols", "base", 0, true, true, PhaseAwaitingStageCleared},
+		{"spin with connections", "base", 0, true, false, PhaseCascading},
+		{"stage cleared, connections left", "base", 0, true, false, PhaseCascading},
+		{"stage cleared, more stage-cleared symbols", "base", 0, false, true, PhaseAwaitingStageCleared},
+		{"cascade into stage-cleared symbols", "base", 0, true, true, PhaseAwaitingStageCleared},
+		{"cascade ended the base round", "base", 0, false, false, PhaseCompleted},
+		{"cascade ended a free spin, more left", "freeSpins", 4, false, false, PhaseFreeSpinPending},
+		{"cascade ended the last free spin", "freeSpins", 0, false, false, PhaseCompleted},
+		{"free spin still cascading", "freeSpins", 4, true, false, PhaseCascading},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := InitializeGameState()
+			gs.GameMode = tt.gameMode
+			gs.FreeSpins.Remaining = tt.remaining
+			gs.Cascading = tt.cascading
+			if got := NextPhase(&gs, tt.hasStageCleared); got != tt.want {
+				t.Errorf("NextPhase = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// playToPhase spins new rounds until one reaches phase, returning its bet
+func (s *testServer) playToPhase(phase RoundPhase) string {
+	s.t.Helper()
+	for n := 1; n <= 300; n++ {
+		body := stepBody(testPlayer, betID(n), nil)
+		body["bet_amount"] = 1.0
+		status, reply := s.post("/spin/birdspartydeluxe", body)
+		for status == http.StatusOK {
+			state := reply["gameState"].(map[string]any)
+			if RoundPhase(fmt.Sprint(state["phase"])) == phase {
+				return betID(n)
+			}
+			path := nextStep(reply)
+			if path == "" {
+				break
+			}
+			status, reply = s.post(path, stepBody(testPlayer, betID(n), nil))
+		}
+		if status != http.StatusOK {
+			s.t.Fatalf("round %d: %d %v", n, status, reply["message"])
+		}
+	}
+	s.t.Fatalf("no round reached %s", phase)
+	return ""
+}
+
+// Every step the round does not expect is refused with 409 and the round's phase, and leaves the round as it was
+func TestIllegalStepsAreRejectedThroughHandlers(t *testing.T) {
+	paths := map[RoundAction]string{
+		ActionSpin:         "/spin/birdspartydeluxe",
+		ActionStageCleared: "/process-stage-cleared/birdspartydeluxe",
+		ActionCascade:      "/cascade/birdspartydeluxe",
+	}
+	for _, phase := range []RoundPhase{PhaseIdle, PhaseAwaitingStageCleared, PhaseCascading, PhaseFreeSpinPending, PhaseCompleted} {
+		t.Run(string(phase), func(t *testing.T) {
+			s := newTestServer(t, nil)
+			bet := betID(1000)
+			if phase != PhaseIdle {
+				bet = s.playToPhase(phase)
+			}
+			for _, action := range allActions {
+				if CheckTransition(phase, action) == nil {
+					continue
+				}
+				body := stepBody(testPlayer, bet, nil)
+				if action == ActionSpin {
+					body["bet_id"], body["bet_amount"] = "illegal-spin", 1.0
+				}
+				status, reply := s.post(paths[action], body)
+				if phase == PhaseIdle {
+					// With no round saved there is no bet to match, which the step reports first
+					if status != http.StatusBadRequest || reply["message"] != "No active round for this bet" {
+						t.Errorf("%s = %d %v, want 400 for no active round", action, status, reply["message"])
+					}
+					continue
+				}
+				want := fmt.Sprintf("%s is not allowed while the round is %s", action, phase)
+				if status != http.StatusConflict || reply["message"] != want || reply["phase"] != string(phase) {
+					t.Errorf("%s = %d %v (phase %v), want 409 %q", action, status, reply["message"], reply["phase"], want)
+				}
+			}
+			if stored := s.storedState(testPlayer); phase != PhaseIdle && stored.Phase != phase {
+				t.Errorf("round is %s after the illegal steps, want %s", stored.Phase, phase)
+			}
+		})
+	}
+}
@@ -1,43 +1,155 @@
 package birdspartydeluxe
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/audit"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/fairness"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/idempotency"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
 	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
 	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/statetoken"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/wallet"
 	"github.com/gofiber/fiber/v2"
 )
 
 // RouteGroup holds the dependencies for the handlers
 type RouteGroup struct {
-	RNGProd      *rng.Client
+	RNGProd      rng.OutcomeProvider
 	SettingsProd *settings.Client
-	RNGTest      *rng.Client
+	RNGTest      rng.OutcomeProvider
 	SettingsTest *settings.Client
+	Sessions     session.Store
+
+	// Random creates the per-step random sources from each round's recorded seed
+	Random random.Factory
+
+	// SpinFlow decides how spins use the outcome provider
+	SpinFlow SpinFlow
+
+	// MathModel plays new rounds unless OperatorMathModels holds a model for the
+	// operator's client_id; nil plays the built-in model
+	MathModel          *MathModel
+	OperatorMathModels map[string]*MathModel
+
+	// Weight profiles of the math models per environment; CheckWeightProfiles validates them at startup
+	WeightProfileProd string
+	WeightProfileTest string
+
+	// Fairness, when set, switches to provably-fair mode: every step draws from the
+	// player's committed server seed, client seed and next nonce instead of Random
+	Fairness *fairness.Manager
+
+	// StateTokens switches the handlers to stateless mode: the client round-trips
+	// the GameState together with a signed token instead of the server storing it
+	StateTokens *statetoken.Signer
+
+	// Idempotency, when set, replays the stored response for a retried bet_id and step
+	Idempotency idempotency.Store
+
+	// Wallets debit bets and credit wins per environment; nil leaves money movement to the operator
+	WalletProd wallet.Wallet
+	WalletTest wallet.Wallet
+
+	// Audit, when set, receives an append-only record of every round step
+	Audit audit.Store
+
+	// AdminToken authenticates the operator endpoints registered by RegisterAdmin
+	AdminToken string
+
+	// Failure policies decide what a step does per environment when settings or RNG are unavailable;
+	// FallbackRNG decides outcomes under the degrade policy
+	FailurePolicyProd FailurePolicy
+	FailurePolicyTest FailurePolicy
+	FallbackRNG       rng.OutcomeProvider
+
+	playerLocks sync.Map // session key -> *sync.Mutex
 }
 
 // NewRouteGroup creates a new RouteGroup
-func NewRouteGroup(rngProd *rng.Client, settingsProd *settings.Client, rngTest *rng.Client, settingsTest *settings.Client) *RouteGroup {
+func NewRouteGroup(rngProd rng.OutcomeProvider, settingsProd *settings.Client, rngTest rng.OutcomeProvider, settingsTest *settings.Client, sessions session.Store) *RouteGroup {
 	return &RouteGroup{
 		RNGProd:      rngProd,
 		SettingsProd: settingsProd,
 		RNGTest:      rngTest,
 		SettingsTest: settingsTest,
+		Sessions:     sessions,
+		Random:       random.CryptoFactory{},
+		SpinFlow:     SpinFlowRNGFirst,
+		MathModel:    DefaultMathModel(),
+
+		WeightProfileProd: "production",
+		WeightProfileTest: "test",
+
+		FailurePolicyProd: FailClosed,
+		FailurePolicyTest: FailClosed,
+		FallbackRNG:       rng.NewLocalProvider(),
 	}
 }
 
+// mathModel returns the math model new rounds of the operator are played with
+func (rg *RouteGroup) mathModel(clientID string) *MathModel {
+	if model, ok := rg.OperatorMathModels[clientID]; ok {
+		return model
+	}
+	if rg.MathModel != nil {
+		return rg.MathModel
+	}
+	return DefaultMathModel()
+}
+
+// CheckWeightProfiles refuses weight profiles that a math model of new rounds does not define,
+// and a production weight profile marked test-only
+func (rg *RouteGroup) CheckWeightProfiles() error {
+	models := []*MathModel{rg.mathModel("")}
+	for _, model := range rg.OperatorMathModels {
+		models = append(models, model)
+	}
+	var problems []error
+	for _, model := range models {
+		if err := model.CheckWeightProfile(rg.WeightProfileProd, true); err != nil {
+			problems = append(problems, fmt.Errorf("production: %w", err))
+		}
+		if err := model.CheckWeightProfile(rg.WeightProfileTest, false); err != nil {
+			problems = append(problems, fmt.Errorf("test: %w", err))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// clientSet holds the external services and the weight profile of one environment
+type clientSet struct {
+	RNG           rng.OutcomeProvider
+	Settings      *settings.Client
+	Wallet        wallet.Wallet
+	Failure       FailurePolicy
+	Fallback      rng.OutcomeProvider
+	WeightProfile string
+}
+
 // Helper to select the correct clients per request
-func (rg *RouteGroup) getClientsForRequest(c *fiber.Ctx) (*rng.Client, *settings.Client) {
+func (rg *RouteGroup) getClientsForRequest(c *fiber.Ctx) clientSet {
 	origin := c.Get("Origin")
 	if len(origin) > 0 && (strings.Contains(strings.ToLower(origin), "test")) {
-		return rg.RNGTest, rg.SettingsTest
+		return clientSet{RNG: rg.RNGTest, Settings: rg.SettingsTest, Wallet: rg.WalletTest, Failure: rg.FailurePolicyTest, Fallback: rg.FallbackRNG, WeightProfile: rg.WeightProfileTest}
 	}
-	return rg.RNGProd, rg.SettingsProd
+	return clientSet{RNG: rg.RNGProd, Settings: rg.SettingsProd, Wallet: rg.WalletProd, Failure: rg.FailurePolicyProd, Fallback: rg.FallbackRNG, WeightProfile: rg.WeightProfileProd}
 }
 
 // Register registers the routes with the Fiber app
 func (rg *RouteGroup) Register(app *fiber.App) {
-	app.Post("/spin/birdspartydeluxe", rg.SpinHandler)
-	app.Post("/process-stage-cleared/birdspartydeluxe", rg.ProcessStageClearedHandler)
-	app.Post("/cascade/birdspartydeluxe", rg.CascadeHandler)
-}
\ No newline at end of file
+	app.Post("/spin/birdspartydeluxe", rg.idempotent(1), rg.SpinHandler)
+	app.Post("/process-stage-cleared/birdspartydeluxe", rg.idempotent(0), rg.ProcessStageClearedHandler)
+	app.Post("/cascade/birdspartydeluxe", rg.idempotent(0), rg.CascadeHandler)
+
+	if rg.Fairness != nil {
+		app.Post("/fairness/birdspartydeluxe/seed", rg.FairnessSeedHandler)
+		app.Post("/fairness/birdspartydeluxe/rotate", rg.FairnessRotateHandler)
+		app.Post("/fairness/birdspartydeluxe/reveal", rg.FairnessRevealHandler)
+	}
+}
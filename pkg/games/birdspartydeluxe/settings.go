@@ -0,0 +1,37 @@
+package birdspartydeluxe
+
+import (
+	"log"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+	"github.com/gofiber/fiber/v2"
+)
+
+// InvalidateSettingsHandler handles the /settings/birdspartydeluxe/invalidate endpoint.
+// Operators call it after changing settings; empty fields match every client, game or player.
+// It is an operator endpoint, registered by RegisterAdmin.
+func (rg *RouteGroup) InvalidateSettingsHandler(c *fiber.Ctx) error {
+	var req settings.Request
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	key := settings.Key{ClientID: req.ClientID, GameID: req.GameID, PlayerID: req.PlayerID}
+	invalidated := 0
+	for _, client := range []*settings.Client{rg.SettingsProd, rg.SettingsTest} {
+		if client != nil && client.Cache != nil {
+			invalidated += client.Cache.Invalidate(key)
+		}
+	}
+	log.Printf("Invalidated %d cached settings for client %q game %q player %q", invalidated, req.ClientID, req.GameID, req.PlayerID)
+
+	return c.JSON(fiber.Map{
+		"status":      "success",
+		"invalidated": invalidated,
+	})
+}
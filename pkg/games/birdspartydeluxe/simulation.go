@@ -0,0 +1,321 @@
+package birdspartydeluxe
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+)
+
+const (
+	// MaxSimulatedSteps stops a simulated round whose cascade chain never ends; the report counts such rounds
+	MaxSimulatedSteps = 1000
+	providerStep      = -1 // Step whose source backs a round's outcome provider, apart from the steps' own sources
+)
+
+// SimulationConfig describes a batch of simulated rounds
+type SimulationConfig struct {
+	Rounds    int
+	Sessions  int    // Players the rounds are split across, each keeping its level and free spins between rounds; 0 means 1
+	Workers   int    // Sessions played in parallel; 0 means one per CPU
+	Seed      string // When set, every round seed is derived from it, so the same configuration gives the same report
+	BetAmount float64
+	RTP       float64 // Settings RTP the decision budget is filled at
+	SpinFlow  SpinFlow
+	Model     *MathModel // Nil plays the built-in model
+	// WeightProfile is applied to the model like an environment's; a model without it plays its own weights
+	WeightProfile string
+	Random        random.Factory
+
+	// Provider returns the outcome provider of one round. r is a source of the round's own,
+	// so a provider drawing from it keeps seeded simulations reproducible.
+	Provider func(r random.Source) rng.OutcomeProvider
+
+	maxSteps int // Overrides maxSimulatedSteps in tests
+}
+
+// SimulationReport summarises simulated rounds. A round is one game cycle: a spin and the
+// stage-cleared and cascade steps that follow it. Free spins are rounds of their own.
+type SimulationReport struct {
+	Rounds        int
+	PaidRounds    int // Rounds that were not free spins
+	SpinFlow      SpinFlow
+	MathModel     string // ID of the model the rounds were played with
+	MathProfile   string // Profile of the model the settings RTP chose
+	WeightProfile string
+	TargetRTP     float64
+	Wagered       float64
+	SpinWin       float64 // Paid by spin steps
+	RoundWin      float64 // Paid by whole rounds, stage-cleared and cascade steps included
+	SpinRTP       float64 // Spin wins over the bet of every round, free spins included
+	RoundRTP      float64 // Round wins over the amount wagered: the game's RTP, free spins and cascades included
+	RTPStdErr     float64 // Standard error of RoundRTP, from how much the sessions' own RTPs spread
+	CycleRTP      float64 // Round wins over the bet of every round, free spins included
+	HitFrequency  float64 // Share of rounds that paid anything
+	StdDev        float64 // Standard deviation of a round's win, in bets
+	SpinHits      int     // Spins that paid anything
+	TargetMisses  int     // Spins that could not be steered within tolerance of the RNG's target
+	RNGBypasses   int     // Steps whose RNG decision could not be applied
+	Truncated     int     // Rounds stopped after MaxSimulatedSteps steps; only the steps played count towards their win
+	StepsPerRound float64
+
+	FreeSpinTriggers    int
+	FreeSpinTriggerRate float64 // Free spin triggers per paid round
+	LevelAdvances       int
+	LevelAdvanceRate    float64           // Level advances per round
+	BoomingReels        map[float64]int   // Rounds by the highest booming reels multiplier they reached
+	MaxWin              map[Level]float64 // Largest round win by the level the round started on
+
+	roundWins   int
+	steps       int
+	sumMultiple float64 // Of round wins in bets, for StdDev
+	sumSquares  float64
+	sessions    []sessionReturn // For RTPStdErr; sessions are independent, unlike the rounds of a session
+}
+
+// sessionReturn is what one session wagered and won
+type sessionReturn struct {
+	wagered, won float64
+}
+
+// Simulate plays rounds through the same engine as the handlers, asking the provider for
+// every decision, so the RTP the game delivers can be compared with the settings RTP.
+// Sessions are played in parallel and merged in order, so the report does not depend on Workers.
+func Simulate(ctx context.Context, config SimulationConfig) (SimulationReport, error) {
+	sessions := max(1, config.Sessions)
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	reports := make([]SimulationReport, sessions)
+	errs := make([]error, sessions)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, sessions); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for session := range next {
+				rounds := config.Rounds / sessions
+				if session < config.Rounds%sessions {
+					rounds++
+				}
+				reports[session], errs[session] = simulateSession(ctx, config, session, rounds)
+			}
+		}()
+	}
+	for session := 0; session < sessions; session++ {
+		next <- session
+	}
+	close(next)
+	wg.Wait()
+
+	report := newSimulationReport(config)
+	for session := range reports {
+		if errs[session] != nil {
+			return report, errs[session]
+		}
+		report.merge(reports[session])
+	}
+	report.finish(config.BetAmount)
+	return report, nil
+}
+
+// simulateSession plays one player's rounds in order
+func simulateSession(ctx context.Context, config SimulationConfig, session, rounds int) (SimulationReport, error) {
+	report := newSimulationReport(config)
+	model := config.modelOrDefault()
+	gameState := InitializeGameState()
+	for i := 0; i < rounds; i++ {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		betID := fmt.Sprintf("sim-%d-%d", session, i)
+		beginRound(&gameState, betID, config.BetAmount, settings.GameSettings{RTP: config.RTP}, config.SpinFlow, model, config.WeightProfile)
+		seed := config.Random.DeriveSeed(fmt.Sprintf("%s/%d/%d", config.Seed, session, i))
+		if config.Seed == "" {
+			var err error
+			if seed, err = config.Random.NewSeed(); err != nil {
+				return report, err
+			}
+		}
+		gameState.Seed = seed
+		report.Wagered += gameState.RoundCost
+		if gameState.RoundCost > 0 {
+			report.PaidRounds++
+		}
+		startLevel := gameState.CurrentLevel
+
+		providerSource, err := config.Random.New(seed, providerStep)
+		if err != nil {
+			return report, err
+		}
+		provider := config.Provider(providerSource)
+		decide := func(betAmount, totalWinnings, share float64) (*Outcome, error) {
+			req := rng.NewRequest("simulation", "birdspartydeluxe", "simulation", betID, config.RTP*share, totalWinnings/betAmount, betAmount, "", "", false)
+			resp, err := provider.Send(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return &Outcome{RTP: config.RTP, Request: req, Response: resp}, nil
+		}
+		sources := func(step int) (random.Source, error) {
+			return config.Random.New(seed, step)
+		}
+
+		action := ActionSpin
+		peakMultiplier := 0.0
+		for {
+			r, err := config.Random.New(seed, gameState.Step)
+			if err != nil {
+				return report, err
+			}
+			modeBefore := gameState.GameMode
+			result, err := PlayStep(action, &gameState, r, decide, sources)
+			if err != nil {
+				return report, err
+			}
+			report.steps++
+			// Read after every step, as the step that ends a cascade chain resets the booming reels
+			peakMultiplier = math.Max(peakMultiplier, gameState.FreeSpins.CurrentMultiplier)
+
+			gameState.RoundWin = round(gameState.RoundWin + gameState.TotalWin)
+			if action == ActionSpin {
+				report.SpinWin += gameState.TotalWin
+				if gameState.TotalWin > 0 {
+					report.SpinHits++
+				}
+				if result.TargetWin > 0 && !result.TargetMet {
+					report.TargetMisses++
+				}
+			}
+			if result.RNGBypassed {
+				report.RNGBypasses++
+			}
+			if result.LevelAdvanced {
+				report.LevelAdvances++
+			}
+			if modeBefore == "base" && gameState.GameMode == "freeSpins" {
+				report.FreeSpinTriggers++
+			}
+
+			gameState.Phase = NextPhase(&gameState, result.HasStageCleared)
+			if roundEnded(gameState.Phase) {
+				break
+			}
+			if gameState.Step >= config.stepLimit() {
+				report.Truncated++
+				break
+			}
+			if gameState.Phase == PhaseAwaitingStageCleared {
+				action = ActionStageCleared
+			} else {
+				action = ActionCascade
+			}
+			gameState.Step++
+		}
+
+		report.Rounds++
+		report.RoundWin += gameState.RoundWin
+		if gameState.RoundWin > 0 {
+			report.roundWins++
+		}
+		multiple := gameState.RoundWin / config.BetAmount
+		report.sumMultiple += multiple
+		report.sumSquares += multiple * multiple
+		report.BoomingReels[peakMultiplier]++
+		report.MaxWin[startLevel] = math.Max(report.MaxWin[startLevel], gameState.RoundWin)
+	}
+	return report, nil
+}
+
+// stepLimit returns the last step a simulated round may play
+func (config SimulationConfig) stepLimit() int {
+	if config.maxSteps > 0 {
+		return config.maxSteps
+	}
+	return MaxSimulatedSteps
+}
+
+// modelOrDefault returns the math model the simulation plays
+func (config SimulationConfig) modelOrDefault() *MathModel {
+	if config.Model != nil {
+		return config.Model
+	}
+	return DefaultMathModel()
+}
+
+func newSimulationReport(config SimulationConfig) SimulationReport {
+	model := config.modelOrDefault().ForRTP(config.RTP).WithWeights(config.WeightProfile)
+	return SimulationReport{
+		SpinFlow:      config.SpinFlow,
+		MathModel:     model.ID(),
+		MathProfile:   model.Profile(),
+		WeightProfile: model.WeightProfile(),
+		TargetRTP:     config.RTP,
+		BoomingReels:  make(map[float64]int),
+		MaxWin:        make(map[Level]float64),
+	}
+}
+
+// merge adds the counts of one session
+func (sr *SimulationReport) merge(other SimulationReport) {
+	sr.Rounds += other.Rounds
+	sr.PaidRounds += other.PaidRounds
+	sr.Wagered += other.Wagered
+	sr.SpinWin += other.SpinWin
+	sr.RoundWin += other.RoundWin
+	sr.SpinHits += other.SpinHits
+	sr.TargetMisses += other.TargetMisses
+	sr.RNGBypasses += other.RNGBypasses
+	sr.Truncated += other.Truncated
+	sr.FreeSpinTriggers += other.FreeSpinTriggers
+	sr.LevelAdvances += other.LevelAdvances
+	sr.roundWins += other.roundWins
+	sr.steps += other.steps
+	sr.sumMultiple += other.sumMultiple
+	sr.sumSquares += other.sumSquares
+	sr.sessions = append(sr.sessions, sessionReturn{wagered: other.Wagered, won: other.RoundWin})
+	for multiplier, count := range other.BoomingReels {
+		sr.BoomingReels[multiplier] += count
+	}
+	for level, win := range other.MaxWin {
+		sr.MaxWin[level] = math.Max(sr.MaxWin[level], win)
+	}
+}
+
+// finish computes the rates once every session is merged
+func (sr *SimulationReport) finish(betAmount float64) {
+	if sr.Rounds > 0 {
+		rounds := float64(sr.Rounds)
+		sr.SpinRTP = sr.SpinWin / (rounds * betAmount)
+		sr.CycleRTP = sr.RoundWin / (rounds * betAmount)
+		sr.HitFrequency = float64(sr.roundWins) / rounds
+		sr.StepsPerRound = float64(sr.steps) / rounds
+		sr.LevelAdvanceRate = float64(sr.LevelAdvances) / rounds
+		mean := sr.sumMultiple / rounds
+		sr.StdDev = math.Sqrt(math.Max(0, sr.sumSquares/rounds-mean*mean))
+	}
+	if sr.Wagered > 0 {
+		sr.RoundRTP = sr.RoundWin / sr.Wagered
+	}
+	if n := float64(len(sr.sessions)); n > 1 && sr.Wagered > 0 {
+		// The ratio estimator's variance: each session's win against what the RTP predicts for its wager
+		squares := 0.0
+		for _, session := range sr.sessions {
+			residual := session.won - sr.RoundRTP*session.wagered
+			squares += residual * residual
+		}
+		sr.RTPStdErr = math.Sqrt(squares*n/(n-1)) / sr.Wagered
+	}
+	if sr.PaidRounds > 0 {
+		sr.FreeSpinTriggerRate = float64(sr.FreeSpinTriggers) / float64(sr.PaidRounds)
+	}
+}
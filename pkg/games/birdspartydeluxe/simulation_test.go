@@ -0,0 +1,81 @@
+package birdspartydeluxe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+)
+
+// naturalProvider lets every payout stand
+type naturalProvider struct{}
+
+func (naturalProvider) Send(ctx context.Context, req rng.Request) (rng.Response, error) {
+	return rng.Response{PrefOutcome: "win", WinAmount: req.PayoutMultiplier * req.BetAmount, WinProb: 1}, nil
+}
+
+func TestSimulationRecordsPeakBoomingReels(t *testing.T) {
+	report, err := Simulate(context.Background(), SimulationConfig{
+		Rounds:        3000,
+		Sessions:      10,
+		Seed:          "booming-reels",
+		BetAmount:     BaseBetAmount,
+		RTP:           0.96,
+		SpinFlow:      SpinFlowLegacy,
+		WeightProfile: "production",
+		Random:        random.CryptoFactory{},
+		Provider:      func(random.Source) rng.OutcomeProvider { return naturalProvider{} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rounds, upgraded := 0, 0
+	for multiplier, count := range report.BoomingReels {
+		rounds += count
+		if multiplier > 1 {
+			upgraded += count
+		}
+	}
+	if rounds != report.Rounds {
+		t.Errorf("booming reels count %d rounds, want %d", rounds, report.Rounds)
+	}
+	// Every round ends with its booming reels reset, so only the peak shows the clover connections
+	if share := float64(upgraded) / float64(report.Rounds); share < 0.1 {
+		t.Errorf("%.4f of rounds reached a booming reels multiplier above 1x, want at least 0.1", share)
+	}
+}
+
+func TestSimulationCountsTruncatedRounds(t *testing.T) {
+	config := SimulationConfig{
+		Rounds:        500,
+		Seed:          "truncated",
+		BetAmount:     BaseBetAmount,
+		RTP:           0.96,
+		SpinFlow:      SpinFlowLegacy,
+		WeightProfile: "production",
+		Random:        random.CryptoFactory{},
+		Provider:      func(random.Source) rng.OutcomeProvider { return naturalProvider{} },
+	}
+	full, err := Simulate(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Truncated != 0 {
+		t.Errorf("%d rounds truncated at %d steps", full.Truncated, MaxSimulatedSteps)
+	}
+
+	// Every natural spin wins, so each round cascades past a limit of one step
+	config.maxSteps = 1
+	truncated, err := Simulate(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated.Truncated == 0 || truncated.Truncated > truncated.Rounds {
+		t.Errorf("%d of %d rounds truncated at one step", truncated.Truncated, truncated.Rounds)
+	}
+	if truncated.StepsPerRound > 2 {
+		t.Errorf("%.2f steps per round with a limit of one step", truncated.StepsPerRound)
+	}
+}
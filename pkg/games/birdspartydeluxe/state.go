@@ -0,0 +1,249 @@
+package birdspartydeluxe
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/statetoken"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNoActiveRound is returned when a round step is requested but the player has no round in progress
+	ErrNoActiveRound = errors.New("no active round for player")
+	// ErrStateRejected is returned in stateless mode when the client-held state does not match its signed token
+	ErrStateRejected = errors.New("game state rejected")
+	// ErrStateUnknown is returned in stateless mode when the session store holds no record of the player's
+	// tokens: the token was issued by an instance that does not share the store, or before the store was lost
+	ErrStateUnknown = errors.New("state token unknown to the session store")
+)
+
+// storedGameState is the server-side form of a game state. It keeps the round seed and
+// the player's budget, which the client form leaves out.
+type storedGameState struct {
+	GameState
+	Seed   string  `json:"seed"`
+	Budget float64 `json:"budget"`
+}
+
+// tokenRecord is what the server keeps for the player's latest state token: its nonce,
+// the seed of the round the client-held state belongs to and the player's budget
+type tokenRecord struct {
+	Nonce  string  `json:"nonce"`
+	Seed   string  `json:"seed"`
+	Budget float64 `json:"budget"`
+}
+
+// roundRef identifies the player and round a request belongs to.
+// GameState and StateToken are only used in stateless mode.
+type roundRef struct {
+	ClientID   string
+	GameID     string
+	PlayerID   string
+	BetID      string
+	GameState  *GameState
+	StateToken string
+}
+
+func (req SpinRequest) ref() roundRef {
+	return roundRef{req.ClientID, req.GameID, req.PlayerID, req.BetID, req.GameState, req.StateToken}
+}
+
+func (req ProcessStageClearedRequest) ref() roundRef {
+	return roundRef{req.ClientID, req.GameID, req.PlayerID, req.BetID, req.GameState, req.StateToken}
+}
+
+func (req CascadeRequest) ref() roundRef {
+	return roundRef{req.ClientID, req.GameID, req.PlayerID, req.BetID, req.GameState, req.StateToken}
+}
+
+// stateless reports whether game state round-trips through the client with signed tokens
+func (rg *RouteGroup) stateless() bool {
+	return rg.StateTokens != nil
+}
+
+// lockPlayer serializes requests for one player so concurrent calls cannot interleave load and save
+func (rg *RouteGroup) lockPlayer(clientID, playerID string) func() {
+	m, _ := rg.playerLocks.LoadOrStore(session.Key(clientID, playerID), &sync.Mutex{})
+	mu := m.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// loadGameState returns the current game state for the player.
+// A player without a stored session (or, in stateless mode, without a token) starts from a fresh game state.
+func (rg *RouteGroup) loadGameState(ref roundRef) (GameState, error) {
+	if rg.stateless() {
+		if ref.StateToken == "" {
+			return InitializeGameState(), nil
+		}
+		claims, gameState, err := rg.verifyClientState(ref)
+		if err != nil {
+			return GameState{}, err
+		}
+		// A spin always starts a new round, so it may not reuse the previous round's bet_id
+		if claims.BetID == ref.BetID {
+			return GameState{}, ErrStateRejected
+		}
+		return gameState, nil
+	}
+
+	data, err := rg.Sessions.Load(session.Key(ref.ClientID, ref.PlayerID))
+	if errors.Is(err, session.ErrNotFound) {
+		return InitializeGameState(), nil
+	}
+	if err != nil {
+		return GameState{}, err
+	}
+
+	var stored storedGameState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return GameState{}, err
+	}
+	gameState := stored.GameState
+	gameState.Seed = stored.Seed
+	gameState.Budget = stored.Budget
+	return gameState, nil
+}
+
+// loadActiveRound returns the game state for a round step (stage-cleared or cascade).
+// The step must belong to the round started by the request's bet_id.
+func (rg *RouteGroup) loadActiveRound(ref roundRef) (GameState, error) {
+	if rg.stateless() {
+		if ref.StateToken == "" {
+			return GameState{}, ErrNoActiveRound
+		}
+		claims, gameState, err := rg.verifyClientState(ref)
+		if err != nil {
+			return GameState{}, err
+		}
+		if claims.BetID != ref.BetID || gameState.BetID != ref.BetID {
+			return GameState{}, ErrStateRejected
+		}
+		if err := gameState.bindMathModel(); err != nil {
+			return GameState{}, err
+		}
+		return gameState, nil
+	}
+
+	gameState, err := rg.loadGameState(ref)
+	if err != nil {
+		return GameState{}, err
+	}
+	if gameState.BetID == "" || gameState.BetID != ref.BetID || len(gameState.Grid) == 0 {
+		return GameState{}, ErrNoActiveRound
+	}
+	// The round goes on with the math it started with
+	if err := gameState.bindMathModel(); err != nil {
+		return GameState{}, err
+	}
+	return gameState, nil
+}
+
+// tokenRecordKey is where the tokenRecord of the player's latest state token is kept. The extra
+// separator keeps it apart from session keys, which have exactly one.
+func tokenRecordKey(ref roundRef) string {
+	return "statetoken/" + session.Key(ref.ClientID, ref.PlayerID)
+}
+
+// verifyClientState checks that the state posted by the client is exactly the state the server
+// signed, in the player's latest token: an older or already used token is rejected
+func (rg *RouteGroup) verifyClientState(ref roundRef) (statetoken.Claims, GameState, error) {
+	if ref.GameState == nil {
+		return statetoken.Claims{}, GameState{}, ErrStateRejected
+	}
+	claims, err := rg.StateTokens.Verify(ref.StateToken)
+	if err != nil {
+		return statetoken.Claims{}, GameState{}, errors.Join(ErrStateRejected, err)
+	}
+
+	data, err := json.Marshal(ref.GameState)
+	if err != nil {
+		return statetoken.Claims{}, GameState{}, err
+	}
+	if claims.ClientID != ref.ClientID ||
+		claims.PlayerID != ref.PlayerID ||
+		claims.Step != ref.GameState.Step ||
+		claims.BetID != ref.GameState.BetID ||
+		claims.StateHash != statetoken.HashState(data) {
+		return statetoken.Claims{}, GameState{}, ErrStateRejected
+	}
+
+	data, err = rg.Sessions.Load(tokenRecordKey(ref))
+	if errors.Is(err, session.ErrNotFound) {
+		return statetoken.Claims{}, GameState{}, errors.Join(ErrStateRejected, ErrStateUnknown)
+	}
+	if err != nil {
+		return statetoken.Claims{}, GameState{}, err
+	}
+	var record tokenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return statetoken.Claims{}, GameState{}, err
+	}
+	if claims.Nonce == "" || claims.Nonce != record.Nonce {
+		return statetoken.Claims{}, GameState{}, ErrStateRejected
+	}
+	gameState := *ref.GameState
+	gameState.Seed = record.Seed
+	gameState.Budget = record.Budget
+	return claims, gameState, nil
+}
+
+// saveGameState persists the game state for the player.
+// In stateless mode only the nonce of the returned token, the round seed and the budget are stored; the token
+// must be sent back with the next request, and it replaces every token issued to the player before it.
+func (rg *RouteGroup) saveGameState(ref roundRef, gameState GameState) (string, error) {
+	if rg.stateless() {
+		data, err := json.Marshal(gameState)
+		if err != nil {
+			return "", err
+		}
+		nonce := uuid.New().String()
+		record, err := json.Marshal(tokenRecord{Nonce: nonce, Seed: gameState.Seed, Budget: gameState.Budget})
+		if err != nil {
+			return "", err
+		}
+		if err := rg.Sessions.Save(tokenRecordKey(ref), record); err != nil {
+			return "", err
+		}
+		return rg.StateTokens.Sign(statetoken.Claims{
+			ClientID:  ref.ClientID,
+			PlayerID:  ref.PlayerID,
+			BetID:     gameState.BetID,
+			Step:      gameState.Step,
+			StateHash: statetoken.HashState(data),
+			Nonce:     nonce,
+		})
+	}
+
+	data, err := json.Marshal(storedGameState{GameState: gameState, Seed: gameState.Seed, Budget: gameState.Budget})
+	if err != nil {
+		return "", err
+	}
+	return "", rg.Sessions.Save(session.Key(ref.ClientID, ref.PlayerID), data)
+}
+
+// cloneGameState returns a deep copy of the game state, so later in-place grid edits do not affect it
+func cloneGameState(gameState GameState) GameState {
+	clone := gameState
+	clone.Grid = copyGrid(gameState.Grid)
+	clone.LastConnections = make([]Connection, len(gameState.LastConnections))
+	for i, connection := range gameState.LastConnections {
+		clone.LastConnections[i] = connection
+		clone.LastConnections[i].Positions = append([]Position(nil), connection.Positions...)
+	}
+	clone.StageClearedSymbols = append([]StageClearedSymbol{}, gameState.StageClearedSymbols...)
+	return clone
+}
+
+// copyGrid returns a deep copy of a grid
+func copyGrid(grid [][]string) [][]string {
+	clone := make([][]string, len(grid))
+	for i := range grid {
+		clone[i] = make([]string, len(grid[i]))
+		copy(clone[i], grid[i])
+	}
+	return clone
+}
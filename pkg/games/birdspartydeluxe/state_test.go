@@ -0,0 +1,132 @@
+package birdspartydeluxe
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/session"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/statetoken"
+)
+
+func newStatelessServer(t *testing.T) *testServer {
+	t.Helper()
+	return newStatelessInstance(t, session.NewMemoryStore())
+}
+
+// newStatelessInstance is one instance of a stateless deployment that keeps its token records in store
+func newStatelessInstance(t *testing.T, store session.Store) *testServer {
+	t.Helper()
+	return newTestServer(t, func(rg *RouteGroup) {
+		rg.Sessions = store
+		signer, err := statetoken.NewSigner([]statetoken.Key{{ID: "k1", Secret: []byte("secret")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer.TTL = time.Hour
+		rg.StateTokens = signer
+	})
+}
+
+// carry returns the state and token of a reply, to send with the next request
+func carry(reply map[string]any) map[string]any {
+	return map[string]any{"gameState": reply["gameState"], "stateToken": reply["stateToken"]}
+}
+
+func TestStateTokenCannotBeReplayed(t *testing.T) {
+	s := newStatelessServer(t)
+	first := s.playRound(testPlayer, betID(1), nil)
+	second := s.playRound(testPlayer, betID(2), carry(first))
+
+	tests := []struct {
+		name   string
+		player player
+		state  map[string]any
+		want   int
+	}{
+		{"already used token", testPlayer, carry(first), http.StatusForbidden},
+		{"token of another client", player{ClientID: "c2", GameID: "birdspartydeluxe", PlayerID: "p1"}, carry(second), http.StatusForbidden},
+		{"token of another player", player{ClientID: "c1", GameID: "birdspartydeluxe", PlayerID: "p2"}, carry(second), http.StatusForbidden},
+		{"latest token", testPlayer, carry(second), http.StatusOK},
+		{"latest token, used", testPlayer, carry(second), http.StatusForbidden},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := stepBody(tt.player, betID(10+i), tt.state)
+			body["bet_amount"] = 1.0
+			if status, reply := s.post("/spin/birdspartydeluxe", body); status != tt.want {
+				t.Errorf("spin = %d %v, want %d", status, reply["message"], tt.want)
+			}
+		})
+	}
+}
+
+func TestStateTokenOfEarlierStepIsStale(t *testing.T) {
+	s := newStatelessServer(t)
+	// Spin until a round has a step after its spin
+	var spin map[string]any
+	for n := 1; spin == nil || nextStep(spin) == ""; n++ {
+		if n > 200 {
+			t.Fatal("no round with more than one step")
+		}
+		body := stepBody(testPlayer, betID(n), nil)
+		if spin != nil {
+			body = stepBody(testPlayer, betID(n), carry(spin))
+		}
+		body["bet_amount"] = 1.0
+		var status int
+		if status, spin = s.post("/spin/birdspartydeluxe", body); status != http.StatusOK {
+			t.Fatalf("spin: %d %v", status, spin["message"])
+		}
+	}
+
+	path := nextStep(spin)
+	bet := spin["gameState"].(map[string]any)["betId"].(string)
+	if status, step := s.post(path, stepBody(testPlayer, bet, carry(spin))); status != http.StatusOK {
+		t.Fatalf("%s: %d %v", path, status, step["message"])
+	}
+	// The step used the spin's token; re-rolling the step with it must fail
+	if status, reply := s.post(path, stepBody(testPlayer, bet, carry(spin))); status != http.StatusForbidden {
+		t.Errorf("step replayed with a used token = %d %v, want 403", status, reply["message"])
+	}
+}
+
+// Behind a load balancer a player's requests land on any instance: each must find the record of the
+// token another instance issued, so the instances share the session store
+func TestStateTokenAcrossInstances(t *testing.T) {
+	shared := session.NewMemoryStore()
+	tests := []struct {
+		name        string
+		storeB      session.Store
+		replayOld   bool
+		want        int
+		wantMessage string
+	}{
+		{"shared store", shared, false, http.StatusOK, ""},
+		{"shared store, earlier token", shared, true, http.StatusForbidden, "Game state signature verification failed"},
+		{"no shared store", session.NewMemoryStore(), false, http.StatusForbidden, "State token unknown to this server; every instance must share the session store"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := player{ClientID: "c1", GameID: "birdspartydeluxe", PlayerID: fmt.Sprintf("lb%d", i)}
+			a, b := newStatelessInstance(t, shared), newStatelessInstance(t, tt.storeB)
+			first := a.playRound(p, betID(1), nil)
+			second := a.playRound(p, betID(2), carry(first))
+
+			state := carry(second)
+			if tt.replayOld {
+				state = carry(first)
+			}
+			body := stepBody(p, betID(3), state)
+			body["bet_amount"] = 1.0
+			status, reply := b.post("/spin/birdspartydeluxe", body)
+			if status != tt.want {
+				t.Fatalf("spin on the other instance = %d %v, want %d", status, reply["message"], tt.want)
+			}
+			if tt.wantMessage != "" && reply["message"] != tt.wantMessage {
+				t.Errorf("message = %q, want %q", reply["message"], tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package birdspartydeluxe
+
+import (
+	"log"
+	"math"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+)
+
+// TargetWinTolerance is how far a generated payout may be from the RNG's target win, as a share of the target
+const TargetWinTolerance = 0.1
+
+// Search budget of the outcome-driven generators
+const (
+	targetSamples   = 100 // Fresh grids drawn before refining the closest one
+	targetClusters  = 20  // Single-cluster grids built when sampling falls short
+	targetMutations = 400 // Single-cell changes tried while refining
+)
+
+// clusterSymbols are the birds a target cluster may be built from, in a fixed order so replays pick the same one
+var clusterSymbols = []Symbol{SymbolPurpleOwl, SymbolGreenOwl, SymbolYellowOwl, SymbolBlueOwl, SymbolRedOwl}
+
+// targetWin returns the payout the RNG service asked for when the step's natural
+// payout is not already within tolerance of it. Responses without a win amount
+// leave the natural payout standing.
+func targetWin(gs *GameState, resp rng.Response, natural float64) (float64, bool) {
+	if resp.PrefOutcome == "loss" || resp.WinAmount <= 0 {
+		return 0, false
+	}
+	target := capWin(gs, round(resp.WinAmount))
+	if withinTarget(natural, target) {
+		return 0, false
+	}
+	return target, true
+}
+
+// withinTarget reports whether payout is within tolerance of target, never closer than one denomination
+func withinTarget(payout, target float64) bool {
+	return math.Abs(payout-target) <= targetSlack(target)
+}
+
+func targetSlack(target float64) float64 {
+	return math.Max(0.01, target*TargetWinTolerance)
+}
+
+// payConnections pays connections on gs the way every step does: each clover connection
+// upgrades the booming reels and pays its base value, then birds pay at the resulting multiplier
+func payConnections(gs *GameState, connections []Connection) ([]Connection, []Connection, float64) {
+	cloverConnections, birdConnections := SeparateConnections(connections)
+	totalWinnings := 0.0
+	for i, connection := range cloverConnections {
+		UpgradeBoomingReels(gs)
+		cloverConnections[i].Payout = calculatePayout(connection.Symbol, connection.Count, gs.level(), gs.Bet.Multiplier)
+		totalWinnings += cloverConnections[i].Payout
+	}
+	for i, connection := range birdConnections {
+		birdConnections[i].Payout = calculatePayout(connection.Symbol, connection.Count, gs.level(), gs.Bet.Multiplier) * gs.FreeSpins.CurrentMultiplier
+		totalWinnings += birdConnections[i].Payout
+	}
+	return cloverConnections, birdConnections, round(totalWinnings)
+}
+
+// gridPayout returns what grid would pay on gs, leaving gs untouched
+func gridPayout(gs *GameState, grid [][]string) float64 {
+	scratch := *gs
+	_, _, total := payConnections(&scratch, FindAllConnections(grid, gs.level()))
+	return total
+}
+
+// GenerateGridForTarget builds a spin grid whose connections pay within tolerance of target.
+// It samples fresh grids, then refines the closest one cell by cell. When the target cannot
+// be reached it returns the closest grid that does not pay more than the target allows,
+// or a losing grid, and reports false.
+func GenerateGridForTarget(gs *GameState, r random.Source, target float64) ([][]string, bool) {
+	upper := target + targetSlack(target)
+
+	var closest, under [][]string
+	closestDiff, underDiff := math.Inf(1), math.Inf(1)
+	consider := func(grid [][]string, payout float64) bool {
+		diff := math.Abs(payout - target)
+		if diff < closestDiff {
+			closest, closestDiff = grid, diff
+		}
+		if payout <= upper && diff < underDiff {
+			under, underDiff = grid, diff
+		}
+		return withinTarget(payout, target)
+	}
+
+	for sample := 0; sample < targetSamples; sample++ {
+		// Alternate between win-seeded and plain grids to cover small and large targets
+		var grid [][]string
+		if sample%2 == 0 {
+			grid = GenerateGridWithWin(gs.level(), r, gs.GameMode)
+		} else {
+			grid = GenerateGrid(gs.level(), r, gs.GameMode)
+		}
+		if consider(grid, gridPayout(gs, grid)) {
+			log.Printf("Target win %.2f reached by grid sample %d", target, sample+1)
+			return grid, true
+		}
+	}
+
+	// Large targets are rarely drawn by chance; build them from the paytable instead
+	for attempt := 0; attempt < targetClusters; attempt++ {
+		grid, ok := clusterGrid(gs, r, target)
+		if !ok {
+			break
+		}
+		if consider(grid, gridPayout(gs, grid)) {
+			log.Printf("Target win %.2f reached by cluster grid %d", target, attempt+1)
+			return grid, true
+		}
+	}
+
+	if grid, ok := refineToTarget(gs, closest, allPositions(len(closest)), r, target, consider); ok {
+		return grid, true
+	}
+
+	if under != nil {
+		log.Printf("Target win %.2f not reached, paying closest %.2f", target, gridPayout(gs, under))
+		return under, false
+	}
+	log.Printf("Target win %.2f not reached without overpaying, generating a loss grid", target)
+	return GenerateLossGrid(gs.level(), r, gs.GameMode), false
+}
+
+// clusterGrid builds a losing grid holding one connected cluster of the bird and size whose
+// paytable value is closest to target without paying more than the target allows
+func clusterGrid(gs *GameState, r random.Source, target float64) ([][]string, bool) {
+	symbol, count, ok := closestCluster(gs, target)
+	if !ok {
+		return nil, false
+	}
+	grid := GenerateLossGrid(gs.level(), r, gs.GameMode)
+	size := len(grid)
+
+	// Grow the cluster from a random cell through random orthogonal neighbours
+	offsets := [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	start := Position{X: r.Intn(size), Y: r.Intn(size)}
+	cluster := []Position{start}
+	inCluster := map[Position]bool{start: true}
+	for len(cluster) < count {
+		from := cluster[r.Intn(len(cluster))]
+		offset := offsets[r.Intn(len(offsets))]
+		next := Position{X: from.X + offset[0], Y: from.Y + offset[1]}
+		if next.X < 0 || next.X >= size || next.Y < 0 || next.Y >= size || inCluster[next] {
+			continue
+		}
+		inCluster[next] = true
+		cluster = append(cluster, next)
+	}
+	for _, pos := range cluster {
+		grid[pos.Y][pos.X] = string(symbol)
+	}
+	return grid, true
+}
+
+// closestCluster returns the bird and connection size that pay closest to target on gs
+func closestCluster(gs *GameState, target float64) (Symbol, int, bool) {
+	upper := target + targetSlack(target)
+	level := gs.level()
+	size := level.GridSize
+
+	var best Symbol
+	bestCount, bestDiff := 0, math.Inf(1)
+	for _, symbol := range clusterSymbols {
+		for count := level.MinConnection; count <= size*size; count++ {
+			payout := round(calculatePayout(symbol, count, level, gs.Bet.Multiplier) * gs.FreeSpins.CurrentMultiplier)
+			if payout <= 0 || payout > upper {
+				continue
+			}
+			if diff := math.Abs(payout - target); diff < bestDiff {
+				best, bestCount, bestDiff = symbol, count, diff
+			}
+		}
+	}
+	return best, bestCount, bestCount > 0
+}
+
+// ApplyTargetForCascade redraws only the cells refilled by this step so that the grid pays within
+// tolerance of target. When the target cannot be reached it keeps the closest redraw that does
+// not pay more than the target allows. It reports whether the grid changed and whether the target was met.
+func ApplyTargetForCascade(gs *GameState, newPositions []Position, r random.Source, target float64) (bool, bool) {
+	allowed := uniquePositions(newPositions)
+	if len(allowed) == 0 {
+		return false, false
+	}
+	upper := target + targetSlack(target)
+
+	var under [][]string
+	underDiff := math.Inf(1)
+	consider := func(grid [][]string, payout float64) bool {
+		if diff := math.Abs(payout - target); payout <= upper && diff < underDiff {
+			under, underDiff = grid, diff
+		}
+		return withinTarget(payout, target)
+	}
+
+	if grid, ok := refineToTarget(gs, gs.Grid, allowed, r, target, consider); ok {
+		gs.Grid = grid
+		return true, true
+	}
+	if under != nil {
+		log.Printf("Target win %.2f not reached in cascade, paying closest %.2f", target, gridPayout(gs, under))
+		gs.Grid = under
+		return true, false
+	}
+	return false, false
+}
+
+// refineToTarget changes one allowed cell at a time, keeping changes that bring the payout
+// closer to target. New cells copy a neighbour half of the time so clusters can grow.
+func refineToTarget(gs *GameState, start [][]string, allowed []Position, r random.Source, target float64, consider func([][]string, float64) bool) ([][]string, bool) {
+	grid := copyGrid(start)
+	diff := math.Abs(gridPayout(gs, grid) - target)
+
+	for mutation := 0; mutation < targetMutations; mutation++ {
+		pos := allowed[r.Intn(len(allowed))]
+		symbol := string(WeightedRandomSymbolWithControl(gs.level(), r, true))
+		if r.Float64() < 0.5 {
+			if neighbour, ok := randomNeighbour(grid, pos, r); ok {
+				symbol = neighbour
+			}
+		}
+		if grid[pos.Y][pos.X] == symbol {
+			continue
+		}
+
+		candidate := copyGrid(grid)
+		candidate[pos.Y][pos.X] = symbol
+		payout := gridPayout(gs, candidate)
+		if consider(candidate, payout) {
+			log.Printf("Target win %.2f reached after %d grid changes", target, mutation+1)
+			return candidate, true
+		}
+		if candidateDiff := math.Abs(payout - target); candidateDiff < diff {
+			grid, diff = candidate, candidateDiff
+		}
+	}
+	return nil, false
+}
+
+// randomNeighbour returns the connection-forming symbol of a random orthogonal neighbour
+func randomNeighbour(grid [][]string, pos Position, r random.Source) (string, bool) {
+	offsets := [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	offset := offsets[r.Intn(len(offsets))]
+	x, y := pos.X+offset[0], pos.Y+offset[1]
+	if y < 0 || y >= len(grid) || x < 0 || x >= len(grid[y]) {
+		return "", false
+	}
+	symbol := grid[y][x]
+	if !IsConnectionFormingSymbol(Symbol(symbol)) {
+		return "", false
+	}
+	return symbol, true
+}
+
+// allPositions lists every cell of a size x size grid
+func allPositions(size int) []Position {
+	positions := make([]Position, 0, size*size)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			positions = append(positions, Position{X: x, Y: y})
+		}
+	}
+	return positions
+}
@@ -0,0 +1,130 @@
+package birdspartydeluxe
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/random"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/settings"
+)
+
+// targetState is a level 1 spin at the base bet
+func targetState() GameState {
+	gs := InitializeGameState()
+	beginRound(&gs, "bet-1", BaseBetAmount, settings.GameSettings{RTP: 0.96}, SpinFlowLegacy, DefaultMathModel(), "production")
+	prepareSpin(&gs)
+	return gs
+}
+
+// seededSource is the reproducible source of a label
+func seededSource(t *testing.T, label string) random.Source {
+	t.Helper()
+	factory := random.CryptoFactory{}
+	r, err := factory.New(factory.DeriveSeed(label), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestWithinTarget(t *testing.T) {
+	tests := []struct {
+		payout, target float64
+		want           bool
+	}{
+		{0, 0, true},
+		{0.01, 0, true}, // Never closer than one denomination
+		{0.02, 0, false},
+		{0.5, 0.5, true},
+		{9, 10, true},
+		{11, 10, true},
+		{8.9, 10, false},
+		{11.01, 10, false},
+	}
+	for _, tt := range tests {
+		if got := withinTarget(tt.payout, tt.target); got != tt.want {
+			t.Errorf("withinTarget(%v, %v) = %v, want %v", tt.payout, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateGridForTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  float64
+		wantMet bool
+	}{
+		{"zero target", 0, true},
+		{"small target", 0.3, true},
+		{"large target", 5, true},
+		{"target beyond the paytable", 1e6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := targetState()
+			grid, met := GenerateGridForTarget(&gs, seededSource(t, tt.name), tt.target)
+			if met != tt.wantMet {
+				t.Fatalf("target met = %v, want %v", met, tt.wantMet)
+			}
+			payout := gridPayout(&gs, grid)
+			if met && !withinTarget(payout, tt.target) {
+				t.Errorf("grid pays %v, not within tolerance of %v", payout, tt.target)
+			}
+			if !met && payout > tt.target+targetSlack(tt.target) {
+				t.Errorf("grid pays %v, more than the target %v allows", payout, tt.target)
+			}
+
+			// The same source draws the same grid, so replays reproduce it
+			again, _ := GenerateGridForTarget(&gs, seededSource(t, tt.name), tt.target)
+			if fmt.Sprint(again) != fmt.Sprint(grid) {
+				t.Error("the same seed generated a different grid")
+			}
+		})
+	}
+}
+
+func TestApplyTargetForCascade(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        int // Top rows refilled by the cascade
+		target      float64
+		wantChanged bool
+		wantMet     bool
+	}{
+		{"nothing refilled", 0, 0.3, false, false},
+		{"reachable target", 3, 0.3, true, true},
+		{"target beyond the paytable", 3, 1e6, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := targetState()
+			r := seededSource(t, tt.name)
+			gs.Grid = GenerateLossGrid(gs.level(), r, gs.GameMode)
+			before := copyGrid(gs.Grid)
+			var refilled []Position
+			for y := 0; y < tt.rows; y++ {
+				for x := range gs.Grid[y] {
+					refilled = append(refilled, Position{X: x, Y: y})
+				}
+			}
+
+			changed, met := ApplyTargetForCascade(&gs, refilled, r, tt.target)
+			if changed != tt.wantChanged || met != tt.wantMet {
+				t.Fatalf("ApplyTargetForCascade = changed %v, met %v, want %v, %v", changed, met, tt.wantChanged, tt.wantMet)
+			}
+			payout := gridPayout(&gs, gs.Grid)
+			if met && !withinTarget(payout, tt.target) {
+				t.Errorf("grid pays %v, not within tolerance of %v", payout, tt.target)
+			}
+			if !met && payout > tt.target+targetSlack(tt.target) {
+				t.Errorf("grid pays %v, more than the target %v allows", payout, tt.target)
+			}
+			// Only refilled cells may change; the rest of the grid fell from the previous step
+			for y := tt.rows; y < len(before); y++ {
+				if fmt.Sprint(gs.Grid[y]) != fmt.Sprint(before[y]) {
+					t.Errorf("row %d changed from %v to %v", y, before[y], gs.Grid[y])
+				}
+			}
+		})
+	}
+}
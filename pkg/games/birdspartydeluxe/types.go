@@ -1,6 +1,11 @@
 package birdspartydeluxe
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/fairness"
+)
 
 // Symbol type
 type Symbol string
@@ -23,28 +28,9 @@ const (
 	SymbolStrawberry  Symbol = "strawberry"   // Level 3 stage-cleared symbol
 )
 
-// Game constants
-const (
-	MinBet = 10
-
-	// Level requirements and grid sizes
-	Level1MinConnection = 4
-	Level2MinConnection = 5
-	Level3MinConnection = 6
-
-	Level1GridSize = 4 // 4x4 = 16 positions
-	Level2GridSize = 5 // 5x5 = 25 positions
-	Level3GridSize = 6 // 6x6 = 36 positions
-
-	// Progress requirements
-	StageProgressTarget = 15
-
-	// Free spin settings - DELUXE VERSION
-	FreeSpinsAwarded = 10
-)
-
-// DELUXE: Booming Reels Multiplier Constants
-var BoomingReelsMultipliers = []float64{1.0, 2.0, 3.0, 4.0, 5.0, 10.0}
+// Game constants. Grid sizes, connections, weights, paytables and the
+// feature constants are part of the math model (see MathModel).
+const MinBet = 10
 
 // Current level type
 type Level int
@@ -67,6 +53,13 @@ type StageClearedSymbol struct {
 	Position Position `json:"position"`
 }
 
+// Move is a symbol dropped by gravity
+type Move struct {
+	Symbol string   `json:"symbol"`
+	From   Position `json:"from"`
+	To     Position `json:"to"`
+}
+
 // Connection represents a group of connected symbols
 type Connection struct {
 	Symbol    Symbol     `json:"symbol"`
@@ -79,8 +72,13 @@ type Connection struct {
 type GameState struct {
 	Bet struct {
 		Amount     float64 `json:"amount"`
-		Multiplier int     `json:"multiplier"`
+		Multiplier float64 `json:"multiplier"`
 	} `json:"bet"`
+	RoundID       string     `json:"roundId"` // Server-assigned identifier of the current round, used by the audit log
+	Seed          string     `json:"-"`       // Random seed of the current round; with the step it reproduces every grid. Never sent to the client
+	BetID         string     `json:"betId"`   // bet_id of the spin that started the current round
+	Step          int        `json:"step"`    // Number of steps played in the current round (spin = 1)
+	Phase         RoundPhase `json:"phase"`   // Which call the round expects next
 	CurrentLevel  Level      `json:"currentLevel"`
 	GridSize      int        `json:"gridSize"`      // Current grid dimensions (4, 5, or 6)
 	Grid          [][]string `json:"grid"`          // Dynamic grid size
@@ -95,38 +93,63 @@ type GameState struct {
 		CloverConnectionsFound int     `json:"cloverConnectionsFound"` // Count of clover connections found in current cascade
 	} `json:"freeSpins"`
 	TotalWin        float64      `json:"totalWin"`
+	RoundCost       float64      `json:"roundCost"`               // Amount debited for the current round (0 for free spins)
+	RoundWin        float64      `json:"roundWin"`                // Accumulated win of the current round, credited when it ends
+	MaxWin          float64      `json:"maxWin"`                  // Operator's maximum round win, fixed when the round starts; 0 means no limit
+	RTP             float64      `json:"rtp"`                     // Settings RTP when the round started, used when settings are unavailable mid-round
+	Budget          float64      `json:"-"`                       // Expected return the player's decisions may still pay, see budget.go. Never sent to the client
+	SpinFlow        SpinFlow     `json:"spinFlow"`                // How the round's spin used the outcome provider
+	MathModel       string       `json:"mathModel"`               // ID of the math model the round is played with
+	MathProfile     string       `json:"mathProfile,omitempty"`   // RTP profile of the math model, chosen by the settings RTP
+	WeightProfile   string       `json:"weightProfile,omitempty"` // Weight profile of the environment the round started in
 	Cascading       bool         `json:"cascading"`
 	LastConnections []Connection `json:"lastConnections"`
 	CascadeCount    int          `json:"cascadeCount"`
 	// Stage-cleared symbols in current spin
 	StageClearedSymbols []StageClearedSymbol `json:"stageClearedSymbols"`
+
+	model *MathModel // Resolved from MathModel
 }
 
-// SpinRequest represents the request body for the /spin endpoint
+// SpinRequest represents the request body for the /spin endpoint.
+// In session mode the game state is held server-side and the client only chooses the bet.
 type SpinRequest struct {
-	GameState GameState `json:"gameState"`
-	ClientID  string    `json:"client_id"`
-	GameID    string    `json:"game_id"`
-	PlayerID  string    `json:"player_id"`
-	BetID     string    `json:"bet_id"`
+	ClientID  string  `json:"client_id"`
+	GameID    string  `json:"game_id"`
+	PlayerID  string  `json:"player_id"`
+	BetID     string  `json:"bet_id"`
+	BetAmount float64 `json:"bet_amount"`
+	Step      int     `json:"step,omitempty"` // Optional, always 1 for a spin
+
+	// Stateless mode only: the state and token returned by the previous response
+	GameState  *GameState `json:"gameState,omitempty"`
+	StateToken string     `json:"stateToken,omitempty"`
 }
 
 // ProcessStageClearedRequest represents the request body for the /process-stage-cleared endpoint
 type ProcessStageClearedRequest struct {
-	GameState GameState `json:"gameState"`
-	ClientID  string    `json:"client_id"`
-	GameID    string    `json:"game_id"`
-	PlayerID  string    `json:"player_id"`
-	BetID     string    `json:"bet_id"`
+	ClientID string `json:"client_id"`
+	GameID   string `json:"game_id"`
+	PlayerID string `json:"player_id"`
+	BetID    string `json:"bet_id"`         // Must match the bet_id of the spin that started the round
+	Step     int    `json:"step,omitempty"` // Step this call produces; required while retries are deduplicated
+
+	// Stateless mode only: the state and token returned by the previous response
+	GameState  *GameState `json:"gameState,omitempty"`
+	StateToken string     `json:"stateToken,omitempty"`
 }
 
 // CascadeRequest represents the request body for the /cascade endpoint
 type CascadeRequest struct {
-	GameState GameState `json:"gameState"`
-	ClientID  string    `json:"client_id"`
-	GameID    string    `json:"game_id"`
-	PlayerID  string    `json:"player_id"`
-	BetID     string    `json:"bet_id"`
+	ClientID string `json:"client_id"`
+	GameID   string `json:"game_id"`
+	PlayerID string `json:"player_id"`
+	BetID    string `json:"bet_id"`         // Must match the bet_id of the spin that started the round
+	Step     int    `json:"step,omitempty"` // Step this call produces; required while retries are deduplicated
+
+	// Stateless mode only: the state and token returned by the previous response
+	GameState  *GameState `json:"gameState,omitempty"`
+	StateToken string     `json:"stateToken,omitempty"`
 }
 
 // SpinResponse represents the response body for the /spin endpoint
@@ -137,6 +160,8 @@ type SpinResponse struct {
 	StageClearedSymbols []StageClearedSymbol `json:"stageClearedSymbols"`
 	HasStageCleared     bool                 `json:"hasStageCleared"`
 	TotalCost           float64              `json:"totalCost"`
+	StateToken          string               `json:"stateToken,omitempty"` // Stateless mode only: must be sent with the next request
+	RoundSeed           string               `json:"roundSeed,omitempty"`  // Seed of the round, revealed once the round has ended
 }
 
 // ProcessStageClearedResponse represents the response body for the /process-stage-cleared endpoint
@@ -150,6 +175,8 @@ type ProcessStageClearedResponse struct {
 	NewLevel          Level        `json:"newLevel,omitempty"`
 	Connections       []Connection `json:"connections"`
 	TotalCost         float64      `json:"totalCost"`
+	StateToken        string       `json:"stateToken,omitempty"` // Stateless mode only: must be sent with the next request
+	RoundSeed         string       `json:"roundSeed,omitempty"`  // Seed of the round, revealed once the round has ended
 }
 
 // CascadeResponse represents the response body for the /cascade endpoint
@@ -161,6 +188,55 @@ type CascadeResponse struct {
 	StageClearedSymbols []StageClearedSymbol `json:"stageClearedSymbols"`
 	HasStageCleared     bool                 `json:"hasStageCleared"`
 	TotalCost           float64              `json:"totalCost"`
+	StateToken          string               `json:"stateToken,omitempty"` // Stateless mode only: must be sent with the next request
+	RoundSeed           string               `json:"roundSeed,omitempty"`  // Seed of the round, revealed once the round has ended
+}
+
+// FairnessRequest represents the request body for the /fairness endpoints
+type FairnessRequest struct {
+	ClientID       string `json:"client_id"`
+	GameID         string `json:"game_id"`
+	PlayerID       string `json:"player_id"`
+	ClientSeed     string `json:"client_seed,omitempty"`      // Rotate only: client seed of the next server seed (random when empty)
+	ServerSeedHash string `json:"server_seed_hash,omitempty"` // Reveal only: the retired seed to reveal
+}
+
+// FairnessResponse represents the response body for the /fairness endpoints
+type FairnessResponse struct {
+	Status     string               `json:"status"`
+	Message    string               `json:"message"`
+	Commitment *fairness.Commitment `json:"commitment,omitempty"` // Seed pair the next steps are played with
+	Revealed   *fairness.Reveal     `json:"revealed,omitempty"`   // Retired server seed, for verification
+}
+
+// ReplayResponse represents the response body for the /rounds/{id}/replay endpoint
+type ReplayResponse struct {
+	Status      string         `json:"status"`
+	Message     string         `json:"message"`
+	RoundID     string         `json:"roundId"`
+	Steps       []ReplayedStep `json:"steps"`
+	PaidWin     float64        `json:"paidWin"`     // Sum of the recorded step wins
+	ReplayedWin float64        `json:"replayedWin"` // Sum of the replayed step wins
+	Diverged    bool           `json:"diverged"`    // Whether any step differs from what was recorded
+}
+
+// ReplayedStep is one re-executed step of a round
+type ReplayedStep struct {
+	Step        int          `json:"step"`
+	Action      RoundAction  `json:"action"`
+	Seed        string       `json:"seed"`
+	InputGrid   [][]string   `json:"inputGrid"`
+	Frames      []Frame      `json:"frames"`
+	Removed     []Position   `json:"removed"`
+	Moves       []Move       `json:"moves"`
+	Filled      []Position   `json:"filled"`
+	Connections []Connection `json:"connections"` // Paying connections with their replayed payouts
+	PrefOutcome string       `json:"prefOutcome,omitempty"`
+	RNGBypassed bool         `json:"rngBypassed"`
+	OutputGrid  [][]string   `json:"outputGrid"`
+	Win         float64      `json:"win"`
+	PaidWin     float64      `json:"paidWin"`
+	Divergences []string     `json:"divergences,omitempty"`
 }
 
 // ValidateLevel validates the current level
@@ -173,34 +249,6 @@ func (l Level) ValidateLevel() error {
 	}
 }
 
-// GetMinConnection returns the minimum connection requirement for the level
-func (l Level) GetMinConnection() int {
-	switch l {
-	case Level1:
-		return Level1MinConnection
-	case Level2:
-		return Level2MinConnection
-	case Level3:
-		return Level3MinConnection
-	default:
-		return Level1MinConnection
-	}
-}
-
-// GetGridSize returns the grid size for the level
-func (l Level) GetGridSize() int {
-	switch l {
-	case Level1:
-		return Level1GridSize
-	case Level2:
-		return Level2GridSize
-	case Level3:
-		return Level3GridSize
-	default:
-		return Level1GridSize
-	}
-}
-
 // GetStageClearedSymbol returns the stage-cleared symbol for the level
 func (l Level) GetStageClearedSymbol() Symbol {
 	switch l {
@@ -215,94 +263,46 @@ func (l Level) GetStageClearedSymbol() Symbol {
 	}
 }
 
-// BetAmountToMultiplier maps bet amounts to multipliers
-var BetAmountToMultiplier = map[float64]int{
-	0.1: 1,
-	0.2: 2,
-	0.3: 3,
-	0.5: 5,
-	1.0: 10,
-	2.0: 20,
-	2.5: 25,
-}
-
-// GetLevelSpecificWeights returns symbol weights for a specific level
-// Stage-cleared symbols only appear on their corresponding level
-func GetLevelSpecificWeights(level Level) map[Symbol]float64 {
-	weights := make(map[Symbol]float64)
+// SpinFlow is the order in which a spin consults the outcome provider and generates its grid
+type SpinFlow string
 
-	// Base weights for all levels - INCREASED CLOVER APPEARANCE
-	weights[SymbolPurpleOwl] = 0.15
-	weights[SymbolGreenOwl] = 0.15
-	weights[SymbolYellowOwl] = 0.15
-	weights[SymbolBlueOwl] = 0.15
-	weights[SymbolRedOwl] = 0.15
+const (
+	SpinFlowRNGFirst SpinFlow = "rng-first" // Ask for the outcome, then generate a grid of that outcome class
+	SpinFlowLegacy   SpinFlow = "legacy"    // Generate a winning grid, then ask whether its payout may stand
+	SpinFlowCycle    SpinFlow = "cycle"     // Ask once for the whole game cycle, then play its cascade chain ahead to fit
+)
 
-	// Level-specific clover weights - INCREASED APPEARANCE PER LEVEL
-	switch level {
-	case Level1:
-		weights[SymbolClover] = 0.20       // Level 1: 25% chance
-		weights[SymbolOrangeSlice] = 0.05 // For testing -0.05, for production-0.005
-	case Level2:
-		weights[SymbolClover] = 0.30   // Level 2: 30% chance (increased)
-		weights[SymbolHoneyPot] = 0.05 // For testing -0.05, for production-0.005
-	case Level3:
-		weights[SymbolClover] = 0.35     // Level 3: 35% chance (highest)
-		weights[SymbolStrawberry] = 0.05 // For testing -0.05, for production-0.005
+// ParseSpinFlow validates a configured spin flow
+func ParseSpinFlow(value string) (SpinFlow, error) {
+	switch flow := SpinFlow(value); flow {
+	case SpinFlowRNGFirst, SpinFlowLegacy, SpinFlowCycle:
+		return flow, nil
+	default:
+		return "", fmt.Errorf("unknown spin flow: %s", value)
 	}
-
-	// DELUXE: Only rainbow egg is special symbol with low weight
-	weights[SymbolFreeGame] = 0.05 // Rainbow egg - triggers free spins (low probability)
-
-	return weights
 }
 
-// Paytables for each level (identical to original Birds Party)
-// Level 1 Paytable (4x4 grid, supports 4-16 connected symbols)
-var PaytableLevel1 = map[Symbol]map[int]float64{
-	SymbolPurpleOwl: {4: 2, 5: 4, 6: 5, 7: 8, 8: 10, 9: 20, 10: 30, 11: 50, 12: 100, 13: 200, 14: 400, 15: 400, 16: 400},
-	SymbolGreenOwl:  {4: 4, 5: 5, 6: 10, 7: 20, 8: 30, 9: 50, 10: 100, 11: 250, 12: 500, 13: 750, 14: 800, 15: 800, 16: 800},
-	SymbolYellowOwl: {4: 5, 5: 10, 6: 20, 7: 40, 8: 80, 9: 160, 10: 500, 11: 1000, 12: 2000, 13: 5000, 14: 6000, 15: 6000, 16: 6000},
-	SymbolBlueOwl:   {4: 10, 5: 30, 6: 50, 7: 60, 8: 100, 9: 750, 10: 1000, 11: 10000, 12: 20000, 13: 50000, 14: 60000, 15: 60000, 16: 60000},
-	SymbolRedOwl:    {4: 20, 5: 50, 6: 100, 7: 500, 8: 1000, 9: 2000, 10: 5000, 11: 20000, 12: 50000, 13: 60000, 14: 80000, 15: 80000, 16: 80000},
-	// DELUXE: Clover symbols have same payouts as purple owl (lowest paying)
-	SymbolClover: {4: 2, 5: 4, 6: 5, 7: 8, 8: 10, 9: 20, 10: 30, 11: 50, 12: 100, 13: 200, 14: 400, 15: 400, 16: 400},
-}
+// BaseBetAmount is the bet that plays the paytable at multiplier 1 (10 credits of 0.01)
+const BaseBetAmount = 0.1
 
-// Level 2 Paytable (5x5 grid, supports 5-25 connected symbols)
-var PaytableLevel2 = map[Symbol]map[int]float64{
-	SymbolPurpleOwl: {5: 2, 6: 4, 7: 5, 8: 8, 9: 10, 10: 20, 11: 30, 12: 50, 13: 100, 14: 200, 15: 450, 16: 450, 17: 450, 18: 450, 19: 450, 20: 450, 21: 450, 22: 450, 23: 450, 24: 450, 25: 450},
-	SymbolGreenOwl:  {5: 4, 6: 5, 7: 10, 8: 20, 9: 30, 10: 50, 11: 100, 12: 250, 13: 500, 14: 750, 15: 1000, 16: 1000, 17: 1000, 18: 1000, 19: 1000, 20: 1000, 21: 1000, 22: 1000, 23: 1000, 24: 1000, 25: 1000},
-	SymbolYellowOwl: {5: 5, 6: 10, 7: 20, 8: 40, 9: 80, 10: 160, 11: 500, 12: 1000, 13: 2000, 14: 5000, 15: 7000, 16: 7000, 17: 7000, 18: 7000, 19: 7000, 20: 7000, 21: 7000, 22: 7000, 23: 7000, 24: 7000, 25: 7000},
-	SymbolBlueOwl:   {5: 10, 6: 30, 7: 50, 8: 60, 9: 100, 10: 750, 11: 1000, 12: 10000, 13: 20000, 14: 50000, 15: 70000, 16: 70000, 17: 70000, 18: 70000, 19: 70000, 20: 70000, 21: 70000, 22: 70000, 23: 70000, 24: 70000, 25: 70000},
-	SymbolRedOwl:    {5: 20, 6: 50, 7: 100, 8: 500, 9: 1000, 10: 2000, 11: 5000, 12: 20000, 13: 50000, 14: 80000, 15: 100000, 16: 100000, 17: 100000, 18: 100000, 19: 100000, 20: 100000, 21: 100000, 22: 100000, 23: 100000, 24: 100000, 25: 100000},
-	// DELUXE: Clover symbols have same payouts as purple owl (lowest paying)
-	SymbolClover: {5: 2, 6: 4, 7: 5, 8: 8, 9: 10, 10: 20, 11: 30, 12: 50, 13: 100, 14: 200, 15: 450, 16: 450, 17: 450, 18: 450, 19: 450, 20: 450, 21: 450, 22: 450, 23: 450, 24: 450, 25: 450},
+// BetMultiplier returns the paytable multiplier of a bet amount from the operator's bet ladder
+func BetMultiplier(amount float64) float64 {
+	return math.Round(amount/BaseBetAmount*100) / 100
 }
 
-// Level 3 Paytable (6x6 grid, supports 6-36 connected symbols)
-var PaytableLevel3 = map[Symbol]map[int]float64{
-	SymbolPurpleOwl: {6: 2, 7: 4, 8: 5, 9: 8, 10: 10, 11: 20, 12: 30, 13: 50, 14: 100, 15: 200, 16: 500, 17: 500, 18: 500, 19: 500, 20: 500, 21: 500, 22: 500, 23: 500, 24: 500, 25: 500, 26: 500, 27: 500, 28: 500, 29: 500, 30: 500, 31: 500, 32: 500, 33: 500, 34: 500, 35: 500, 36: 500},
-	SymbolGreenOwl:  {6: 4, 7: 5, 8: 10, 9: 20, 10: 30, 11: 50, 12: 100, 13: 250, 14: 500, 15: 750, 16: 1200, 17: 1200, 18: 1200, 19: 1200, 20: 1200, 21: 1200, 22: 1200, 23: 1200, 24: 1200, 25: 1200, 26: 1200, 27: 1200, 28: 1200, 29: 1200, 30: 1200, 31: 1200, 32: 1200, 33: 1200, 34: 1200, 35: 1200, 36: 1200},
-	SymbolYellowOwl: {6: 5, 7: 10, 8: 20, 9: 40, 10: 80, 11: 160, 12: 500, 13: 1000, 14: 2000, 15: 5000, 16: 8000, 17: 8000, 18: 8000, 19: 8000, 20: 8000, 21: 8000, 22: 8000, 23: 8000, 24: 8000, 25: 8000, 26: 8000, 27: 8000, 28: 8000, 29: 8000, 30: 8000, 31: 8000, 32: 8000, 33: 8000, 34: 8000, 35: 8000, 36: 8000},
-	SymbolBlueOwl:   {6: 10, 7: 30, 8: 50, 9: 60, 10: 100, 11: 750, 12: 1000, 13: 10000, 14: 20000, 15: 50000, 16: 80000, 17: 80000, 18: 80000, 19: 80000, 20: 80000, 21: 80000, 22: 80000, 23: 80000, 24: 80000, 25: 80000, 26: 80000, 27: 80000, 28: 80000, 29: 80000, 30: 80000, 31: 80000, 32: 80000, 33: 80000, 34: 80000, 35: 80000, 36: 80000},
-	SymbolRedOwl:    {6: 20, 7: 50, 8: 100, 9: 500, 10: 1000, 11: 2000, 12: 5000, 13: 20000, 14: 50000, 15: 100000, 16: 100000, 17: 100000, 18: 100000, 19: 100000, 20: 100000, 21: 100000, 22: 100000, 23: 100000, 24: 100000, 25: 100000, 26: 100000, 27: 100000, 28: 100000, 29: 100000, 30: 100000, 31: 100000, 32: 100000, 33: 100000, 34: 100000, 35: 100000, 36: 100000},
-	// DELUXE: Clover symbols have same payouts as purple owl (lowest paying)
-	SymbolClover: {6: 2, 7: 4, 8: 5, 9: 8, 10: 10, 11: 20, 12: 30, 13: 50, 14: 100, 15: 200, 16: 500, 17: 500, 18: 500, 19: 500, 20: 500, 21: 500, 22: 500, 23: 500, 24: 500, 25: 500, 26: 500, 27: 500, 28: 500, 29: 500, 30: 500, 31: 500, 32: 500, 33: 500, 34: 500, 35: 500, 36: 500},
-}
-
-// GetPaytable returns the appropriate paytable for the given level
-func GetPaytable(level Level) map[Symbol]map[int]float64 {
-	switch level {
-	case Level1:
-		return PaytableLevel1
-	case Level2:
-		return PaytableLevel2
-	case Level3:
-		return PaytableLevel3
-	default:
-		return PaytableLevel1
-	}
+// SymbolOrder is the fixed order in which weighted picks walk the symbols.
+// Map iteration is random in Go, so picks must not range over the weights map.
+var SymbolOrder = []Symbol{
+	SymbolPurpleOwl,
+	SymbolGreenOwl,
+	SymbolYellowOwl,
+	SymbolBlueOwl,
+	SymbolRedOwl,
+	SymbolClover,
+	SymbolOrangeSlice,
+	SymbolHoneyPot,
+	SymbolStrawberry,
+	SymbolFreeGame,
 }
 
 // IsStageClearedSymbol checks if a symbol is a stage-cleared symbol
@@ -321,19 +321,12 @@ func IsConnectionFormingSymbol(symbol Symbol) bool {
 	return IsRegularBirdSymbol(symbol) || symbol == SymbolClover
 }
 
-// DELUXE: GetBoomingReelsMultiplier returns the multiplier for the given level
-func GetBoomingReelsMultiplier(level int) float64 {
-	if level < 0 || level >= len(BoomingReelsMultipliers) {
-		return 1.0 // Default multiplier
-	}
-	return BoomingReelsMultipliers[level]
-}
-
 // DELUXE: UpgradeBoomingReels upgrades the booming reels multiplier level
 func UpgradeBoomingReels(gameState *GameState) {
-	if gameState.FreeSpins.BoomingReelsLevel < len(BoomingReelsMultipliers)-1 {
+	model := gameState.math()
+	if gameState.FreeSpins.BoomingReelsLevel < len(model.BoomingReelsMultipliers)-1 {
 		gameState.FreeSpins.BoomingReelsLevel++
-		gameState.FreeSpins.CurrentMultiplier = GetBoomingReelsMultiplier(gameState.FreeSpins.BoomingReelsLevel)
+		gameState.FreeSpins.CurrentMultiplier = model.BoomingReelsMultiplier(gameState.FreeSpins.BoomingReelsLevel)
 		gameState.FreeSpins.CloverConnectionsFound++
 	}
 }
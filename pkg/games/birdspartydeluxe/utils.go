@@ -1,4 +1,4 @@
 package birdspartydeluxe
 
 // This file contains utility functions specific to the Birds Party Deluxe game
-// Currently contains helper functions used throughout the game logic
\ No newline at end of file
+// Currently contains helper functions used throughout the game logic
@@ -0,0 +1,109 @@
+package birdspartydeluxe
+
+import (
+	"log"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/wallet"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Transaction IDs are derived from the round's bet_id and round_id, so a retried wallet call never
+// moves money twice, while a spin retried after its round was rolled back is a new round that is
+// charged again: a rolled-back transaction ID is never reused.
+func debitTransactionID(gameState GameState) string {
+	return gameState.BetID + ":" + gameState.RoundID + ":debit"
+}
+func creditTransactionID(gameState GameState) string {
+	return gameState.BetID + ":" + gameState.RoundID + ":credit"
+}
+func rollbackTransactionID(refID string) string { return refID + ":rollback" }
+
+// roundEnded reports whether the round has no more stage-cleared or cascade steps to play
+func roundEnded(phase RoundPhase) bool {
+	return phase == PhaseCompleted || phase == PhaseFreeSpinPending
+}
+
+// debitBet takes the round's cost from the player's wallet
+func debitBet(c *fiber.Ctx, w wallet.Wallet, ref roundRef, gameState *GameState) error {
+	if w == nil || gameState.RoundCost <= 0 {
+		return nil
+	}
+	balance, err := w.Debit(c.UserContext(), wallet.Transaction{
+		TransactionID: debitTransactionID(*gameState),
+		RoundID:       gameState.BetID,
+		ClientID:      ref.ClientID,
+		GameID:        ref.GameID,
+		PlayerID:      ref.PlayerID,
+		Amount:        gameState.RoundCost,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Debited %.2f for bet %s, balance %.2f", gameState.RoundCost, gameState.BetID, balance)
+	return nil
+}
+
+// settleRound credits the accumulated round win once the cascade chain has ended
+func settleRound(c *fiber.Ctx, w wallet.Wallet, ref roundRef, gameState *GameState) error {
+	if w == nil || !roundEnded(gameState.Phase) || gameState.RoundWin <= 0 {
+		return nil
+	}
+	balance, err := w.Credit(c.UserContext(), wallet.Transaction{
+		TransactionID: creditTransactionID(*gameState),
+		RoundID:       gameState.BetID,
+		ClientID:      ref.ClientID,
+		GameID:        ref.GameID,
+		PlayerID:      ref.PlayerID,
+		Amount:        gameState.RoundWin,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Credited %.2f for bet %s, balance %.2f", gameState.RoundWin, gameState.BetID, balance)
+	return nil
+}
+
+// rollbackRound reverses the round's credit and debit. Rolling back a transaction
+// that never reached the wallet is a no-op, so both are always attempted.
+func rollbackRound(c *fiber.Ctx, w wallet.Wallet, ref roundRef, gameState GameState) {
+	if w == nil || gameState.RoundCost <= 0 && gameState.RoundWin <= 0 {
+		return
+	}
+	for _, refID := range []string{creditTransactionID(gameState), debitTransactionID(gameState)} {
+		_, err := w.Rollback(c.UserContext(), wallet.Rollback{
+			TransactionID: rollbackTransactionID(refID),
+			ReferenceID:   refID,
+			RoundID:       gameState.BetID,
+			ClientID:      ref.ClientID,
+			GameID:        ref.GameID,
+			PlayerID:      ref.PlayerID,
+		})
+		if err != nil {
+			log.Printf("⚠️  WALLET: Failed to roll back %s: %v", refID, err)
+			continue
+		}
+		log.Printf("Rolled back %s", refID)
+	}
+}
+
+// compensateFailedStep rolls back the round's money when a step fails after the bet was debited,
+// and voids the round so it cannot be continued. A 503 only voids the step, which can be retried,
+// and in stateless mode the client still holds the last valid token, so the round is left intact.
+func (rg *RouteGroup) compensateFailedStep(c *fiber.Ctx, w wallet.Wallet, ref roundRef, before GameState) {
+	status := c.Response().StatusCode()
+	if status < fiber.StatusInternalServerError || status == fiber.StatusServiceUnavailable || w == nil || rg.stateless() {
+		return
+	}
+	log.Printf("⚠️  Step failed for bet %s, voiding round", before.BetID)
+	rollbackRound(c, w, ref, before)
+
+	before.Phase = PhaseCompleted
+	before.Cascading = false
+	before.LastConnections = []Connection{}
+	before.StageClearedSymbols = []StageClearedSymbol{}
+	before.TotalWin = 0
+	before.RoundWin = 0
+	if _, err := rg.saveGameState(ref, before); err != nil {
+		log.Printf("⚠️  Failed to save voided round for bet %s: %v", before.BetID, err)
+	}
+}
@@ -0,0 +1,82 @@
+package birdspartydeluxe
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/rng"
+	"github.com/JILI-GAMES/b_backend_games12/pkg/common/wallet"
+)
+
+// unavailableProvider answers like the RNG service being down while down is set
+type unavailableProvider struct {
+	rng.OutcomeProvider
+	down atomic.Bool
+}
+
+func (p *unavailableProvider) Send(ctx context.Context, req rng.Request) (rng.Response, error) {
+	if p.down.Load() {
+		return rng.Response{}, rng.ErrRNGUnavailable
+	}
+	return p.OutcomeProvider.Send(ctx, req)
+}
+
+func newWalletServer(t *testing.T) (*testServer, *wallet.MemoryWallet, *unavailableProvider) {
+	t.Helper()
+	w := wallet.NewMemoryWallet()
+	w.SetBalance(testPlayer.ClientID, testPlayer.PlayerID, 10)
+	var provider *unavailableProvider
+	s := newTestServer(t, func(rg *RouteGroup) {
+		provider = &unavailableProvider{OutcomeProvider: rg.RNGProd}
+		rg.RNGProd = provider
+		rg.WalletProd = w
+	})
+	return s, w, provider
+}
+
+func (s *testServer) balance(w *wallet.MemoryWallet) float64 {
+	s.t.Helper()
+	balance, err := w.Balance(context.Background(), testPlayer.ClientID, testPlayer.PlayerID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return balance
+}
+
+func TestRetriedSpinIsCharged(t *testing.T) {
+	s, w, provider := newWalletServer(t)
+
+	provider.down.Store(true)
+	body := stepBody(testPlayer, betID(1), nil)
+	body["bet_amount"] = 1.0
+	if status, reply := s.post("/spin/birdspartydeluxe", body); status != http.StatusServiceUnavailable {
+		t.Fatalf("spin with the RNG down = %d %v, want 503", status, reply["message"])
+	}
+	if got := s.balance(w); got != 10 {
+		t.Errorf("balance after a voided spin = %v, want the bet refunded (10)", got)
+	}
+
+	provider.down.Store(false)
+	last := s.playRound(testPlayer, betID(1), nil)
+	roundWin := last["gameState"].(map[string]any)["roundWin"].(float64)
+	if got, want := s.balance(w), 9+roundWin; got != want {
+		t.Errorf("balance after the retried spin = %v, want %v", got, want)
+	}
+}
+
+func TestSpinCannotReuseBetID(t *testing.T) {
+	s, w, _ := newWalletServer(t)
+	s.playRound(testPlayer, betID(1), nil)
+	before := s.balance(w)
+
+	body := stepBody(testPlayer, betID(1), nil)
+	body["bet_amount"] = 1.0
+	if status, reply := s.post("/spin/birdspartydeluxe", body); status != http.StatusConflict {
+		t.Errorf("spin reusing a played bet_id = %d %v, want 409", status, reply["message"])
+	}
+	if got := s.balance(w); got != before {
+		t.Errorf("balance = %v, want %v", got, before)
+	}
+}